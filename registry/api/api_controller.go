@@ -1,15 +1,60 @@
 package api
 
 import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+
 	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
 )
 
 type APIController struct {
-	access datastore.Access
+	access  datastore.Access
+	changes *changeNotifier
+	// nil when certificates are not accepted
+	trust *CertificateTrust
+	ca    *CertificateAuthority
+	// nil when responses are not signed
+	signer *ResponseSigner
+	// set when access is filled from an upstream registry
+	mirror *Mirror
 }
 
 func NewAPIController(access datastore.Access) *APIController {
 	return &APIController{
-		access: access,
+		access:  access,
+		changes: newChangeNotifier(),
+	}
+}
+
+// Ping checks that datastore is reachable, and for mirrors that it holds upstream data
+func (api *APIController) Ping(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "api.Ping")
+	defer func() { tracing.End(span, err) }()
+	if api.mirror != nil && !api.mirror.synced() {
+		logging.FromContext(ctx).WithField("method", "api.Ping").Warn("mirror has not synced yet")
+		err = ErrServiceUnavailable
+		return
+	}
+	err = api.access.Ping(ctx)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("method", "api.Ping").Error("error in access.Ping")
+		err = ErrInternalServerError
+	}
+	return
+}
+
+// inTx runs f in one datastore transaction. f returns api errors, failing transaction itself is internal error.
+func (api *APIController) inTx(ctx context.Context, clog *log.Entry, f func(tx pgx.Tx) error) (err error) {
+	err = api.access.RunInTx(ctx, f)
+	var apiErr *Error
+	if err != nil && !errors.As(err, &apiErr) {
+		clog.WithError(err).Error("error in access.RunInTx")
+		err = ErrInternalServerError
 	}
+	return
 }
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	adminTokenBytes      = 32
+	adminTokenDefaultTtl = 24 * time.Hour
+	adminTokenMaxTtl     = 90 * 24 * time.Hour
+	adminPasswordMinLen  = 10
+	adminUsernameMaxLen  = 100
+	auditLogListLimit    = 500
+)
+
+// randomToken returns url-safe secret, only its hashToken is stored
+func randomToken() (token string, err error) {
+	raw := make([]byte, adminTokenBytes)
+	if _, err = rand.Read(raw); err != nil {
+		return
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authorize checks whether admin's role grants the permission, returns ErrForbidden if not
+func (api *APIController) Authorize(admin *entity.Admin, permission entity.AdminPermission) (err error) {
+	if admin == nil || !admin.Role.Has(permission) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (api *APIController) AdminAuthenticatePassword(ctx context.Context, username, password string) (admin *entity.Admin, err error) {
+	ctx, span := tracing.Start(ctx, "api.AdminAuthenticatePassword")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method":   "api.AdminAuthenticatePassword",
+		"username": username,
+	})
+	admin, err = api.access.AdminByUsername(ctx, username)
+	if err != nil {
+		eMsg := "error in access.AdminByUsername"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if admin == nil || admin.State != entity.EntityStateEnabled {
+		clog.Warn("admin not found or not enabled")
+		admin = nil
+		err = ErrUnauthorized
+		return
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password))
+	if err != nil {
+		clog.Warn("invalid password")
+		admin = nil
+		err = ErrUnauthorized
+		return
+	}
+	return
+}
+
+func (api *APIController) AdminAuthenticateToken(ctx context.Context, token string) (admin *entity.Admin, err error) {
+	ctx, span := tracing.Start(ctx, "api.AdminAuthenticateToken")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.AdminAuthenticateToken",
+	})
+	var item *entity.AdminToken
+	item, err = api.access.AdminTokenByHash(ctx, hashToken(token))
+	if err != nil {
+		eMsg := "error in access.AdminTokenByHash"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if item == nil || item.State != entity.EntityStateEnabled || time.Now().After(item.ExpireTs) {
+		clog.Warn("token not found, not enabled or expired")
+		err = ErrUnauthorized
+		return
+	}
+	admin, err = api.access.AdminById(ctx, item.AdminId)
+	if err != nil {
+		eMsg := "error in access.AdminById"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if admin == nil || admin.State != entity.EntityStateEnabled {
+		clog.WithField("admin-id", item.AdminId).Warn("token owner not found or not enabled")
+		admin = nil
+		err = ErrUnauthorized
+		return
+	}
+	return
+}
+
+func (api *APIController) AdminAdd(ctx context.Context, actor string, username, password string, role entity.AdminRole) (admin *entity.Admin, err error) {
+	ctx, span := tracing.Start(ctx, "api.AdminAdd")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method":   "api.AdminAdd",
+		"actor":    actor,
+		"username": username,
+	})
+	v := &validator{}
+	v.requiredString("username", username, adminUsernameMaxLen)
+	if len(password) < adminPasswordMinLen {
+		v.add("password", FieldReasonTooShort)
+	}
+	if !role.Valid() {
+		v.add("role", FieldReasonInvalidValue)
+	}
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid username, password or role")
+		return
+	}
+	var hash []byte
+	hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		eMsg := "error in bcrypt.GenerateFromPassword"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	admin, err = api.access.AdminAdd(ctx, nil, actor, username, string(hash), role)
+	if err != nil {
+		eMsg := "error in access.AdminAdd"
+		clog.WithError(err).Error(eMsg)
+		err = storeError(err)
+		return
+	}
+	return
+}
+
+// AdminTokenCreate issues new API token for actor, plain token is returned only once, only its hash is stored
+func (api *APIController) AdminTokenCreate(ctx context.Context, actor *entity.Admin, req *entity.AdminTokenCreateRequest) (resp *entity.AdminTokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.AdminTokenCreate")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.AdminTokenCreate",
+		"actor":  actor.Username,
+	})
+	ttl := time.Duration(req.TtlHours) * time.Hour
+	if ttl <= 0 {
+		ttl = adminTokenDefaultTtl
+	}
+	if ttl > adminTokenMaxTtl {
+		clog.WithField("ttl", ttl).Warn("token ttl is too long")
+		err = ErrValidation.WithDetails(FieldError{Field: "ttl_hours", Reason: FieldReasonInvalidValue})
+		return
+	}
+	token, err := randomToken()
+	if err != nil {
+		eMsg := "error in randomToken"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	var item *entity.AdminToken
+	item, err = api.access.AdminTokenAdd(ctx, nil, actor.Username, actor, hashToken(token), req.Label, time.Now().Add(ttl))
+	if err != nil {
+		eMsg := "error in access.AdminTokenAdd"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	resp = &entity.AdminTokenResponse{
+		Id:       item.Id,
+		Token:    token,
+		Label:    item.Label,
+		ExpireTs: item.ExpireTs.Unix(),
+	}
+	return
+}
+
+func (api *APIController) AuditLogList(ctx context.Context) (items []*entity.AuditLogResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.AuditLogList")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.AuditLogList",
+	})
+	items = make([]*entity.AuditLogResponse, 0)
+	var logs []*entity.AuditLog
+	logs, err = api.access.AuditLogList(ctx, auditLogListLimit)
+	if err != nil {
+		eMsg := "error in access.AuditLogList"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	for _, l := range logs {
+		items = append(items, &entity.AuditLogResponse{
+			Id:         l.Id,
+			Actor:      l.Actor,
+			Action:     l.Action,
+			ObjectType: l.ObjectType,
+			ObjectId:   l.ObjectId,
+			Details:    l.Details,
+			CreateTs:   l.CreateTs.Unix(),
+		})
+	}
+	return
+}
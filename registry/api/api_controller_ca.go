@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+// certificateRequestActor is recorded in audit log for issued certificates, requesters prove the key by signing the CSR
+const certificateRequestActor = "certificate-request"
+
+var ErrCaNotConfigured = ErrNotFound.WithAppCode(AppCodeCaNotConfigured)
+
+func issuedCertificateResponse(item *entity.IssuedCertificate) *entity.IssuedCertificateResponse {
+	return &entity.IssuedCertificateResponse{
+		Id:               item.Id,
+		OrganizationId:   item.OrganizationId,
+		Serial:           item.Serial,
+		Usage:            item.Usage,
+		KeyFingerprint:   item.KeyFingerprint,
+		Certificate:      item.Certificate,
+		NotBefore:        item.NotBefore.Unix(),
+		NotAfter:         item.NotAfter.Unix(),
+		RevokedTs:        unixOrNil(item.RevokedTs),
+		RevocationReason: item.RevocationReason,
+		CreateTs:         item.CreateTs.Unix(),
+	}
+}
+
+// parseCertificateRequest accepts PEM encoded PKCS #10 request, both header variants openssl writes
+func parseCertificateRequest(csr string) (req *x509.CertificateRequest, ok bool) {
+	block, _ := pem.Decode([]byte(csr))
+	if block == nil || (block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST") {
+		return nil, false
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, false
+	}
+	return req, true
+}
+
+// normalizeSerial accepts hex serial in either case, optionally with colons between bytes and leading zeros
+func normalizeSerial(serial string) (normalized string, ok bool) {
+	n, ok := new(big.Int).SetString(strings.ReplaceAll(serial, ":", ""), 16)
+	if !ok || n.Sign() <= 0 {
+		return "", false
+	}
+	return n.Text(16), true
+}
+
+func (api *APIController) CaCertificateGet(ctx context.Context) (resp *entity.CaCertificateResponse, err error) {
+	_, span := tracing.Start(ctx, "api.CaCertificateGet")
+	defer func() { tracing.End(span, err) }()
+	if api.ca == nil {
+		err = ErrCaNotConfigured
+		return
+	}
+	resp = &entity.CaCertificateResponse{
+		Certificate: api.ca.certificatePEM(),
+		NotAfter:    api.ca.cert.NotAfter.Unix(),
+	}
+	return
+}
+
+// CaCrl returns DER encoded CRL of certificates revoked before they expired
+func (api *APIController) CaCrl(ctx context.Context) (crl []byte, err error) {
+	ctx, span := tracing.Start(ctx, "api.CaCrl")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.CaCrl",
+	})
+	if api.ca == nil {
+		err = ErrCaNotConfigured
+		return
+	}
+	items, err := api.access.IssuedCertificateRevokedList(ctx)
+	if err != nil {
+		eMsg := "error in access.IssuedCertificateRevokedList"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	crl, err = api.ca.crl(items)
+	if err != nil {
+		eMsg := "error in ca.crl"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	return
+}
+
+// CertificateIssue signs CSR of an enabled organization. The CSR must carry the registered key of the organization
+// and be signed by it, which proves possession of the key, so the request needs no admin.
+func (api *APIController) CertificateIssue(ctx context.Context, req *entity.CertificateIssueRequest) (resp *entity.IssuedCertificateResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.CertificateIssue")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.CertificateIssue",
+		"id":     req.Id,
+		"usage":  req.Usage,
+	})
+	if api.ca == nil {
+		err = ErrCaNotConfigured
+		return
+	}
+	v := &validator{}
+	if req.Usage == "" {
+		v.add("usage", FieldReasonRequired)
+	} else if !req.Usage.IsValid() {
+		v.add("usage", FieldReasonInvalidValue)
+	}
+	var csr *x509.CertificateRequest
+	if req.Csr == "" {
+		v.add("csr", FieldReasonRequired)
+	} else if parsed, ok := parseCertificateRequest(req.Csr); !ok {
+		v.add("csr", FieldReasonInvalidFormat)
+	} else if parsed.CheckSignature() != nil {
+		v.add("csr", FieldReasonInvalidValue)
+	} else {
+		csr = parsed
+	}
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid certificate request")
+		return
+	}
+	o, err := api.access.OrganizationById(ctx, req.Id)
+	if err != nil {
+		eMsg := "error in access.OrganizationById"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if o == nil || o.State != entity.EntityStateEnabled {
+		clog.Warn("organization not found")
+		err = ErrNotFound
+		return
+	}
+	fingerprint, err := publicKeyHash(csr.PublicKey)
+	if err != nil || fingerprint != o.Fingerprint {
+		clog.WithError(err).Warn("CSR key is not the organization key")
+		err = ErrValidation.WithDetails(FieldError{Field: "csr", Reason: FieldReasonKeyMismatch})
+		return
+	}
+	item, err := api.ca.issue(o, csr.PublicKey, req.Usage)
+	if err != nil {
+		eMsg := "error in ca.issue"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	err = api.access.IssuedCertificateAdd(ctx, nil, certificateRequestActor, item)
+	if err != nil {
+		eMsg := "error in access.IssuedCertificateAdd"
+		clog.WithError(err).Error(eMsg)
+		err = storeError(err)
+		return
+	}
+	clog.WithField("serial", item.Serial).Info("certificate issued")
+	resp = issuedCertificateResponse(item)
+	return
+}
+
+func (api *APIController) CertificateList(ctx context.Context, organizationId int) (resp []*entity.IssuedCertificateResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.CertificateList")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.CertificateList",
+		"id":     organizationId,
+	})
+	if _, err = api.organizationForChange(ctx, clog, organizationId); err != nil {
+		return
+	}
+	items, err := api.access.IssuedCertificateList(ctx, organizationId)
+	if err != nil {
+		eMsg := "error in access.IssuedCertificateList"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	resp = make([]*entity.IssuedCertificateResponse, 0, len(items))
+	for _, item := range items {
+		resp = append(resp, issuedCertificateResponse(item))
+	}
+	return
+}
+
+// CertificateRevoke puts issued certificate on the CRL, revocation cannot be undone
+func (api *APIController) CertificateRevoke(ctx context.Context, actor *entity.Admin, req *entity.CertificateRevokeRequest) (resp *entity.IssuedCertificateResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.CertificateRevoke")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.CertificateRevoke",
+		"actor":  actor.Username,
+		"serial": req.Serial,
+	})
+	serial, ok := normalizeSerial(req.Serial)
+	if !ok {
+		clog.Warn("invalid serial")
+		err = ErrValidation.WithDetails(FieldError{Field: "serial", Reason: FieldReasonInvalidFormat})
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = entity.RevocationReasonUnspecified
+	}
+	if !req.Reason.IsValid() {
+		clog.Warn("invalid revocation reason")
+		err = ErrValidation.WithDetails(FieldError{Field: "reason", Reason: FieldReasonInvalidValue})
+		return
+	}
+	item, err := api.access.IssuedCertificateBySerial(ctx, serial)
+	if err != nil {
+		eMsg := "error in access.IssuedCertificateBySerial"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if item == nil {
+		clog.Warn("certificate not found")
+		err = ErrNotFound
+		return
+	}
+	err = api.access.IssuedCertificateRevoke(ctx, nil, actor.Username, item, req.Reason)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNoRowsAffected) {
+			clog.Warn("certificate already revoked")
+			err = ErrConflict.WithAppCode(AppCodeCertificateRevoked)
+			return
+		}
+		eMsg := "error in access.IssuedCertificateRevoke"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	resp = issuedCertificateResponse(item)
+	return
+}
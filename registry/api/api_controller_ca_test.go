@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+const testCrlUrl = "https://registry.example.com/api/v1/ca/crl"
+
+func csrPEM(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "ignored"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+// newTestIssuer returns controller issuing certificates with a new CA, and organization enabled with key
+func newTestIssuer(t *testing.T, key crypto.Signer) (api *APIController, ca *testCA, o *entity.OrganizationResponse) {
+	t.Helper()
+	api = newTestController()
+	ca = newTestCA(t, "registry CA", nil)
+	authority, err := NewCertificateAuthority(ca.cert, ca.key, time.Hour, testCrlUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetCertificateAuthority(authority)
+	o, err = api.OrganizationAdd(context.Background(), testAdmin, addRequest(t, api, "Edara 1", key))
+	checkError(t, err, nil)
+	return
+}
+
+func TestCertificateIssue(t *testing.T) {
+	ctx := context.Background()
+	key := newRsaKey(t)
+	api, ca, o := newTestIssuer(t, key)
+	csr := csrPEM(t, key)
+
+	_, err := newTestController().CertificateIssue(ctx, &entity.CertificateIssueRequest{Id: o.Id, Csr: csr, Usage: entity.CertificateUsageClient})
+	checkError(t, err, ErrCaNotConfigured)
+
+	failures := []struct {
+		name   string
+		req    *entity.CertificateIssueRequest
+		want   error
+		field  string
+		reason string
+	}{
+		{"no usage", &entity.CertificateIssueRequest{Id: o.Id, Csr: csr}, ErrValidation, "usage", FieldReasonRequired},
+		{"unknown usage", &entity.CertificateIssueRequest{Id: o.Id, Csr: csr, Usage: "CODE_SIGNING"}, ErrValidation, "usage", FieldReasonInvalidValue},
+		{"no csr", &entity.CertificateIssueRequest{Id: o.Id, Usage: entity.CertificateUsageClient}, ErrValidation, "csr", FieldReasonRequired},
+		{"not a csr", &entity.CertificateIssueRequest{Id: o.Id, Csr: "not a csr", Usage: entity.CertificateUsageClient}, ErrValidation, "csr", FieldReasonInvalidFormat},
+		{"csr for another key", &entity.CertificateIssueRequest{Id: o.Id, Csr: csrPEM(t, newRsaKey(t)), Usage: entity.CertificateUsageClient}, ErrValidation, "csr", FieldReasonKeyMismatch},
+		{"unknown organization", &entity.CertificateIssueRequest{Id: 99, Csr: csr, Usage: entity.CertificateUsageClient}, ErrNotFound, "", ""},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := api.CertificateIssue(ctx, tt.req)
+			checkError(t, err, tt.want)
+			if tt.field != "" {
+				checkDetail(t, err, tt.field, tt.reason)
+			}
+		})
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	for _, usage := range []entity.CertificateUsage{entity.CertificateUsageClient, entity.CertificateUsageServer} {
+		t.Run(string(usage), func(t *testing.T) {
+			resp, err := api.CertificateIssue(ctx, &entity.CertificateIssueRequest{Id: o.Id, Csr: csr, Usage: usage})
+			checkError(t, err, nil)
+			block, _ := pem.Decode([]byte(resp.Certificate))
+			if block == nil {
+				t.Fatalf("certificate is not PEM: %q", resp.Certificate)
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			extKeyUsage := x509.ExtKeyUsageClientAuth
+			if usage == entity.CertificateUsageServer {
+				extKeyUsage = x509.ExtKeyUsageServerAuth
+			}
+			opts := x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{extKeyUsage}}
+			if usage == entity.CertificateUsageServer {
+				opts.DNSName = "edara-1.example.com"
+			}
+			if _, err = cert.Verify(opts); err != nil {
+				t.Errorf("issued certificate does not verify: %v", err)
+			}
+			if cert.Subject.CommonName != o.Name {
+				t.Errorf("issued to %q", cert.Subject.CommonName)
+			}
+			if len(cert.CRLDistributionPoints) != 1 || cert.CRLDistributionPoints[0] != testCrlUrl {
+				t.Errorf("CRL distribution points %v", cert.CRLDistributionPoints)
+			}
+			if cert.SerialNumber.Text(16) != resp.Serial || resp.KeyFingerprint != o.KeyFingerprint {
+				t.Errorf("response serial %s, fingerprint %s", resp.Serial, resp.KeyFingerprint)
+			}
+		})
+	}
+	items, err := api.CertificateList(ctx, o.Id)
+	checkError(t, err, nil)
+	if len(items) != 2 {
+		t.Errorf("%d certificates listed, want 2", len(items))
+	}
+}
+
+func TestCertificateRevoke(t *testing.T) {
+	ctx := context.Background()
+	key := newRsaKey(t)
+	api, ca, o := newTestIssuer(t, key)
+	issued, err := api.CertificateIssue(ctx, &entity.CertificateIssueRequest{Id: o.Id, Csr: csrPEM(t, key), Usage: entity.CertificateUsageClient})
+	checkError(t, err, nil)
+
+	_, err = api.CertificateRevoke(ctx, testAdmin, &entity.CertificateRevokeRequest{Serial: "not hex"})
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "serial", FieldReasonInvalidFormat)
+	_, err = api.CertificateRevoke(ctx, testAdmin, &entity.CertificateRevokeRequest{Serial: issued.Serial, Reason: "forgotten"})
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "reason", FieldReasonInvalidValue)
+	_, err = api.CertificateRevoke(ctx, testAdmin, &entity.CertificateRevokeRequest{Serial: "ffff"})
+	checkError(t, err, ErrNotFound)
+
+	// serial is matched in any case and with leading zeros
+	resp, err := api.CertificateRevoke(ctx, testAdmin, &entity.CertificateRevokeRequest{Serial: "00" + strings.ToUpper(issued.Serial), Reason: entity.RevocationReasonKeyCompromise})
+	checkError(t, err, nil)
+	if resp.RevokedTs == nil || resp.RevocationReason != entity.RevocationReasonKeyCompromise {
+		t.Errorf("revoked at %v for %s", resp.RevokedTs, resp.RevocationReason)
+	}
+	_, err = api.CertificateRevoke(ctx, testAdmin, &entity.CertificateRevokeRequest{Serial: issued.Serial})
+	checkError(t, err, ErrConflict.WithAppCode(AppCodeCertificateRevoked))
+
+	der, err := api.CaCrl(ctx)
+	checkError(t, err, nil)
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = crl.CheckSignatureFrom(ca.cert); err != nil {
+		t.Errorf("CRL is not signed by CA: %v", err)
+	}
+	if len(crl.RevokedCertificates) != 1 {
+		t.Fatalf("CRL lists %d certificates, want 1", len(crl.RevokedCertificates))
+	}
+	entry := crl.RevokedCertificates[0]
+	serial, _ := new(big.Int).SetString(issued.Serial, 16)
+	var reason asn1.Enumerated
+	if len(entry.Extensions) == 1 {
+		_, _ = asn1.Unmarshal(entry.Extensions[0].Value, &reason)
+	}
+	if entry.SerialNumber.Cmp(serial) != 0 || int(reason) != entity.RevocationReasonKeyCompromise.Code() {
+		t.Errorf("CRL lists %s with reason %d", entry.SerialNumber.Text(16), reason)
+	}
+}
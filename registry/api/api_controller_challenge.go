@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/signing"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const keyChallengeTtl = 10 * time.Minute
+
+// KeyChallengeCreate issues nonce for public key. Applicant proves possession of the private key by signing
+// the nonce string with the scheme of the key algorithm, the same scheme X-Signature uses.
+func (api *APIController) KeyChallengeCreate(ctx context.Context, req *entity.KeyChallengeRequest) (resp *entity.KeyChallengeResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.KeyChallengeCreate")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.KeyChallengeCreate",
+	})
+	v := &validator{}
+	v.publicKey("public_key", req.PublicKey)
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid public key")
+		return
+	}
+	key, _ := parsePublicKey(req.PublicKey)
+	keyHash, err := publicKeyHash(key)
+	if err != nil {
+		eMsg := "error in publicKeyHash"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		eMsg := "error in randomToken"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	var item *entity.KeyChallenge
+	item, err = api.access.KeyChallengeAdd(ctx, nil, nonce, keyHash, time.Now().Add(keyChallengeTtl))
+	if err != nil {
+		eMsg := "error in access.KeyChallengeAdd"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	resp = &entity.KeyChallengeResponse{
+		Id:       item.Id,
+		Nonce:    item.Nonce,
+		ExpireTs: item.ExpireTs.Unix(),
+	}
+	return
+}
+
+// verifyKeyProof checks that signature is made over challenge nonce by private key of publicKey
+// and uses the challenge up in tx, so that it stays usable when the key change in tx fails.
+// publicKey must be already validated.
+func (api *APIController) verifyKeyProof(ctx context.Context, tx pgx.Tx, clog *log.Entry, publicKey string, challengeId int, signature string) (err error) {
+	ctx, span := tracing.Start(ctx, "api.verifyKeyProof")
+	defer func() { tracing.End(span, err) }()
+	clog = clog.WithField("challenge-id", challengeId)
+	v := &validator{}
+	if challengeId <= 0 {
+		v.add("challenge_id", FieldReasonRequired)
+	}
+	if signature == "" {
+		v.add("signature", FieldReasonRequired)
+	}
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("proof of possession is missing")
+		return
+	}
+	key, _ := parsePublicKey(publicKey)
+	keyHash, err := publicKeyHash(key)
+	if err != nil {
+		eMsg := "error in publicKeyHash"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	var item *entity.KeyChallenge
+	item, err = api.access.KeyChallengeById(ctx, challengeId)
+	if err != nil {
+		eMsg := "error in access.KeyChallengeById"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	// missing, used, expired and issued for another key are not told apart
+	if item == nil || item.UsedTs != nil || time.Now().After(item.ExpireTs) || item.KeyHash != keyHash {
+		clog.Warn("challenge is not usable for the key")
+		err = ErrValidation.WithDetails(FieldError{Field: "challenge_id", Reason: FieldReasonInvalidValue})
+		return
+	}
+	alg, algErr := signing.Algorithm(key)
+	sig, decodeErr := base64.StdEncoding.DecodeString(signature)
+	if algErr != nil || decodeErr != nil {
+		clog.Warn("signature cannot be checked")
+		err = ErrValidation.WithDetails(FieldError{Field: "signature", Reason: FieldReasonInvalidFormat})
+		return
+	}
+	if err = signing.Verify(alg, key, []byte(item.Nonce), sig); err != nil {
+		clog.WithError(err).Warn("signature does not match")
+		err = ErrValidation.WithDetails(FieldError{Field: "signature", Reason: FieldReasonInvalidValue})
+		return
+	}
+	err = api.access.KeyChallengeUse(ctx, tx, item)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNoRowsAffected) {
+			clog.Warn("challenge was used concurrently")
+			err = ErrValidation.WithDetails(FieldError{Field: "challenge_id", Reason: FieldReasonInvalidValue})
+			return
+		}
+		eMsg := "error in access.KeyChallengeUse"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	clog.Info("proof of possession accepted")
+	return
+}
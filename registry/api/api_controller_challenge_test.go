@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func TestKeyProof(t *testing.T) {
+	ctx := context.Background()
+	api := newTestController()
+	key, otherKey := newRsaKey(t), newRsaKey(t)
+
+	_, err := api.KeyChallengeCreate(ctx, &entity.KeyChallengeRequest{PublicKey: "not a key"})
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "public_key", FieldReasonInvalidFormat)
+
+	used := addRequest(t, api, "Edara 1", key)
+	_, err = api.OrganizationAdd(ctx, testAdmin, used)
+	checkError(t, err, nil)
+	otherChallengeId, _ := proof(t, api, publicKeyPEM(t, otherKey), otherKey)
+	_, otherNonceSignature := proof(t, api, used.PublicKey, key)
+
+	tests := []struct {
+		name   string
+		change func(req *entity.OrganizationAddRequest)
+		field  string
+		reason string
+	}{
+		{"no challenge", func(req *entity.OrganizationAddRequest) { req.ChallengeId = 0 }, "challenge_id", FieldReasonRequired},
+		{"no signature", func(req *entity.OrganizationAddRequest) { req.Signature = "" }, "signature", FieldReasonRequired},
+		{"unknown challenge", func(req *entity.OrganizationAddRequest) { req.ChallengeId = 99 }, "challenge_id", FieldReasonInvalidValue},
+		{"used challenge", func(req *entity.OrganizationAddRequest) {
+			req.ChallengeId, req.Signature = used.ChallengeId, used.Signature
+		}, "challenge_id", FieldReasonInvalidValue},
+		{"challenge of another key", func(req *entity.OrganizationAddRequest) { req.ChallengeId = otherChallengeId }, "challenge_id", FieldReasonInvalidValue},
+		{"signed by another key", func(req *entity.OrganizationAddRequest) {
+			req.ChallengeId, req.Signature = proof(t, api, req.PublicKey, otherKey)
+		}, "signature", FieldReasonInvalidValue},
+		{"signature of another nonce", func(req *entity.OrganizationAddRequest) { req.Signature = otherNonceSignature }, "signature", FieldReasonInvalidValue},
+		{"signature not base64", func(req *entity.OrganizationAddRequest) { req.Signature = "not base64!" }, "signature", FieldReasonInvalidFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := addRequest(t, api, "Edara 2", key)
+			tt.change(req)
+			_, err := api.OrganizationAdd(ctx, testAdmin, req)
+			checkError(t, err, ErrValidation)
+			checkDetail(t, err, tt.field, tt.reason)
+		})
+	}
+
+	newKey := newRsaKey(t)
+	_, err = api.OrganizationKeyChange(ctx, testAdmin, &entity.OrganizationKeyChangeRequest{Id: 1, PublicKey: "not a key"})
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "public_key", FieldReasonInvalidFormat)
+	change := &entity.OrganizationKeyChangeRequest{Id: 1, PublicKey: publicKeyPEM(t, newKey)}
+	change.ChallengeId, change.Signature = proof(t, api, change.PublicKey, key)
+	_, err = api.OrganizationKeyChange(ctx, testAdmin, change)
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "signature", FieldReasonInvalidValue)
+	change.ChallengeId, change.Signature = proof(t, api, change.PublicKey, newKey)
+	_, err = api.OrganizationKeyChange(ctx, testAdmin, change)
+	checkError(t, err, nil)
+}
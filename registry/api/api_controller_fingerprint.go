@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const keyFingerprintLen = 64
+
+// normalizeFingerprint accepts hex SHA-256 in either case, optionally with colons between bytes
+func normalizeFingerprint(fingerprint string) (normalized string, ok bool) {
+	normalized = strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	if len(normalized) != keyFingerprintLen {
+		return "", false
+	}
+	for _, c := range normalized {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", false
+		}
+	}
+	return normalized, true
+}
+
+func keyStatus(item *entity.Organization) entity.KeyStatus {
+	switch item.State {
+	case entity.EntityStateEnabled:
+		if item.CertExpireTs != nil && time.Now().After(*item.CertExpireTs) {
+			return entity.KeyStatusExpired
+		}
+		return entity.KeyStatusActive
+	case entity.EntityStatePending:
+		return entity.KeyStatusPending
+	}
+	return entity.KeyStatusInactive
+}
+
+// OrganizationKeyByFingerprint resolves SHA-256 SPKI fingerprint to the organization holding the key in any state,
+// so that keys of deleted organizations found in old logs and signatures are resolved as well
+func (api *APIController) OrganizationKeyByFingerprint(ctx context.Context, fingerprint string) (resp *entity.KeyFingerprintResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationKeyByFingerprint")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method":      "api.OrganizationKeyByFingerprint",
+		"fingerprint": fingerprint,
+	})
+	normalized, ok := normalizeFingerprint(fingerprint)
+	if !ok {
+		clog.Warn("invalid fingerprint")
+		err = ErrValidation.WithDetails(FieldError{Field: "fingerprint", Reason: FieldReasonInvalidFormat})
+		return
+	}
+	var item *entity.Organization
+	item, err = api.access.OrganizationByKeyFingerprint(ctx, normalized)
+	if err != nil {
+		eMsg := "error in access.OrganizationByKeyFingerprint"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if item == nil {
+		clog.Warn("key not found")
+		err = ErrNotFound
+		return
+	}
+	resp = &entity.KeyFingerprintResponse{
+		Fingerprint:       item.Fingerprint,
+		KeyStatus:         keyStatus(item),
+		KeyAlgorithm:      item.Algorithm,
+		PublicKey:         item.PublicKey,
+		CertExpireTs:      unixOrNil(item.CertExpireTs),
+		OrganizationId:    item.Id,
+		OrganizationName:  item.Name,
+		OrganizationState: item.State,
+	}
+	return
+}
+
+// OrganizationFingerprintBackfill computes fingerprints of keys stored before they were recorded,
+// keys changed meanwhile already have one and are skipped
+func (api *APIController) OrganizationFingerprintBackfill(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationFingerprintBackfill")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationFingerprintBackfill",
+	})
+	var items []*entity.Organization
+	items, err = api.access.OrganizationNoFingerprintList(ctx)
+	if err != nil {
+		eMsg := "error in access.OrganizationNoFingerprintList"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	count := 0
+	for _, item := range items {
+		ilog := clog.WithField("id", item.Id)
+		key, ok := parsePublicKey(item.PublicKey)
+		if !ok {
+			ilog.Warn("stored public key cannot be parsed")
+			continue
+		}
+		var fingerprint string
+		fingerprint, err = publicKeyHash(key)
+		if err != nil {
+			ilog.WithError(err).Warn("error in publicKeyHash")
+			err = nil
+			continue
+		}
+		err = api.access.OrganizationFingerprintSet(ctx, nil, item, fingerprint)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNoRowsAffected) {
+				err = nil
+				continue
+			}
+			eMsg := "error in access.OrganizationFingerprintSet"
+			ilog.WithError(err).Error(eMsg)
+			err = ErrInternalServerError
+			return
+		}
+		count++
+	}
+	if count > 0 {
+		clog.WithField("count", count).Info("key fingerprints computed")
+	}
+	return
+}
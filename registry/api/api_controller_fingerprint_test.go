@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func TestNormalizeFingerprint(t *testing.T) {
+	sum := sha256.Sum256([]byte("key"))
+	hexSum := hex.EncodeToString(sum[:])
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	tests := []struct {
+		name        string
+		fingerprint string
+		ok          bool
+	}{
+		{"lower case", hexSum, true},
+		{"upper case", strings.ToUpper(hexSum), true},
+		{"openssl", strings.Join(parts, ":"), true},
+		{"empty", "", false},
+		{"too short", hexSum[:62], false},
+		{"too long", hexSum + "00", false},
+		{"not hex", "g" + hexSum[1:], false},
+		{"not a fingerprint", "not-a-fingerprint", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normalizeFingerprint(tt.fingerprint)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != hexSum {
+				t.Errorf("normalized to %s, want %s", got, hexSum)
+			}
+		})
+	}
+}
+
+func TestOrganizationKeyByFingerprint(t *testing.T) {
+	ctx := context.Background()
+	api := newTestController()
+	oldKey, key := newRsaKey(t), newRsaKey(t)
+	fingerprint := func(publicKey string) string {
+		parsed, _ := parsePublicKey(publicKey)
+		hash, err := publicKeyHash(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	o, err := api.OrganizationAdd(ctx, testAdmin, addRequest(t, api, "Edara 1", oldKey))
+	checkError(t, err, nil)
+	change := &entity.OrganizationKeyChangeRequest{Id: o.Id, PublicKey: publicKeyPEM(t, key)}
+	change.ChallengeId, change.Signature = proof(t, api, change.PublicKey, key)
+	_, err = api.OrganizationKeyChange(ctx, testAdmin, change)
+	checkError(t, err, nil)
+
+	_, err = api.OrganizationKeyByFingerprint(ctx, "not-a-fingerprint")
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "fingerprint", FieldReasonInvalidFormat)
+	_, err = api.OrganizationKeyByFingerprint(ctx, fingerprint(publicKeyPEM(t, oldKey)))
+	checkError(t, err, ErrNotFound)
+
+	want := fingerprint(change.PublicKey)
+	for _, state := range []entity.EntityState{entity.EntityStateEnabled, entity.EntityStateDisabled, entity.EntityStateDeleted} {
+		if state != entity.EntityStateEnabled {
+			_, err = api.OrganizationChangeState(ctx, testAdmin, &entity.OrganizationChangeStateRequest{Id: o.Id, State: state})
+			checkError(t, err, nil)
+		}
+		resp, err := api.OrganizationKeyByFingerprint(ctx, strings.ToUpper(want))
+		checkError(t, err, nil)
+		wantStatus := entity.KeyStatusInactive
+		if state == entity.EntityStateEnabled {
+			wantStatus = entity.KeyStatusActive
+		}
+		if resp.Fingerprint != want || resp.OrganizationId != o.Id || resp.OrganizationState != state || resp.KeyStatus != wantStatus {
+			t.Errorf("%s organization resolved as %+v", state, resp)
+		}
+	}
+
+	reg, err := api.OrganizationRegister(ctx, registrationRequest(t, api, "Edara 2"))
+	checkError(t, err, nil)
+	resp, err := api.OrganizationKeyByFingerprint(ctx, reg.KeyFingerprint)
+	checkError(t, err, nil)
+	if resp.KeyStatus != entity.KeyStatusPending {
+		t.Errorf("key of pending registration is %s", resp.KeyStatus)
+	}
+}
+
+func TestOrganizationFingerprintBackfill(t *testing.T) {
+	ctx := context.Background()
+	api := newTestController()
+	key := newRsaKey(t)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(der)
+	stored := entity.OrganizationKey{PublicKey: publicKeyPEM(t, key), Algorithm: entity.KeyAlgorithmRsaPkcs1Sha256}
+	o, err := api.access.OrganizationAdd(ctx, nil, testAdmin.Username, "Edara 1", "Edara 1", entity.SRD, "https://edara-1.example.com/receive", stored)
+	checkError(t, err, nil)
+	_, err = api.OrganizationKeyByFingerprint(ctx, hex.EncodeToString(sum[:]))
+	checkError(t, err, ErrNotFound)
+
+	checkError(t, api.OrganizationFingerprintBackfill(ctx), nil)
+	resp, err := api.OrganizationKeyByFingerprint(ctx, hex.EncodeToString(sum[:]))
+	checkError(t, err, nil)
+	if resp.OrganizationId != o.Id {
+		t.Errorf("fingerprint resolved to organization %d, want %d", resp.OrganizationId, o.Id)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+var ErrSigningNotConfigured = ErrNotFound.WithAppCode(AppCodeSigningNotConfigured)
+
+func (api *APIController) SigningKeyGet(ctx context.Context) (resp *entity.SigningKeyResponse, err error) {
+	_, span := tracing.Start(ctx, "api.SigningKeyGet")
+	defer func() { tracing.End(span, err) }()
+	if api.signer == nil {
+		err = ErrSigningNotConfigured
+		return
+	}
+	resp = api.signer.key
+	return
+}
+
+// KeyValidityCheck tells whether key with fingerprint is good for organization right now, the way OCSP does
+// for certificates. Unknown organizations and keys are answered with UNKNOWN rather than 404, so that every answer
+// is signed. The answer is valid for maxAge.
+func (api *APIController) KeyValidityCheck(ctx context.Context, organizationId int, fingerprint string, maxAge time.Duration) (resp *entity.KeyValidityResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.KeyValidityCheck")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method":      "api.KeyValidityCheck",
+		"id":          organizationId,
+		"fingerprint": fingerprint,
+	})
+	if api.signer == nil {
+		err = ErrSigningNotConfigured
+		return
+	}
+	normalized, ok := normalizeFingerprint(fingerprint)
+	if !ok {
+		clog.Warn("invalid fingerprint")
+		err = ErrValidation.WithDetails(FieldError{Field: "fingerprint", Reason: FieldReasonInvalidFormat})
+		return
+	}
+	o, err := api.access.OrganizationById(ctx, organizationId)
+	if err != nil {
+		eMsg := "error in access.OrganizationById"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	now := time.Now().UTC()
+	resp = &entity.KeyValidityResponse{
+		OrganizationId: organizationId,
+		KeyFingerprint: normalized,
+		Status:         entity.KeyValidityUnknown,
+		ThisUpdate:     now.Unix(),
+		NextUpdate:     now.Add(maxAge).Unix(),
+	}
+	current := o != nil && o.Fingerprint == normalized
+	if current {
+		resp.KeyAlgorithm = o.Algorithm
+		resp.KeyNotAfter = unixOrNil(o.CertExpireTs)
+		switch o.State {
+		case entity.EntityStateEnabled:
+			// expired certificate is not revoked, its key is answered as unknown the way OCSP responders may do
+			if o.CertExpireTs == nil || now.Before(*o.CertExpireTs) {
+				resp.Status = entity.KeyValidityGood
+			}
+			return
+		case entity.EntityStatePending, entity.EntityStateRejected:
+			return
+		}
+	}
+	var revoked *entity.RevokedKey
+	revoked, err = api.access.RevokedKeyByFingerprint(ctx, organizationId, normalized)
+	if err != nil {
+		eMsg := "error in access.RevokedKeyByFingerprint"
+		clog.WithError(err).Error(eMsg)
+		resp = nil
+		err = ErrInternalServerError
+		return
+	}
+	switch {
+	case revoked != nil:
+		resp.Status = entity.KeyValidityRevoked
+		resp.RevokedTs = unixOrNil(&revoked.RevokedTs)
+		resp.RevocationReason = revoked.RevocationReason
+	case current:
+		// disabled before revoked keys were recorded
+		resp.Status = entity.KeyValidityRevoked
+		resp.RevokedTs = unixOrNil(&o.UpdateTs)
+		resp.RevocationReason = entity.RevocationReasonCessationOfOperation
+	}
+	return
+}
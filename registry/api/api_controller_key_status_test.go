@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func TestKeyValidityCheck(t *testing.T) {
+	ctx := context.Background()
+	const maxAge = 5 * time.Minute
+	api := newTestController()
+	_, err := api.KeyValidityCheck(ctx, 1, strings.Repeat("0", keyFingerprintLen), maxAge)
+	checkError(t, err, ErrSigningNotConfigured)
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewResponseSigner(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetResponseSigner(signer)
+
+	replaced, err := api.OrganizationAdd(ctx, testAdmin, addRequest(t, api, "Edara 1", newRsaKey(t)))
+	checkError(t, err, nil)
+	key := newRsaKey(t)
+	change := &entity.OrganizationKeyChangeRequest{Id: replaced.Id, PublicKey: publicKeyPEM(t, key)}
+	change.ChallengeId, change.Signature = proof(t, api, change.PublicKey, key)
+	current, err := api.OrganizationKeyChange(ctx, testAdmin, change)
+	checkError(t, err, nil)
+	disabled, err := api.OrganizationAdd(ctx, testAdmin, addRequest(t, api, "Edara 2", newRsaKey(t)))
+	checkError(t, err, nil)
+	_, err = api.OrganizationChangeState(ctx, testAdmin, &entity.OrganizationChangeStateRequest{Id: disabled.Id, State: entity.EntityStateDisabled})
+	checkError(t, err, nil)
+	pending, err := api.OrganizationRegister(ctx, registrationRequest(t, api, "Edara 3"))
+	checkError(t, err, nil)
+
+	tests := []struct {
+		name           string
+		organizationId int
+		fingerprint    string
+		status         entity.KeyValidity
+		reason         entity.RevocationReason
+	}{
+		{"current key", current.Id, current.KeyFingerprint, entity.KeyValidityGood, ""},
+		{"current key upper case", current.Id, strings.ToUpper(current.KeyFingerprint), entity.KeyValidityGood, ""},
+		{"replaced key", current.Id, replaced.KeyFingerprint, entity.KeyValidityRevoked, entity.RevocationReasonSuperseded},
+		{"key of another organization", disabled.Id, current.KeyFingerprint, entity.KeyValidityUnknown, ""},
+		{"disabled organization", disabled.Id, disabled.KeyFingerprint, entity.KeyValidityRevoked, entity.RevocationReasonCessationOfOperation},
+		{"pending registration", pending.Id, pending.KeyFingerprint, entity.KeyValidityUnknown, ""},
+		{"unknown organization", 99, current.KeyFingerprint, entity.KeyValidityUnknown, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := api.KeyValidityCheck(ctx, tt.organizationId, tt.fingerprint, maxAge)
+			checkError(t, err, nil)
+			if resp.Status != tt.status || resp.RevocationReason != tt.reason {
+				t.Errorf("status %s for %q, want %s for %q", resp.Status, resp.RevocationReason, tt.status, tt.reason)
+			}
+			if (resp.RevokedTs != nil) != (tt.status == entity.KeyValidityRevoked) {
+				t.Errorf("RevokedTs = %v", resp.RevokedTs)
+			}
+			if resp.KeyFingerprint != strings.ToLower(tt.fingerprint) || resp.NextUpdate-resp.ThisUpdate != int64(maxAge/time.Second) {
+				t.Errorf("answered for %s from %d to %d", resp.KeyFingerprint, resp.ThisUpdate, resp.NextUpdate)
+			}
+		})
+	}
+
+	_, err = api.KeyValidityCheck(ctx, current.Id, "not-a-fingerprint", maxAge)
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "fingerprint", FieldReasonInvalidFormat)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/client"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/snapshot"
+	"ykjam/doc-registry-go/tracing"
+)
+
+var ErrMirrorNotConfigured = ErrNotFound.WithAppCode(AppCodeMirrorNotConfigured)
+
+// MirrorSyncLoop syncs right away and then every interval until ctx is done
+func (api *APIController) MirrorSyncLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_ = api.MirrorSync(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MirrorSync replaces served organizations with those upstream lists now. Every organization is fetched on its own,
+// since the list leaves out state, certificate and timestamps. Nothing is replaced when any fetch fails, so that
+// the mirror never serves a partial directory.
+func (api *APIController) MirrorSync(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "api.MirrorSync")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method":   "api.MirrorSync",
+		"upstream": api.mirror.upstreamUrl,
+	})
+	start := time.Now().UTC()
+	var items []*entity.OrganizationResponse
+	defer func() { api.mirror.record(start, len(items), err) }()
+	list, err := api.mirror.upstream.OrganizationList(ctx)
+	if err != nil {
+		eMsg := "error in upstream.OrganizationList"
+		clog.WithError(err).Error(eMsg)
+		return
+	}
+	items = make([]*entity.OrganizationResponse, 0, len(list))
+	for _, o := range list {
+		var item *entity.OrganizationResponse
+		item, err = api.mirror.upstream.Organization(ctx, o.Id)
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			// deleted after the list was taken
+			err = nil
+			continue
+		}
+		if err != nil {
+			eMsg := "error in upstream.Organization"
+			clog.WithError(err).WithField("id", o.Id).Error(eMsg)
+			items = nil
+			return
+		}
+		items = append(items, item)
+	}
+	api.mirror.target.Replace(snapshot.Organizations(items))
+	api.changes.notify()
+	clog.WithFields(log.Fields{
+		"organizations": len(items),
+		"duration":      time.Since(start),
+	}).Debug("mirror synced")
+	return
+}
+
+// MirrorStatus tells when mirror last synced and how old the data it serves is
+func (api *APIController) MirrorStatus(ctx context.Context) (resp *entity.MirrorStatusResponse, err error) {
+	_, span := tracing.Start(ctx, "api.MirrorStatus")
+	defer func() { tracing.End(span, err) }()
+	if api.mirror == nil {
+		err = ErrMirrorNotConfigured
+		return
+	}
+	m := api.mirror
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resp = &entity.MirrorStatusResponse{
+		UpstreamUrl:       m.upstreamUrl,
+		LastSyncTs:        unixOrNil(m.lastSyncTs),
+		LastAttemptTs:     unixOrNil(m.lastAttemptTs),
+		LastError:         m.lastError,
+		OrganizationCount: m.count,
+	}
+	if m.lastSyncTs != nil {
+		lag := int64(time.Since(*m.lastSyncTs).Seconds())
+		resp.LagSeconds = &lag
+	}
+	return
+}
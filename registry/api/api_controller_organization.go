@@ -2,14 +2,20 @@ package api
 
 import (
 	"context"
+	"crypto/x509"
 
+	"github.com/jackc/pgx/v4"
 	log "github.com/sirupsen/logrus"
 
 	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
 )
 
 func (api *APIController) OrganizationList(ctx context.Context) (items []*entity.OrganizationListResponse, err error) {
-	clog := log.WithFields(log.Fields{
+	ctx, span := tracing.Start(ctx, "api.OrganizationList")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
 		"method": "api.OrganizationList",
 	})
 	items = make([]*entity.OrganizationListResponse, 0)
@@ -23,14 +29,297 @@ func (api *APIController) OrganizationList(ctx context.Context) (items []*entity
 	}
 	for _, organization := range organizations {
 		item := &entity.OrganizationListResponse{
-			Id:        organization.Id,
-			Name:      organization.Name,
-			Label:     organization.Label,
-			Type:      organization.Type,
-			Url:       organization.Url,
-			PublicKey: organization.PublicKey,
+			Id:             organization.Id,
+			Name:           organization.Name,
+			Label:          organization.Label,
+			Type:           organization.Type,
+			Url:            organization.Url,
+			PublicKey:      organization.PublicKey,
+			KeyAlgorithm:   organization.Algorithm,
+			KeyFingerprint: organization.Fingerprint,
+			CertExpireTs:   unixOrNil(organization.CertExpireTs),
 		}
 		items = append(items, item)
 	}
 	return
 }
+
+// OrganizationDirectory returns organizations the public list shows, with state, certificate and timestamps
+func (api *APIController) OrganizationDirectory(ctx context.Context) (items []*entity.OrganizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationDirectory")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationDirectory",
+	})
+	var organizations []*entity.Organization
+	organizations, err = api.access.OrganizationList(ctx)
+	if err != nil {
+		eMsg := "error in access.OrganizationList"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	items = make([]*entity.OrganizationResponse, 0, len(organizations))
+	for _, organization := range organizations {
+		items = append(items, organizationResponse(organization))
+	}
+	return
+}
+
+func organizationResponse(o *entity.Organization) *entity.OrganizationResponse {
+	return &entity.OrganizationResponse{
+		Id:             o.Id,
+		Name:           o.Name,
+		Label:          o.Label,
+		Type:           o.Type,
+		Url:            o.Url,
+		PublicKey:      o.PublicKey,
+		KeyAlgorithm:   o.Algorithm,
+		KeyFingerprint: o.Fingerprint,
+		Certificate:    o.Certificate,
+		CertExpireTs:   unixOrNil(o.CertExpireTs),
+		State:          o.State,
+		CreateTs:       o.CreateTs.Unix(),
+		UpdateTs:       o.UpdateTs.Unix(),
+	}
+}
+
+// organizationForChange loads organization by id, returns ErrNotFound if it is missing, deleted or not approved
+func (api *APIController) organizationForChange(ctx context.Context, clog *log.Entry, id int) (item *entity.Organization, err error) {
+	ctx, span := tracing.Start(ctx, "api.organizationForChange")
+	defer func() { tracing.End(span, err) }()
+	item, err = api.access.OrganizationById(ctx, id)
+	if err != nil {
+		eMsg := "error in access.OrganizationById"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if item == nil || item.State == entity.EntityStatePending || item.State == entity.EntityStateRejected {
+		clog.WithField("id", id).Warn("organization not found")
+		item = nil
+		err = ErrNotFound
+		return
+	}
+	return
+}
+
+func (api *APIController) OrganizationAdd(ctx context.Context, actor *entity.Admin, req *entity.OrganizationAddRequest) (resp *entity.OrganizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationAdd")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationAdd",
+		"actor":  actor.Username,
+	})
+	v := &validator{}
+	v.organizationFields(req.Name, req.Label, req.Type, req.Url)
+	key := api.organizationKey(v, req.Name, req.PublicKey, req.Certificate)
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid organization data")
+		return
+	}
+	var item *entity.Organization
+	err = api.inTx(ctx, clog, func(tx pgx.Tx) (err error) {
+		if err = api.verifyKeyProof(ctx, tx, clog, key.PublicKey, req.ChallengeId, req.Signature); err != nil {
+			return
+		}
+		item, err = api.access.OrganizationAdd(ctx, tx, actor.Username, req.Name, req.Label, req.Type, req.Url, key)
+		if err != nil {
+			eMsg := "error in access.OrganizationAdd"
+			clog.WithError(err).Error(eMsg)
+			err = storeError(err)
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	api.changes.notify()
+	resp = organizationResponse(item)
+	return
+}
+
+func (api *APIController) OrganizationUpdate(ctx context.Context, actor *entity.Admin, req *entity.OrganizationUpdateRequest) (resp *entity.OrganizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationUpdate")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationUpdate",
+		"actor":  actor.Username,
+	})
+	v := &validator{}
+	v.organizationFields(req.Name, req.Label, req.Type, req.Url)
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid organization data")
+		return
+	}
+	var item *entity.Organization
+	item, err = api.organizationForChange(ctx, clog, req.Id)
+	if err != nil {
+		return
+	}
+	// certificate subject names the organization, renaming needs a new certificate first
+	if item.Certificate != "" && req.Name != item.Name {
+		clog.Warn("rename would not match certificate subject")
+		err = ErrValidation.WithDetails(FieldError{Field: "name", Reason: FieldReasonSubjectMismatch})
+		return
+	}
+	err = api.access.OrganizationUpdate(ctx, nil, actor.Username, item, req.Name, req.Label, req.Type, req.Url, item.OrganizationKey)
+	if err != nil {
+		eMsg := "error in access.OrganizationUpdate"
+		clog.WithError(err).Error(eMsg)
+		err = storeError(err)
+		return
+	}
+	api.changes.notify()
+	resp = organizationResponse(item)
+	return
+}
+
+func (api *APIController) OrganizationKeyChange(ctx context.Context, actor *entity.Admin, req *entity.OrganizationKeyChangeRequest) (resp *entity.OrganizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationKeyChange")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationKeyChange",
+		"actor":  actor.Username,
+	})
+	var item *entity.Organization
+	item, err = api.organizationForChange(ctx, clog, req.Id)
+	if err != nil {
+		return
+	}
+	// certificate subject is checked against the stored name, so validation waits for the organization
+	v := &validator{}
+	key := api.organizationKey(v, item.Name, req.PublicKey, req.Certificate)
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid key")
+		return
+	}
+	err = api.inTx(ctx, clog, func(tx pgx.Tx) (err error) {
+		if err = api.verifyKeyProof(ctx, tx, clog, key.PublicKey, req.ChallengeId, req.Signature); err != nil {
+			return
+		}
+		err = api.access.OrganizationUpdate(ctx, tx, actor.Username, item, item.Name, item.Label, item.Type, item.Url, key)
+		if err != nil {
+			eMsg := "error in access.OrganizationUpdate"
+			clog.WithError(err).Error(eMsg)
+			err = storeError(err)
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	api.changes.notify()
+	resp = organizationResponse(item)
+	return
+}
+
+// OrganizationChangeState enables or disables organization, disabling revokes trust in its public key
+func (api *APIController) OrganizationChangeState(ctx context.Context, actor *entity.Admin, req *entity.OrganizationChangeStateRequest) (resp *entity.OrganizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationChangeState")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationChangeState",
+		"actor":  actor.Username,
+	})
+	if req.State != entity.EntityStateEnabled && req.State != entity.EntityStateDisabled && req.State != entity.EntityStateDeleted {
+		clog.WithField("state", req.State).Warn("invalid state")
+		err = ErrValidation.WithDetails(FieldError{Field: "state", Reason: FieldReasonInvalidValue})
+		return
+	}
+	var item *entity.Organization
+	item, err = api.organizationForChange(ctx, clog, req.Id)
+	if err != nil {
+		return
+	}
+	err = api.access.OrganizationChangeState(ctx, nil, actor.Username, item, req.State)
+	if err != nil {
+		eMsg := "error in access.OrganizationChangeState"
+		clog.WithError(err).Error(eMsg)
+		err = storeError(err)
+		return
+	}
+	api.changes.notify()
+	resp = organizationResponse(item)
+	return
+}
+
+// OrganizationByClientCertificate maps verified TLS client certificate to enabled organization by subject common name
+func (api *APIController) OrganizationByClientCertificate(ctx context.Context, cert *x509.Certificate) (item *entity.Organization, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationByClientCertificate")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method":  "api.OrganizationByClientCertificate",
+		"subject": cert.Subject.CommonName,
+	})
+	if cert.Subject.CommonName == "" {
+		clog.Warn("client certificate has empty common name")
+		err = ErrForbidden
+		return
+	}
+	item, err = api.access.OrganizationByName(ctx, cert.Subject.CommonName)
+	if err != nil {
+		eMsg := "error in access.OrganizationByName"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if item == nil {
+		clog.Warn("no enabled organization for client certificate")
+		err = ErrForbidden
+		return
+	}
+	return
+}
+
+func (api *APIController) OrganizationGet(ctx context.Context, id int) (resp *entity.OrganizationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationGet")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationGet",
+	})
+	var item *entity.Organization
+	item, err = api.organizationForChange(ctx, clog, id)
+	if err != nil {
+		return
+	}
+	resp = organizationResponse(item)
+	return
+}
+
+// OrganizationKeyLookup returns public key of enabled organization by name, as receivers get it in X-Organization
+func (api *APIController) OrganizationKeyLookup(ctx context.Context, name string) (resp *entity.OrganizationKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationKeyLookup")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationKeyLookup",
+		"name":   name,
+	})
+	v := &validator{}
+	v.requiredString("organization_name", name, organizationNameMaxLen)
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid organization name")
+		return
+	}
+	var item *entity.Organization
+	item, err = api.access.OrganizationByName(ctx, name)
+	if err != nil {
+		eMsg := "error in access.OrganizationByName"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if item == nil {
+		clog.Warn("organization not found")
+		err = ErrNotFound
+		return
+	}
+	resp = &entity.OrganizationKeyResponse{
+		OrganizationId:   item.Id,
+		OrganizationName: item.Name,
+		PublicKey:        item.PublicKey,
+		KeyAlgorithm:     item.Algorithm,
+		KeyFingerprint:   item.Fingerprint,
+	}
+	return
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+// TestOrganizationKeyAlgorithms adds organization with key of every supported algorithm and changes key of
+// another one through all of them, proofs are signed with the scheme of each algorithm
+func TestOrganizationKeyAlgorithms(t *testing.T) {
+	ctx := context.Background()
+	api := newTestController()
+	newEcKey := func(curve elliptic.Curve) crypto.Signer {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	type algorithmKey struct {
+		key crypto.Signer
+		alg entity.KeyAlgorithm
+	}
+	newKeys := func() []algorithmKey {
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []algorithmKey{
+			{newRsaKey(t), entity.KeyAlgorithmRsaPkcs1Sha256},
+			{newEcKey(elliptic.P256()), entity.KeyAlgorithmEcdsaP256Sha256},
+			{newEcKey(elliptic.P384()), entity.KeyAlgorithmEcdsaP384Sha384},
+			{edKey, entity.KeyAlgorithmEd25519},
+		}
+	}
+	keys := newKeys()
+	for i, k := range keys {
+		t.Run(string(k.alg), func(t *testing.T) {
+			o, err := api.OrganizationAdd(ctx, testAdmin, addRequest(t, api, fmt.Sprintf("Edara %d", i), k.key))
+			checkError(t, err, nil)
+			if o.KeyAlgorithm != k.alg {
+				t.Errorf("added with %s, want %s", o.KeyAlgorithm, k.alg)
+			}
+		})
+	}
+
+	o, err := api.OrganizationAdd(ctx, testAdmin, addRequest(t, api, "Edara 9", newRsaKey(t)))
+	checkError(t, err, nil)
+	for _, k := range newKeys() {
+		change := &entity.OrganizationKeyChangeRequest{Id: o.Id, PublicKey: publicKeyPEM(t, k.key)}
+		// proof must be made by the new key, whatever key signs it otherwise
+		change.ChallengeId, change.Signature = proof(t, api, change.PublicKey, keys[0].key)
+		_, err = api.OrganizationKeyChange(ctx, testAdmin, change)
+		checkError(t, err, ErrValidation)
+		change.ChallengeId, change.Signature = proof(t, api, change.PublicKey, k.key)
+		o, err = api.OrganizationKeyChange(ctx, testAdmin, change)
+		checkError(t, err, nil)
+		if o.KeyAlgorithm != k.alg {
+			t.Errorf("changed to %s, want %s", o.KeyAlgorithm, k.alg)
+		}
+	}
+
+	_, err = api.KeyChallengeCreate(ctx, &entity.KeyChallengeRequest{PublicKey: publicKeyPEM(t, newEcKey(elliptic.P224()))})
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "public_key", FieldReasonInvalidValue)
+}
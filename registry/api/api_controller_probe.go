@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	probeConcurrency = 8
+	probeUserAgent   = "doc-registry-prober"
+)
+
+// NewProbeClient returns client for OrganizationProbeAll, redirects are not followed so that
+// the endpoint itself is measured
+func NewProbeClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// OrganizationProbeLoop probes enabled organizations right away and then every interval until ctx is done
+func (api *APIController) OrganizationProbeLoop(ctx context.Context, client *http.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		api.OrganizationProbeAll(ctx, client)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// OrganizationProbeAll sends HEAD request to Url of every enabled organization and stores the outcome.
+// Any response below 500 counts as reachable, since receive endpoints need not support HEAD.
+func (api *APIController) OrganizationProbeAll(ctx context.Context, client *http.Client) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationProbeAll")
+	var err error
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationProbeAll",
+	})
+	var organizations []*entity.Organization
+	organizations, err = api.access.OrganizationList(ctx)
+	if err != nil {
+		clog.WithError(err).Error("error in access.OrganizationList")
+		return
+	}
+	sem := make(chan struct{}, probeConcurrency)
+	wg := sync.WaitGroup{}
+	for _, o := range organizations {
+		if o.State != entity.EntityStateEnabled {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(o *entity.Organization) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			item := probeOrganization(ctx, client, o)
+			if ctx.Err() != nil {
+				return
+			}
+			olog := clog.WithFields(log.Fields{
+				"organization_id": o.Id,
+				"url":             o.Url,
+				"status_code":     item.StatusCode,
+				"latency_ms":      item.LatencyMs,
+			})
+			if item.Error != "" {
+				olog.WithField("probe_error", item.Error).Warn("organization endpoint unreachable")
+			}
+			if sErr := api.access.OrganizationProbeSave(ctx, nil, item); sErr != nil {
+				olog.WithError(sErr).Error("error in access.OrganizationProbeSave")
+			}
+		}(o)
+	}
+	wg.Wait()
+}
+
+func probeOrganization(ctx context.Context, client *http.Client, o *entity.Organization) (item *entity.OrganizationProbe) {
+	item = &entity.OrganizationProbe{
+		OrganizationId: o.Id,
+		Url:            o.Url,
+		ProbeTs:        time.Now().UTC(),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, o.Url, nil)
+	if err != nil {
+		item.Error = err.Error()
+		return
+	}
+	req.Header.Set("User-Agent", probeUserAgent)
+	resp, err := client.Do(req)
+	item.LatencyMs = int(time.Since(item.ProbeTs) / time.Millisecond)
+	if err != nil {
+		item.Error = err.Error()
+		// expired or untrusted certificate still tells when it expires
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) && len(certErr.UnverifiedCertificates) > 0 {
+			notAfter := certErr.UnverifiedCertificates[0].NotAfter.UTC()
+			item.CertExpireTs = &notAfter
+		}
+		return
+	}
+	defer resp.Body.Close()
+	item.StatusCode = resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		notAfter := resp.TLS.PeerCertificates[0].NotAfter.UTC()
+		item.CertExpireTs = &notAfter
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		item.Error = resp.Status
+		return
+	}
+	item.SuccessTs = &item.ProbeTs
+	return
+}
+
+func unixOrNil(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	u := t.Unix()
+	return &u
+}
+
+// OrganizationProbeList returns latest probe of every enabled organization that was probed
+func (api *APIController) OrganizationProbeList(ctx context.Context) (items []*entity.OrganizationProbeResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationProbeList")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationProbeList",
+	})
+	items = make([]*entity.OrganizationProbeResponse, 0)
+	var probes []*entity.OrganizationProbe
+	probes, err = api.access.OrganizationProbeList(ctx)
+	if err != nil {
+		eMsg := "error in access.OrganizationProbeList"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	for _, p := range probes {
+		items = append(items, &entity.OrganizationProbeResponse{
+			OrganizationId:   p.OrganizationId,
+			OrganizationName: p.OrganizationName,
+			Url:              p.Url,
+			Reachable:        p.Error == "",
+			StatusCode:       p.StatusCode,
+			LatencyMs:        p.LatencyMs,
+			Error:            p.Error,
+			CertExpireTs:     unixOrNil(p.CertExpireTs),
+			SuccessTs:        unixOrNil(p.SuccessTs),
+			ProbeTs:          p.ProbeTs.Unix(),
+		})
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+const probeTestTimeout = 5 * time.Second
+
+func TestProbeOrganization(t *testing.T) {
+	handler := func(status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodHead || r.UserAgent() != probeUserAgent {
+				t.Errorf("probe is %s with User-Agent %q", r.Method, r.UserAgent())
+			}
+			if status == http.StatusFound {
+				w.Header().Set("Location", "/elsewhere")
+			}
+			w.WriteHeader(status)
+		})
+	}
+	plain := map[int]*httptest.Server{}
+	for _, status := range []int{http.StatusMethodNotAllowed, http.StatusFound, http.StatusBadGateway} {
+		plain[status] = httptest.NewServer(handler(status))
+		defer plain[status].Close()
+	}
+	untrusted := httptest.NewTLSServer(handler(http.StatusOK))
+	defer untrusted.Close()
+	closed := httptest.NewServer(handler(http.StatusOK))
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		statusCode int
+		reachable  bool
+		certExpire bool
+	}{
+		{"HEAD not supported", plain[http.StatusMethodNotAllowed].URL, http.StatusMethodNotAllowed, true, false},
+		{"redirect is not followed", plain[http.StatusFound].URL, http.StatusFound, true, false},
+		{"server error", plain[http.StatusBadGateway].URL, http.StatusBadGateway, false, false},
+		{"connection refused", closed.URL, 0, false, false},
+		{"untrusted certificate", untrusted.URL, 0, false, true},
+		{"invalid url", "://", 0, false, false},
+	}
+	client := NewProbeClient(probeTestTimeout)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := probeOrganization(context.Background(), client, &entity.Organization{Id: 1, Url: tt.url})
+			if item.StatusCode != tt.statusCode {
+				t.Errorf("StatusCode = %d, want %d", item.StatusCode, tt.statusCode)
+			}
+			if reachable := item.Error == ""; reachable != tt.reachable {
+				t.Errorf("reachable = %v, error %q", reachable, item.Error)
+			}
+			if (item.SuccessTs != nil) != tt.reachable {
+				t.Errorf("SuccessTs = %v with reachable %v", item.SuccessTs, tt.reachable)
+			}
+			if (item.CertExpireTs != nil) != tt.certExpire {
+				t.Errorf("CertExpireTs = %v", item.CertExpireTs)
+			}
+		})
+	}
+}
+
+// TestOrganizationProbeAll probes enabled organizations only and keeps the last success of one that went down
+func TestOrganizationProbeAll(t *testing.T) {
+	ctx := context.Background()
+	api := newTestController()
+	var down atomic.Bool
+	var probed atomic.Int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probed.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer endpoint.Close()
+
+	enabled, err := api.OrganizationAdd(ctx, testAdmin, addRequest(t, api, "Edara 1", newRsaKey(t)))
+	checkError(t, err, nil)
+	disabled, err := api.OrganizationAdd(ctx, testAdmin, addRequest(t, api, "Edara 2", newRsaKey(t)))
+	checkError(t, err, nil)
+	for _, o := range []*entity.OrganizationResponse{enabled, disabled} {
+		_, err = api.OrganizationUpdate(ctx, testAdmin, &entity.OrganizationUpdateRequest{Id: o.Id, Name: o.Name, Label: o.Label, Type: o.Type, Url: endpoint.URL + "/" + strconv.Itoa(o.Id)})
+		checkError(t, err, nil)
+	}
+	_, err = api.OrganizationChangeState(ctx, testAdmin, &entity.OrganizationChangeStateRequest{Id: disabled.Id, State: entity.EntityStateDisabled})
+	checkError(t, err, nil)
+
+	client := NewProbeClient(probeTestTimeout)
+	api.OrganizationProbeAll(ctx, client)
+	if n := probed.Load(); n != 1 {
+		t.Fatalf("%d endpoints probed, want only the enabled one", n)
+	}
+	items, err := api.OrganizationProbeList(ctx)
+	checkError(t, err, nil)
+	if len(items) != 1 || items[0].OrganizationId != enabled.Id || !items[0].Reachable || items[0].SuccessTs == nil {
+		t.Fatalf("probes %+v, want reachable Edara 1 only", items)
+	}
+	lastSuccess := *items[0].SuccessTs
+
+	down.Store(true)
+	api.OrganizationProbeAll(ctx, client)
+	items, err = api.OrganizationProbeList(ctx)
+	checkError(t, err, nil)
+	if len(items) != 1 || items[0].Reachable || items[0].SuccessTs == nil || *items[0].SuccessTs != lastSuccess {
+		t.Fatalf("probes %+v, want unreachable Edara 1 with success at %d", items, lastSuccess)
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	// registrationActor is recorded in audit log for registrations, requesters are not registry admins
+	registrationActor        = "self-registration"
+	registrationReasonMaxLen = 1000
+)
+
+func registrationResponse(item *entity.OrganizationRegistration) *entity.OrganizationRegistrationResponse {
+	return &entity.OrganizationRegistrationResponse{
+		Id:             item.Id,
+		Name:           item.Name,
+		Label:          item.Label,
+		Type:           item.Type,
+		Url:            item.Url,
+		PublicKey:      item.PublicKey,
+		KeyAlgorithm:   item.Algorithm,
+		KeyFingerprint: item.Fingerprint,
+		Certificate:    item.Certificate,
+		CertExpireTs:   unixOrNil(item.CertExpireTs),
+		State:          item.State,
+		Reason:         item.Reason,
+		CreateTs:       item.CreateTs.Unix(),
+		UpdateTs:       item.UpdateTs.Unix(),
+	}
+}
+
+// OrganizationRegister stores registration request as PENDING organization. Returned token is the only way
+// for the requester to poll the status, it is not stored.
+func (api *APIController) OrganizationRegister(ctx context.Context, req *entity.OrganizationRegistrationRequest) (resp *entity.OrganizationRegistrationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationRegister")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationRegister",
+		"name":   req.Name,
+	})
+	v := &validator{}
+	v.organizationFields(req.Name, req.Label, req.Type, req.Url)
+	key := api.organizationKey(v, req.Name, req.PublicKey, req.Certificate)
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid registration data")
+		return
+	}
+	// names are unique only among enabled organizations, so clash is checked here and again on approval
+	var existing *entity.Organization
+	existing, err = api.access.OrganizationByName(ctx, req.Name)
+	if err != nil {
+		eMsg := "error in access.OrganizationByName"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if existing != nil {
+		clog.Warn("organization name is taken")
+		err = ErrConflict.WithAppCode(AppCodeOrganizationNameConflict).WithDetails(FieldError{Field: "name", Reason: FieldReasonConflict})
+		return
+	}
+	token, err := randomToken()
+	if err != nil {
+		eMsg := "error in randomToken"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	var item *entity.OrganizationRegistration
+	err = api.inTx(ctx, clog, func(tx pgx.Tx) (err error) {
+		if err = api.verifyKeyProof(ctx, tx, clog, key.PublicKey, req.ChallengeId, req.Signature); err != nil {
+			return
+		}
+		item, err = api.access.OrganizationRegister(ctx, tx, registrationActor, req.Name, req.Label, req.Type, req.Url, key, hashToken(token))
+		if err != nil {
+			eMsg := "error in access.OrganizationRegister"
+			clog.WithError(err).Error(eMsg)
+			err = storeError(err)
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	clog.WithField("id", item.Id).Info("organization registration received")
+	resp = registrationResponse(item)
+	resp.Token = token
+	return
+}
+
+// OrganizationRegistrationStatus returns registration to its requester, wrong token is reported as not found
+func (api *APIController) OrganizationRegistrationStatus(ctx context.Context, id int, token string) (resp *entity.OrganizationRegistrationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationRegistrationStatus")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationRegistrationStatus",
+		"id":     id,
+	})
+	var item *entity.OrganizationRegistration
+	item, err = api.access.OrganizationRegistrationById(ctx, id)
+	if err != nil {
+		eMsg := "error in access.OrganizationRegistrationById"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if item == nil || subtle.ConstantTimeCompare([]byte(item.TokenHash), []byte(hashToken(token))) != 1 {
+		clog.Warn("registration not found or token mismatch")
+		err = ErrNotFound
+		return
+	}
+	resp = registrationResponse(item)
+	return
+}
+
+// OrganizationRegistrationList returns registrations in state, PENDING ones when state is empty
+func (api *APIController) OrganizationRegistrationList(ctx context.Context, state entity.EntityState) (items []*entity.OrganizationRegistrationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.OrganizationRegistrationList")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationRegistrationList",
+		"state":  state,
+	})
+	if state == "" {
+		state = entity.EntityStatePending
+	}
+	switch state {
+	case entity.EntityStatePending, entity.EntityStateRejected, entity.EntityStateEnabled, entity.EntityStateDisabled:
+	default:
+		clog.Warn("invalid state")
+		err = ErrValidation.WithDetails(FieldError{Field: "state", Reason: FieldReasonInvalidValue})
+		return
+	}
+	var registrations []*entity.OrganizationRegistration
+	registrations, err = api.access.OrganizationRegistrationList(ctx, state)
+	if err != nil {
+		eMsg := "error in access.OrganizationRegistrationList"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	items = make([]*entity.OrganizationRegistrationResponse, 0, len(registrations))
+	for _, item := range registrations {
+		items = append(items, registrationResponse(item))
+	}
+	return
+}
+
+// OrganizationRegistrationApprove enables PENDING organization, it fails with conflict if its name, url or key
+// got taken by another enabled organization meanwhile
+func (api *APIController) OrganizationRegistrationApprove(ctx context.Context, actor *entity.Admin, req *entity.OrganizationRegistrationReviewRequest) (resp *entity.OrganizationRegistrationResponse, err error) {
+	return api.organizationRegistrationReview(ctx, actor, req, entity.EntityStateEnabled)
+}
+
+// OrganizationRegistrationReject rejects PENDING organization, reason is shown to the requester
+func (api *APIController) OrganizationRegistrationReject(ctx context.Context, actor *entity.Admin, req *entity.OrganizationRegistrationReviewRequest) (resp *entity.OrganizationRegistrationResponse, err error) {
+	return api.organizationRegistrationReview(ctx, actor, req, entity.EntityStateRejected)
+}
+
+func (api *APIController) organizationRegistrationReview(ctx context.Context, actor *entity.Admin, req *entity.OrganizationRegistrationReviewRequest, state entity.EntityState) (resp *entity.OrganizationRegistrationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "api.organizationRegistrationReview")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.organizationRegistrationReview",
+		"actor":  actor.Username,
+		"id":     req.Id,
+		"state":  state,
+	})
+	v := &validator{}
+	if state == entity.EntityStateRejected {
+		v.requiredString("reason", req.Reason, registrationReasonMaxLen)
+	} else if len(req.Reason) > registrationReasonMaxLen {
+		v.add("reason", FieldReasonTooLong)
+	}
+	if err = v.err(); err != nil {
+		clog.WithError(err).Warn("invalid review data")
+		return
+	}
+	var item *entity.OrganizationRegistration
+	item, err = api.access.OrganizationRegistrationById(ctx, req.Id)
+	if err != nil {
+		eMsg := "error in access.OrganizationRegistrationById"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	if item == nil {
+		clog.Warn("registration not found")
+		err = ErrNotFound
+		return
+	}
+	if item.State != entity.EntityStatePending {
+		clog.WithField("current-state", item.State).Warn("registration is already reviewed")
+		err = ErrConflict.WithAppCode(AppCodeRegistrationNotPending)
+		return
+	}
+	if state == entity.EntityStateEnabled && item.CertExpireTs != nil && time.Now().After(*item.CertExpireTs) {
+		clog.Warn("certificate expired while registration was pending")
+		err = ErrValidation.WithDetails(FieldError{Field: "certificate", Reason: FieldReasonExpired})
+		return
+	}
+	err = api.access.OrganizationRegistrationReview(ctx, nil, actor.Username, item, state, req.Reason)
+	if err != nil {
+		eMsg := "error in access.OrganizationRegistrationReview"
+		clog.WithError(err).Error(eMsg)
+		err = storeError(err)
+		return
+	}
+	if state == entity.EntityStateEnabled {
+		api.changes.notify()
+	}
+	resp = registrationResponse(item)
+	return
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func registrationRequest(t *testing.T, api *APIController, name string) *entity.OrganizationRegistrationRequest {
+	t.Helper()
+	add := addRequest(t, api, name, newRsaKey(t))
+	return &entity.OrganizationRegistrationRequest{
+		Name:        add.Name,
+		Label:       add.Label,
+		Type:        add.Type,
+		Url:         add.Url,
+		PublicKey:   add.PublicKey,
+		ChallengeId: add.ChallengeId,
+		Signature:   add.Signature,
+	}
+}
+
+func TestOrganizationRegistration(t *testing.T) {
+	ctx := context.Background()
+	api := newTestController()
+
+	rejected, err := api.OrganizationRegister(ctx, registrationRequest(t, api, "Edara 1"))
+	checkError(t, err, nil)
+	if rejected.State != entity.EntityStatePending || rejected.Token == "" {
+		t.Fatalf("registration is %s with token %q, want PENDING with token", rejected.State, rejected.Token)
+	}
+	_, err = api.OrganizationGet(ctx, rejected.Id)
+	checkError(t, err, ErrNotFound)
+	_, err = api.OrganizationRegistrationStatus(ctx, rejected.Id, "wrong token")
+	checkError(t, err, ErrNotFound)
+	status, err := api.OrganizationRegistrationStatus(ctx, rejected.Id, rejected.Token)
+	checkError(t, err, nil)
+	if status.Token != "" {
+		t.Error("token is returned again by status")
+	}
+
+	_, err = api.OrganizationRegistrationReject(ctx, testAdmin, &entity.OrganizationRegistrationReviewRequest{Id: rejected.Id})
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "reason", FieldReasonRequired)
+	_, err = api.OrganizationRegistrationReject(ctx, testAdmin, &entity.OrganizationRegistrationReviewRequest{Id: rejected.Id, Reason: "unknown organization"})
+	checkError(t, err, nil)
+	_, err = api.OrganizationRegistrationApprove(ctx, testAdmin, &entity.OrganizationRegistrationReviewRequest{Id: rejected.Id})
+	checkError(t, err, ErrConflict.WithAppCode(AppCodeRegistrationNotPending))
+	_, err = api.OrganizationRegistrationApprove(ctx, testAdmin, &entity.OrganizationRegistrationReviewRequest{Id: 99})
+	checkError(t, err, ErrNotFound)
+	status, err = api.OrganizationRegistrationStatus(ctx, rejected.Id, rejected.Token)
+	checkError(t, err, nil)
+	if status.State != entity.EntityStateRejected || status.Reason != "unknown organization" {
+		t.Errorf("status is %s with reason %q", status.State, status.Reason)
+	}
+
+	approved, err := api.OrganizationRegister(ctx, registrationRequest(t, api, "Edara 2"))
+	checkError(t, err, nil)
+	pending, err := api.OrganizationRegistrationList(ctx, "")
+	checkError(t, err, nil)
+	if len(pending) != 1 || pending[0].Id != approved.Id {
+		t.Errorf("pending registrations %v, want only %d", pending, approved.Id)
+	}
+	_, err = api.OrganizationRegistrationList(ctx, entity.EntityStateDeleted)
+	checkError(t, err, ErrValidation)
+	_, err = api.OrganizationRegistrationApprove(ctx, testAdmin, &entity.OrganizationRegistrationReviewRequest{Id: approved.Id})
+	checkError(t, err, nil)
+	o, err := api.OrganizationGet(ctx, approved.Id)
+	checkError(t, err, nil)
+	if o.State != entity.EntityStateEnabled {
+		t.Errorf("approved organization is %s", o.State)
+	}
+
+	_, err = api.OrganizationRegister(ctx, registrationRequest(t, api, "Edara 2"))
+	checkError(t, err, ErrConflict.WithAppCode(AppCodeOrganizationNameConflict))
+}
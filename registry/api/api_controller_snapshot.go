@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/snapshot"
+	"ykjam/doc-registry-go/tracing"
+)
+
+// SnapshotExport returns bundle of organizations the public list shows, signed with the response signing key.
+// registryUrl tells readers of the bundle where it came from.
+func (api *APIController) SnapshotExport(ctx context.Context, registryUrl string) (bundle []byte, err error) {
+	ctx, span := tracing.Start(ctx, "api.SnapshotExport")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.SnapshotExport",
+	})
+	if api.signer == nil {
+		err = ErrSigningNotConfigured
+		return
+	}
+	organizations, err := api.access.OrganizationList(ctx)
+	if err != nil {
+		eMsg := "error in access.OrganizationList"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	s := &entity.Snapshot{
+		Manifest: entity.SnapshotManifest{
+			FormatVersion:         entity.SnapshotFormatVersion,
+			CreateTs:              time.Now().Unix(),
+			RegistryUrl:           registryUrl,
+			OrganizationCount:     len(organizations),
+			SigningKeyAlgorithm:   api.signer.key.KeyAlgorithm,
+			SigningKeyFingerprint: api.signer.key.KeyFingerprint,
+		},
+		Organizations: make([]*entity.OrganizationResponse, 0, len(organizations)),
+	}
+	for _, o := range organizations {
+		s.Organizations = append(s.Organizations, organizationResponse(o))
+	}
+	bundle, err = snapshot.Create(api.signer.signer, s)
+	if err != nil {
+		eMsg := "error in snapshot.Create"
+		clog.WithError(err).Error(eMsg)
+		err = ErrInternalServerError
+		return
+	}
+	clog.WithField("organizations", len(organizations)).Info("snapshot exported")
+	return
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"reflect"
+	"testing"
+
+	"ykjam/doc-registry-go/client"
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/snapshot"
+)
+
+// TestSnapshotExport exports organizations, imports the bundle into read-only datastore the way the daemon does
+// and expects the import to serve the same directory
+func TestSnapshotExport(t *testing.T) {
+	ctx := context.Background()
+	api := newTestController()
+	_, err := api.SnapshotExport(ctx, "https://registry.example.com")
+	checkError(t, err, ErrSigningNotConfigured)
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewResponseSigner(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetResponseSigner(signer)
+	for _, name := range []string{"Edara 1", "Edara 2"} {
+		_, err = api.OrganizationAdd(ctx, testAdmin, addRequest(t, api, name, newRsaKey(t)))
+		checkError(t, err, nil)
+	}
+	_, err = api.OrganizationChangeState(ctx, testAdmin, &entity.OrganizationChangeStateRequest{Id: 2, State: entity.EntityStateDisabled})
+	checkError(t, err, nil)
+	_, err = api.OrganizationRegister(ctx, registrationRequest(t, api, "Edara 3"))
+	checkError(t, err, nil)
+
+	raw, err := api.SnapshotExport(ctx, "https://registry.example.com")
+	checkError(t, err, nil)
+	verifier, err := client.NewVerifierFromPEM([]byte(publicKeyPEM(t, signingKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := snapshot.Open(raw, verifier)
+	if err != nil {
+		t.Fatalf("exported bundle does not open: %v", err)
+	}
+	want, err := api.OrganizationDirectory(ctx)
+	checkError(t, err, nil)
+	if len(want) == 0 || s.Manifest.OrganizationCount != len(want) || s.Manifest.SigningKeyFingerprint != signer.key.KeyFingerprint {
+		t.Errorf("manifest %+v, want %d organizations signed by %s", s.Manifest, len(want), signer.key.KeyFingerprint)
+	}
+
+	imported := datastore.NewReadOnlyAccess()
+	imported.Replace(snapshot.Organizations(s.Organizations))
+	got, err := NewAPIController(imported).OrganizationDirectory(ctx)
+	checkError(t, err, nil)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported directory %+v, want %+v", got, want)
+	}
+}
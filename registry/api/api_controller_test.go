@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+var testAdmin = &entity.Admin{Id: 1, Username: "tester", Role: entity.AdminRoleSecurityOfficer}
+
+func newTestController() *APIController {
+	return NewAPIController(datastore.NewMemoryAccess())
+}
+
+func newRsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func publicKeyPEM(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// proof takes a challenge for publicKey and signs its nonce with key, as the applicant does
+func proof(t *testing.T, api *APIController, publicKey string, key crypto.Signer) (challengeId int, signature string) {
+	t.Helper()
+	challenge, err := api.KeyChallengeCreate(context.Background(), &entity.KeyChallengeRequest{PublicKey: publicKey})
+	if err != nil {
+		t.Fatalf("KeyChallengeCreate: %v", err)
+	}
+	sig, err := signing.Sign(key, []byte(challenge.Nonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge.Id, base64.StdEncoding.EncodeToString(sig)
+}
+
+// addRequest is a valid request adding organization name with key, proof included
+func addRequest(t *testing.T, api *APIController, name string, key crypto.Signer) *entity.OrganizationAddRequest {
+	t.Helper()
+	publicKey := publicKeyPEM(t, key)
+	challengeId, signature := proof(t, api, publicKey, key)
+	return &entity.OrganizationAddRequest{
+		Name:        name,
+		Label:       name + " label",
+		Type:        entity.SRD,
+		Url:         "https://" + strings.ToLower(strings.ReplaceAll(name, " ", "-")) + ".example.com/receive",
+		PublicKey:   publicKey,
+		ChallengeId: challengeId,
+		Signature:   signature,
+	}
+}
+
+// checkError fails t unless err matches want, nil want expects no error
+func checkError(t *testing.T, err, want error) {
+	t.Helper()
+	switch {
+	case want == nil && err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	case want != nil && !errors.Is(err, want):
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+// checkDetail fails t unless err reports reason for field
+func checkDetail(t *testing.T, err error, field, reason string) {
+	t.Helper()
+	for _, d := range AsError(err).Details {
+		if d.Field == field && d.Reason == reason {
+			return
+		}
+	}
+	t.Fatalf("error %v has no %s detail for %s, got %v", err, reason, field, AsError(err).Details)
+}
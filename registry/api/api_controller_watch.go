@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+)
+
+// changeNotifier wakes up watchers when organizations are changed through this process,
+// changes made by other instances are picked up by polling
+type changeNotifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newChangeNotifier() *changeNotifier {
+	return &changeNotifier{ch: make(chan struct{})}
+}
+
+// wait returns channel closed on next notify
+func (n *changeNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ch
+}
+
+func (n *changeNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
+
+// OrganizationWatch sends every current organization as ADDED, then every change noticed, until ctx is done
+// or send fails. Changes are checked after each write through this controller and every pollInterval.
+// Organizations are compared as watchers see them rather than by Version, since mirrors and snapshots
+// do not get the upstream version.
+func (api *APIController) OrganizationWatch(ctx context.Context, pollInterval time.Duration, send func(event *entity.OrganizationEvent) error) (err error) {
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "api.OrganizationWatch",
+	})
+	known := make(map[int]*entity.OrganizationResponse)
+	for {
+		changed := api.changes.wait()
+		var organizations []*entity.Organization
+		organizations, err = api.access.OrganizationList(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			eMsg := "error in access.OrganizationList"
+			clog.WithError(err).Error(eMsg)
+			return ErrInternalServerError
+		}
+		seen := make(map[int]bool, len(organizations))
+		for _, o := range organizations {
+			seen[o.Id] = true
+			resp := organizationResponse(o)
+			prev, ok := known[o.Id]
+			if ok && reflect.DeepEqual(prev, resp) {
+				continue
+			}
+			eventType := entity.OrganizationEventUpdated
+			if !ok {
+				eventType = entity.OrganizationEventAdded
+			}
+			known[o.Id] = resp
+			if err = send(&entity.OrganizationEvent{Type: eventType, Organization: resp}); err != nil {
+				return
+			}
+		}
+		for id, o := range known {
+			if seen[id] {
+				continue
+			}
+			delete(known, id)
+			deleted := *o
+			deleted.State = entity.EntityStateDeleted
+			if err = send(&entity.OrganizationEvent{Type: entity.OrganizationEventDeleted, Organization: &deleted}); err != nil {
+				return
+			}
+		}
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+const (
+	// issued certificates start a bit earlier to tolerate clock skew between nodes
+	certificateBackdate = 5 * time.Minute
+	crlValidity         = time.Hour
+	serialBits          = 127
+)
+
+var (
+	ErrCaNotCA       = errors.New("CA certificate must be a CA allowed to sign certificates and CRLs")
+	ErrCaKeyMismatch = errors.New("CA key does not match CA certificate")
+	ErrNoCaCert      = errors.New("no certificate found in CA certificate file")
+
+	oidCrlReason = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+// CertificateAuthority issues short-lived DMS node certificates for organization keys
+type CertificateAuthority struct {
+	cert     *x509.Certificate
+	signer   crypto.Signer
+	validity time.Duration
+	// CRL distribution point put into issued certificates, left out when empty
+	crlUrl string
+}
+
+// LoadCertificateAuthority reads CA certificate to sign with signer from the key backend, crlUrl is where the registry
+// serves its CRL
+func LoadCertificateAuthority(certFile string, signer crypto.Signer, validity time.Duration, crlUrl string) (ca *CertificateAuthority, err error) {
+	clog := log.WithFields(log.Fields{
+		"method": "api.LoadCertificateAuthority",
+		"cert":   certFile,
+	})
+	certs, err := readCertificates(certFile)
+	if err == nil && len(certs) == 0 {
+		err = ErrNoCaCert
+	}
+	if err != nil {
+		eMsg := "error loading CA certificate"
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		return
+	}
+	ca, err = NewCertificateAuthority(certs[0], signer, validity, crlUrl)
+	if err != nil {
+		clog.WithError(err).Error("invalid CA")
+		return nil, err
+	}
+	clog.WithField("subject", certs[0].Subject.String()).Info("certificate authority loaded")
+	return
+}
+
+func NewCertificateAuthority(cert *x509.Certificate, signer crypto.Signer, validity time.Duration, crlUrl string) (ca *CertificateAuthority, err error) {
+	if !cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign == 0 || cert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, ErrCaNotCA
+	}
+	certHash, err := publicKeyHash(cert.PublicKey)
+	if err != nil {
+		return
+	}
+	keyHash, err := publicKeyHash(signer.Public())
+	if err != nil {
+		return
+	}
+	if certHash != keyHash {
+		return nil, ErrCaKeyMismatch
+	}
+	return &CertificateAuthority{cert: cert, signer: signer, validity: validity, crlUrl: crlUrl}, nil
+}
+
+// SetCertificateAuthority enables issuing DMS node certificates, certificate routes answer 404 while ca is nil
+func (api *APIController) SetCertificateAuthority(ca *CertificateAuthority) {
+	api.ca = ca
+}
+
+func (ca *CertificateAuthority) certificatePEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+}
+
+func randomSerial() (serial *big.Int, err error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), serialBits))
+}
+
+// issue signs certificate for publicKey of organization, subject common name is the organization name the same way
+// client certificates are mapped to organizations, server certificates are valid for the host of organization url
+func (ca *CertificateAuthority) issue(o *entity.Organization, publicKey crypto.PublicKey, usage entity.CertificateUsage) (item *entity.IssuedCertificate, err error) {
+	serial, err := randomSerial()
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	notAfter := now.Add(ca.validity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: o.Name, Organization: []string{o.Label}},
+		NotBefore:    now.Add(-certificateBackdate),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if _, ok := publicKey.(*rsa.PublicKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	if ca.crlUrl != "" {
+		template.CRLDistributionPoints = []string{ca.crlUrl}
+	}
+	switch usage {
+	case entity.CertificateUsageClient:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	case entity.CertificateUsageServer:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		u, pErr := url.Parse(o.Url)
+		if pErr != nil {
+			return nil, pErr
+		}
+		if ip := net.ParseIP(u.Hostname()); ip != nil {
+			template.IPAddresses = []net.IP{ip}
+		} else {
+			template.DNSNames = []string{u.Hostname()}
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, publicKey, ca.signer)
+	if err != nil {
+		return
+	}
+	fingerprint, err := publicKeyHash(publicKey)
+	if err != nil {
+		return
+	}
+	item = &entity.IssuedCertificate{
+		OrganizationId: o.Id,
+		Serial:         serial.Text(16),
+		Usage:          usage,
+		KeyFingerprint: fingerprint,
+		Certificate:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		NotBefore:      template.NotBefore,
+		NotAfter:       template.NotAfter,
+	}
+	return
+}
+
+// crl returns DER encoded CRL listing revoked certificates, numbered by the time it is made
+func (ca *CertificateAuthority) crl(revoked []*entity.IssuedCertificate) (der []byte, err error) {
+	now := time.Now().UTC()
+	entries := make([]pkix.RevokedCertificate, 0, len(revoked))
+	for _, item := range revoked {
+		serial, ok := new(big.Int).SetString(item.Serial, 16)
+		if !ok || item.RevokedTs == nil {
+			continue
+		}
+		reason, mErr := asn1.Marshal(asn1.Enumerated(item.RevocationReason.Code()))
+		if mErr != nil {
+			return nil, mErr
+		}
+		entries = append(entries, pkix.RevokedCertificate{
+			SerialNumber:   serial,
+			RevocationTime: *item.RevokedTs,
+			Extensions:     []pkix.Extension{{Id: oidCrlReason, Value: reason}},
+		})
+	}
+	template := &x509.RevocationList{
+		RevokedCertificates: entries,
+		Number:              big.NewInt(now.UnixNano()),
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(crlValidity),
+	}
+	return x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.signer)
+}
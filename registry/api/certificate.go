@@ -0,0 +1,198 @@
+package api
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+var ErrNoTrustAnchors = errors.New("no certificates found in root CA bundle")
+
+// CertificateTrust holds the national PKI CAs organization certificates must chain to
+type CertificateTrust struct {
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+}
+
+func readCertificates(source string) (certs []*x509.Certificate, err error) {
+	raw, err := ioutil.ReadFile(source)
+	if err != nil {
+		return
+	}
+	return parseCertificates(raw)
+}
+
+// parseCertificates decodes every CERTIFICATE block of PEM bundle, other blocks are skipped
+func parseCertificates(raw []byte) (certs []*x509.Certificate, err error) {
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			return
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// LoadCertificateTrust reads root CA bundle and optional intermediate CA bundle, both PEM encoded
+func LoadCertificateTrust(rootFile, intermediateFile string) (trust *CertificateTrust, err error) {
+	clog := log.WithFields(log.Fields{
+		"method": "api.LoadCertificateTrust",
+		"roots":  rootFile,
+	})
+	var roots, intermediates []*x509.Certificate
+	roots, err = readCertificates(rootFile)
+	if err == nil && len(roots) == 0 {
+		err = ErrNoTrustAnchors
+	}
+	if err != nil {
+		eMsg := "error loading root CA bundle"
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		return
+	}
+	if intermediateFile != "" {
+		intermediates, err = readCertificates(intermediateFile)
+		if err != nil {
+			eMsg := "error loading intermediate CA bundle"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+	}
+	clog.WithField("count", len(roots)).Info("certificate trust anchors loaded")
+	return NewCertificateTrust(roots, intermediates), nil
+}
+
+func NewCertificateTrust(roots, intermediates []*x509.Certificate) *CertificateTrust {
+	trust := &CertificateTrust{
+		roots:         x509.NewCertPool(),
+		intermediates: x509.NewCertPool(),
+	}
+	for _, c := range roots {
+		trust.roots.AddCert(c)
+	}
+	for _, c := range intermediates {
+		trust.intermediates.AddCert(c)
+	}
+	return trust
+}
+
+// SetCertificateTrust enables X.509 certificates as organization keys, they are rejected while trust is nil
+func (api *APIController) SetCertificateTrust(trust *CertificateTrust) {
+	api.trust = trust
+}
+
+// organizationKey validates key given either as bare public key or as certificate of organization named name.
+// Certificate must chain to trust anchors, be valid now and have name as subject common name, the same way
+// client certificates are mapped to organizations. When both are given they must hold the same key.
+func (api *APIController) organizationKey(v *validator, name, publicKey, certificate string) (key entity.OrganizationKey) {
+	if certificate == "" {
+		v.publicKey("public_key", publicKey)
+		parsed, ok := parsePublicKey(publicKey)
+		if !ok {
+			return
+		}
+		// stored as PKIX like keys taken from certificates, whatever PEM form it was given in
+		der, err := x509.MarshalPKIXPublicKey(parsed)
+		if err != nil {
+			v.add("public_key", FieldReasonInvalidValue)
+			return
+		}
+		key.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		key.Algorithm, _ = signing.Algorithm(parsed)
+		key.Fingerprint, _ = publicKeyHash(parsed)
+		return
+	}
+	if api.trust == nil {
+		v.add("certificate", FieldReasonUntrusted)
+		return
+	}
+	certs, err := parseCertificates([]byte(certificate))
+	if err != nil || len(certs) == 0 {
+		v.add("certificate", FieldReasonInvalidFormat)
+		return
+	}
+	leaf := certs[0]
+	now := time.Now()
+	if now.Before(leaf.NotBefore) {
+		v.add("certificate", FieldReasonNotYetValid)
+		return
+	}
+	if now.After(leaf.NotAfter) {
+		v.add("certificate", FieldReasonExpired)
+		return
+	}
+	intermediates := intermediatePool(api.trust.intermediates, certs[1:])
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         api.trust.roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		v.add("certificate", FieldReasonUntrusted)
+		return
+	}
+	if leaf.Subject.CommonName != name {
+		v.add("certificate", FieldReasonSubjectMismatch)
+		return
+	}
+	alg, err := signing.Algorithm(leaf.PublicKey)
+	if err != nil {
+		v.add("certificate", FieldReasonInvalidValue)
+		return
+	}
+	der, err := x509.MarshalPKIXPublicKey(leaf.PublicKey)
+	if err != nil {
+		v.add("certificate", FieldReasonInvalidValue)
+		return
+	}
+	leafHash, _ := publicKeyHash(leaf.PublicKey)
+	if publicKey != "" {
+		given, ok := parsePublicKey(publicKey)
+		givenHash, _ := publicKeyHash(given)
+		if !ok || givenHash != leafHash {
+			v.add("public_key", FieldReasonInvalidValue)
+			return
+		}
+	}
+	var chain []byte
+	for _, c := range certs {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	notAfter := leaf.NotAfter.UTC()
+	key.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	key.Algorithm = alg
+	key.Fingerprint = leafHash
+	key.Certificate = string(chain)
+	key.CertExpireTs = &notAfter
+	return
+}
+
+// intermediatePool returns pool with configured intermediates and the ones sent along with the certificate
+func intermediatePool(configured *x509.CertPool, sent []*x509.Certificate) *x509.CertPool {
+	if len(sent) == 0 {
+		return configured
+	}
+	pool := configured.Clone()
+	for _, c := range sent {
+		pool.AddCert(c)
+	}
+	return pool
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+// testCA issues certificates for organization keys
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTestCA(t *testing.T, name string, parent *testCA) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &testCA{key: key}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	if parent == nil {
+		parent = &testCA{cert: template, key: key}
+	}
+	ca.cert = parent.sign(t, template, key.Public())
+	return ca
+}
+
+func (ca *testCA) sign(t *testing.T, template *x509.Certificate, pub crypto.PublicKey) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// issue returns PEM certificate for key named name valid between notBefore and notAfter
+func (ca *testCA) issue(t *testing.T, name string, key crypto.Signer, notBefore, notAfter time.Time) string {
+	t.Helper()
+	cert := ca.sign(t, &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, key.Public())
+	return certificatePEM(cert)
+}
+
+func certificatePEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func TestOrganizationKeyBareKeyForms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkixPEM := func(pub interface{}) string {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	}
+	rsaPkix := pkixPEM(&rsaKey.PublicKey)
+	tests := []struct {
+		name      string
+		publicKey string
+		want      string
+		alg       entity.KeyAlgorithm
+	}{
+		{"RSA PKIX", rsaPkix, rsaPkix, entity.KeyAlgorithmRsaPkcs1Sha256},
+		{"RSA PKCS #1", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})), rsaPkix, entity.KeyAlgorithmRsaPkcs1Sha256},
+		{"RSA PKIX with text around", "key:\n" + rsaPkix + "\n\n", rsaPkix, entity.KeyAlgorithmRsaPkcs1Sha256},
+		{"P-256 PKIX", pkixPEM(&ecKey.PublicKey), pkixPEM(&ecKey.PublicKey), entity.KeyAlgorithmEcdsaP256Sha256},
+	}
+	api := &APIController{}
+	var rsaFingerprint string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &validator{}
+			key := api.organizationKey(v, "org", tt.publicKey, "")
+			if err := v.err(); err != nil {
+				t.Fatalf("organizationKey: %v", err)
+			}
+			if key.PublicKey != tt.want {
+				t.Errorf("PublicKey = %q, want PKIX %q", key.PublicKey, tt.want)
+			}
+			if key.Algorithm != tt.alg || key.Fingerprint == "" {
+				t.Errorf("Algorithm = %s, Fingerprint = %q", key.Algorithm, key.Fingerprint)
+			}
+			if tt.alg == entity.KeyAlgorithmRsaPkcs1Sha256 {
+				if rsaFingerprint == "" {
+					rsaFingerprint = key.Fingerprint
+				} else if key.Fingerprint != rsaFingerprint {
+					t.Errorf("Fingerprint = %s, want %s of the same key in another form", key.Fingerprint, rsaFingerprint)
+				}
+			}
+		})
+	}
+
+	v := &validator{}
+	if key := api.organizationKey(v, "org", "not a key", ""); v.err() == nil || key.PublicKey != "" {
+		t.Errorf("invalid key accepted as %q", key.PublicKey)
+	}
+}
+
+func TestOrganizationKeyCertificate(t *testing.T) {
+	root := newTestCA(t, "root CA", nil)
+	intermediate := newTestCA(t, "intermediate CA", root)
+	other := newTestCA(t, "other CA", nil)
+	key := newRsaKey(t)
+	now := time.Now()
+	valid := func(ca *testCA, name string) string {
+		return ca.issue(t, name, key, now.Add(-time.Hour), now.Add(time.Hour))
+	}
+	tests := []struct {
+		name        string
+		certificate string
+		publicKey   string
+		field       string
+		reason      string
+	}{
+		{"issued by root", valid(root, "Edara 1"), "", "", ""},
+		{"issued by intermediate sent along", valid(intermediate, "Edara 1") + certificatePEM(intermediate.cert), "", "", ""},
+		{"with the same public key", valid(root, "Edara 1"), publicKeyPEM(t, key), "", ""},
+		{"intermediate not sent", valid(intermediate, "Edara 1"), "", "certificate", FieldReasonUntrusted},
+		{"issued by other CA", valid(other, "Edara 1"), "", "certificate", FieldReasonUntrusted},
+		{"issued to other name", valid(root, "Edara 2"), "", "certificate", FieldReasonSubjectMismatch},
+		{"expired", root.issue(t, "Edara 1", key, now.Add(-2*time.Hour), now.Add(-time.Hour)), "", "certificate", FieldReasonExpired},
+		{"not yet valid", root.issue(t, "Edara 1", key, now.Add(time.Hour), now.Add(2*time.Hour)), "", "certificate", FieldReasonNotYetValid},
+		{"with other public key", valid(root, "Edara 1"), publicKeyPEM(t, newRsaKey(t)), "public_key", FieldReasonInvalidValue},
+		{"not a certificate", "not a certificate", "", "certificate", FieldReasonInvalidFormat},
+	}
+	api := newTestController()
+	api.SetCertificateTrust(NewCertificateTrust([]*x509.Certificate{root.cert}, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &validator{}
+			got := api.organizationKey(v, "Edara 1", tt.publicKey, tt.certificate)
+			err := v.err()
+			if tt.field != "" {
+				checkError(t, err, ErrValidation)
+				checkDetail(t, err, tt.field, tt.reason)
+				return
+			}
+			checkError(t, err, nil)
+			if got.PublicKey != publicKeyPEM(t, key) || got.Certificate == "" || got.CertExpireTs == nil {
+				t.Errorf("key %+v, want the certificate key", got)
+			}
+		})
+	}
+
+	v := &validator{}
+	newTestController().organizationKey(v, "Edara 1", "", valid(root, "Edara 1"))
+	checkDetail(t, v.err(), "certificate", FieldReasonUntrusted)
+}
+
+// TestOrganizationRenameWithCertificate rejects rename of organization whose certificate names it
+func TestOrganizationRenameWithCertificate(t *testing.T) {
+	ctx := context.Background()
+	root := newTestCA(t, "root CA", nil)
+	api := newTestController()
+	api.SetCertificateTrust(NewCertificateTrust([]*x509.Certificate{root.cert}, nil))
+	key := newRsaKey(t)
+	req := addRequest(t, api, "Edara 1", key)
+	req.PublicKey, req.Certificate = "", root.issue(t, "Edara 1", key, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	o, err := api.OrganizationAdd(ctx, testAdmin, req)
+	checkError(t, err, nil)
+
+	update := &entity.OrganizationUpdateRequest{Id: o.Id, Name: "Edara 2", Label: o.Label, Type: o.Type, Url: o.Url}
+	_, err = api.OrganizationUpdate(ctx, testAdmin, update)
+	checkError(t, err, ErrValidation)
+	checkDetail(t, err, "name", FieldReasonSubjectMismatch)
+	update.Name, update.Label = o.Name, "Edara 1, Müdirlik"
+	_, err = api.OrganizationUpdate(ctx, testAdmin, update)
+	checkError(t, err, nil)
+}
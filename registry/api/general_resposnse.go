@@ -10,26 +10,170 @@ type GeneralResponse struct {
 }
 
 type ResponseErrorCodeAndMessage struct {
-	ErrorCode    int    `json:"error_code"`
-	ErrorMessage string `json:"error_msg"`
+	ErrorCode    int          `json:"error_code"`
+	ErrorMessage string       `json:"error_msg"`
+	Code         string       `json:"code"`
+	Details      []FieldError `json:"details,omitempty"`
+	RequestId    string       `json:"request_id,omitempty"`
 }
 
+// FieldError tells which request field failed and why, Reason is one of FieldReason* values
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+const (
+	FieldReasonRequired      = "required"
+	FieldReasonInvalidFormat = "invalid_format"
+	FieldReasonInvalidValue  = "invalid_value"
+	FieldReasonNotASCII      = "not_ascii"
+	FieldReasonTooShort      = "too_short"
+	FieldReasonTooLong       = "too_long"
+	FieldReasonConflict      = "conflict"
+	// certificate reasons
+	FieldReasonExpired         = "expired"
+	FieldReasonNotYetValid     = "not_yet_valid"
+	FieldReasonUntrusted       = "untrusted"
+	FieldReasonSubjectMismatch = "subject_mismatch"
+	FieldReasonKeyMismatch     = "key_mismatch"
+)
+
 const (
 	ErrorCodeOK                  int = 200
 	ErrorCodeBadRequest          int = 400
+	ErrorCodeUnauthorized        int = 401
 	ErrorCodeForbidden           int = 403
 	ErrorCodeNotFound            int = 404
+	ErrorCodeMethodNotAllowed    int = 405
+	ErrorCodeConflict            int = 409
+	ErrorCodeRequestTooLarge     int = 413
+	ErrorCodeTooManyRequests     int = 429
 	ErrorCodeInternalServerError int = 500
+	ErrorCodeServiceUnavailable  int = 503
 )
 
-var ErrOK = errors.New("OK")
-var ErrBadRequest = errors.New("bad request")
-var ErrNotFound = errors.New("not found")
-var ErrInternalServerError = errors.New("internal server error")
-
 const (
 	ErrorMessageOK                  = "ok"
 	ErrorMessageBadRequest          = "bad_request"
+	ErrorMessageUnauthorized        = "unauthorized"
+	ErrorMessageForbidden           = "forbidden"
 	ErrorMessageNotFound            = "not_found"
+	ErrorMessageMethodNotAllowed    = "method_not_allowed"
+	ErrorMessageConflict            = "conflict"
+	ErrorMessageRequestTooLarge     = "request_too_large"
+	ErrorMessageTooManyRequests     = "too_many_requests"
 	ErrorMessageInternalServerError = "internal_server_error"
+	ErrorMessageServiceUnavailable  = "service_unavailable"
 )
+
+// stable application error codes, clients should branch on these rather than on HTTP status or message
+const (
+	AppCodeBadRequest                    = "BAD_REQUEST"
+	AppCodeValidationFailed              = "VALIDATION_FAILED"
+	AppCodeUnauthorized                  = "UNAUTHORIZED"
+	AppCodeForbidden                     = "FORBIDDEN"
+	AppCodeNotFound                      = "NOT_FOUND"
+	AppCodeMethodNotAllowed              = "METHOD_NOT_ALLOWED"
+	AppCodeConflict                      = "CONFLICT"
+	AppCodeOrganizationNameConflict      = "ORGANIZATION_NAME_CONFLICT"
+	AppCodeOrganizationUrlConflict       = "ORGANIZATION_URL_CONFLICT"
+	AppCodeOrganizationPublicKeyConflict = "ORGANIZATION_PUBLIC_KEY_CONFLICT"
+	AppCodeRegistrationNotPending        = "REGISTRATION_NOT_PENDING"
+	AppCodeCaNotConfigured               = "CA_NOT_CONFIGURED"
+	AppCodeSigningNotConfigured          = "SIGNING_NOT_CONFIGURED"
+	AppCodeCertificateRevoked            = "CERTIFICATE_REVOKED"
+	AppCodeReadOnly                      = "READ_ONLY"
+	AppCodeMirrorNotConfigured           = "MIRROR_NOT_CONFIGURED"
+	AppCodeRequestTooLarge               = "REQUEST_TOO_LARGE"
+	AppCodeTooManyRequests               = "TOO_MANY_REQUESTS"
+	AppCodeInternalServerError           = "INTERNAL_SERVER_ERROR"
+	AppCodeServiceUnavailable            = "SERVICE_UNAVAILABLE"
+)
+
+// Error is returned by APIController methods, it carries the HTTP status code, message and stable
+// application code sent to client, and optionally field level details.
+// Errors with code below 500 are client errors, the rest are server errors.
+type Error struct {
+	Code    int
+	Message string
+	AppCode string
+	Details []FieldError
+	cause   error
+	parent  *Error
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is makes copies match their sentinel and more specific errors match the general one they were derived from,
+// e.g. errors.Is(ErrValidation.WithDetails(d), ErrBadRequest) is true, errors.Is(ErrBadRequest, ErrValidation) is not
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	for p := e; p != nil; p = p.parent {
+		if p.Code == t.Code && p.AppCode == t.AppCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap returns copy of e keeping cause for logs, cause is never sent to client
+func (e *Error) Wrap(cause error) *Error {
+	c := *e
+	c.cause = cause
+	return &c
+}
+
+// WithDetails returns copy of e with field level details
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// WithAppCode derives more specific error from e, the result still matches e in errors.Is
+func (e *Error) WithAppCode(appCode string) *Error {
+	c := *e
+	c.AppCode = appCode
+	c.parent = e
+	return &c
+}
+
+func (e *Error) IsClientError() bool {
+	return e.Code < ErrorCodeInternalServerError
+}
+
+var ErrOK = errors.New("OK")
+var ErrBadRequest = &Error{Code: ErrorCodeBadRequest, Message: ErrorMessageBadRequest, AppCode: AppCodeBadRequest}
+var ErrValidation = ErrBadRequest.WithAppCode(AppCodeValidationFailed)
+var ErrConflict = &Error{Code: ErrorCodeConflict, Message: ErrorMessageConflict, AppCode: AppCodeConflict}
+var ErrUnauthorized = &Error{Code: ErrorCodeUnauthorized, Message: ErrorMessageUnauthorized, AppCode: AppCodeUnauthorized}
+var ErrForbidden = &Error{Code: ErrorCodeForbidden, Message: ErrorMessageForbidden, AppCode: AppCodeForbidden}
+var ErrNotFound = &Error{Code: ErrorCodeNotFound, Message: ErrorMessageNotFound, AppCode: AppCodeNotFound}
+var ErrMethodNotAllowed = &Error{Code: ErrorCodeMethodNotAllowed, Message: ErrorMessageMethodNotAllowed, AppCode: AppCodeMethodNotAllowed}
+var ErrReadOnly = ErrMethodNotAllowed.WithAppCode(AppCodeReadOnly)
+var ErrRequestTooLarge = &Error{Code: ErrorCodeRequestTooLarge, Message: ErrorMessageRequestTooLarge, AppCode: AppCodeRequestTooLarge}
+var ErrTooManyRequests = &Error{Code: ErrorCodeTooManyRequests, Message: ErrorMessageTooManyRequests, AppCode: AppCodeTooManyRequests}
+var ErrInternalServerError = &Error{Code: ErrorCodeInternalServerError, Message: ErrorMessageInternalServerError, AppCode: AppCodeInternalServerError}
+var ErrServiceUnavailable = &Error{Code: ErrorCodeServiceUnavailable, Message: ErrorMessageServiceUnavailable, AppCode: AppCodeServiceUnavailable}
+
+// AsError finds *Error in err chain, unknown errors are reported as ErrInternalServerError
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternalServerError
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"ykjam/doc-registry-go/client"
+	"ykjam/doc-registry-go/datastore"
+)
+
+// Mirror pulls organizations of an upstream registry into a read-only datastore
+type Mirror struct {
+	upstreamUrl string
+	upstream    *client.Client
+	target      *datastore.ReadOnlyAccess
+
+	mu            sync.Mutex
+	lastSyncTs    *time.Time
+	lastAttemptTs *time.Time
+	lastError     string
+	count         int
+}
+
+// NewMirror creates mirror of upstreamUrl filling target, upstream responses without valid signature are rejected.
+// Responses are revalidated with If-None-Match, so unchanged organizations cost upstream little on each sync.
+func NewMirror(upstreamUrl string, verifier client.Verifier, target *datastore.ReadOnlyAccess, timeout time.Duration) (m *Mirror, err error) {
+	upstream, err := client.New([]string{upstreamUrl},
+		client.WithVerifier(verifier),
+		client.WithHTTPClient(&http.Client{Timeout: timeout}))
+	if err != nil {
+		return
+	}
+	return &Mirror{upstreamUrl: upstreamUrl, upstream: upstream, target: target}, nil
+}
+
+// SetMirror makes controller serve data of m, readiness fails until its first successful sync
+func (api *APIController) SetMirror(m *Mirror) {
+	api.mirror = m
+}
+
+func (m *Mirror) synced() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastSyncTs != nil
+}
+
+// record stores outcome of sync started at start
+func (m *Mirror) record(start time.Time, count int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastAttemptTs = &start
+	if err != nil {
+		m.lastError = err.Error()
+		return
+	}
+	m.lastSyncTs = &start
+	m.lastError = ""
+	m.count = count
+}
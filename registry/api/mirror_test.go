@@ -0,0 +1,227 @@
+package api_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/client"
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/ratelimit"
+	"ykjam/doc-registry-go/web"
+)
+
+// newUpstream serves organizations of source with responses signed by signer
+func newUpstream(source datastore.Access, signer *api.ResponseSigner) *httptest.Server {
+	upstreamController := api.NewAPIController(source)
+	upstreamController.SetResponseSigner(signer)
+	s := web.NewServer(upstreamController, config.NewStaticStore(&config.Config{}), ratelimit.New())
+	r := mux.NewRouter()
+	s.RegisterRoutes(r)
+	return httptest.NewServer(r)
+}
+
+// newMirror returns controller mirroring upstreamUrl, responses must be signed with signingKey
+func newMirror(t *testing.T, upstreamUrl string, signingKey crypto.Signer) *api.APIController {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(signingKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := client.NewVerifierFromPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := datastore.NewReadOnlyAccess()
+	mirror, err := api.NewMirror(upstreamUrl, verifier, target, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := api.NewAPIController(target)
+	c.SetMirror(mirror)
+	return c
+}
+
+// TestMirrorWatchUpdated syncs a mirror twice from an upstream registry and expects watchers of the mirror
+// to get the organization changed in between as UPDATED
+func TestMirrorWatchUpdated(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := api.NewResponseSigner(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := datastore.NewReadOnlyAccess()
+	now := time.Now().UTC().Truncate(time.Second)
+	organization := func(url string) *entity.Organization {
+		return &entity.Organization{
+			Id:              1,
+			Name:            "Edara 1",
+			Label:           "Edara 1",
+			Type:            entity.SRD,
+			Url:             url,
+			OrganizationKey: entity.OrganizationKey{PublicKey: "key", Algorithm: entity.KeyAlgorithmEcdsaP256Sha256, Fingerprint: "f1"},
+			State:           entity.EntityStateEnabled,
+			CreateTs:        now,
+			UpdateTs:        now,
+			Version:         1,
+		}
+	}
+	source.Replace([]*entity.Organization{organization("https://edara-1.example.com/receive")})
+	upstream := newUpstream(source, signer)
+	defer upstream.Close()
+	c := newMirror(t, upstream.URL, signingKey)
+	if err = c.MirrorSync(ctx); err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+
+	events := make(chan *entity.OrganizationEvent, 10)
+	go func() {
+		_ = c.OrganizationWatch(ctx, time.Hour, func(event *entity.OrganizationEvent) error {
+			events <- event
+			return nil
+		})
+	}()
+	next := func() *entity.OrganizationEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no watch event")
+			return nil
+		}
+	}
+	if event := next(); event.Type != entity.OrganizationEventAdded {
+		t.Fatalf("first event = %s, want %s", event.Type, entity.OrganizationEventAdded)
+	}
+
+	// a new URL within the same second keeps update_ts as well, the mirror still has to tell it apart
+	source.Replace([]*entity.Organization{organization("https://edara-1.example.com/v2/receive")})
+	if err = c.MirrorSync(ctx); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	event := next()
+	if event.Type != entity.OrganizationEventUpdated || event.Organization.Url != "https://edara-1.example.com/v2/receive" {
+		t.Fatalf("event after sync = %s %s, want %s with the new URL", event.Type, event.Organization.Url, entity.OrganizationEventUpdated)
+	}
+
+	// syncing unchanged upstream sends nothing
+	if err = c.MirrorSync(ctx); err != nil {
+		t.Fatalf("third sync: %v", err)
+	}
+	select {
+	case event = <-events:
+		t.Fatalf("unexpected %s event after sync without changes", event.Type)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// TestMirrorSync keeps serving the last synced organizations when upstream goes away, and serves nothing
+// from upstream whose answers are not signed by the expected key
+func TestMirrorSync(t *testing.T) {
+	ctx := context.Background()
+	_, err := api.NewAPIController(datastore.NewReadOnlyAccess()).MirrorStatus(ctx)
+	if !errors.Is(err, api.ErrMirrorNotConfigured) {
+		t.Fatalf("status of a registry that is not a mirror: %v", err)
+	}
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := api.NewResponseSigner(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := api.NewResponseSigner(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	source := datastore.NewReadOnlyAccess()
+	source.Replace([]*entity.Organization{{
+		Id:              1,
+		Name:            "Edara 1",
+		Label:           "Edara 1",
+		Type:            entity.SRD,
+		Url:             "https://edara-1.example.com/receive",
+		OrganizationKey: entity.OrganizationKey{PublicKey: "key", Algorithm: entity.KeyAlgorithmEcdsaP256Sha256, Fingerprint: "f1"},
+		State:           entity.EntityStateEnabled,
+		CreateTs:        now,
+		UpdateTs:        now,
+		Version:         1,
+	}})
+	upstream := newUpstream(source, signer)
+	c := newMirror(t, upstream.URL, signingKey)
+
+	if err = c.Ping(ctx); err == nil {
+		t.Error("mirror is ready before its first sync")
+	}
+	if err = c.MirrorSync(ctx); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if err = c.Ping(ctx); err != nil {
+		t.Errorf("mirror is not ready after sync: %v", err)
+	}
+	synced, err := c.MirrorStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced.LastSyncTs == nil || synced.LastError != "" || synced.OrganizationCount != 1 || synced.UpstreamUrl != upstream.URL {
+		t.Fatalf("status after sync %+v", synced)
+	}
+	upstream.Close()
+	if err = c.MirrorSync(ctx); err == nil {
+		t.Fatal("synced from closed upstream")
+	}
+	status, err := c.MirrorStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastError == "" || status.LastSyncTs == nil || *status.LastSyncTs != *synced.LastSyncTs || status.OrganizationCount != 1 {
+		t.Errorf("status after failed sync %+v", status)
+	}
+	if items, _ := c.OrganizationDirectory(ctx); len(items) != 1 {
+		t.Errorf("mirror serves %d organizations after failed sync, want the last synced one", len(items))
+	}
+
+	forged := newUpstream(source, otherSigner)
+	defer forged.Close()
+	c = newMirror(t, forged.URL, signingKey)
+	if err = c.MirrorSync(ctx); err == nil {
+		t.Fatal("synced from upstream signing with another key")
+	}
+	status, err = c.MirrorStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastSyncTs != nil || status.LastAttemptTs == nil || status.LastError == "" {
+		t.Errorf("status after failed sync %+v", status)
+	}
+	items, err := c.OrganizationDirectory(ctx)
+	if err != nil || len(items) != 0 {
+		t.Errorf("mirror serves %d organizations from unverified upstream, error %v", len(items), err)
+	}
+}
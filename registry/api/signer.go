@@ -0,0 +1,58 @@
+package api
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+// ResponseSigner signs response bodies, verifiers check them with the key published at the signing key route
+type ResponseSigner struct {
+	signer crypto.Signer
+	key    *entity.SigningKeyResponse
+}
+
+// NewResponseSigner accepts key of any algorithm organization keys may have
+func NewResponseSigner(signer crypto.Signer) (rs *ResponseSigner, err error) {
+	alg, err := signing.Algorithm(signer.Public())
+	if err != nil {
+		return
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return
+	}
+	fingerprint, err := publicKeyHash(signer.Public())
+	if err != nil {
+		return
+	}
+	return &ResponseSigner{
+		signer: signer,
+		key: &entity.SigningKeyResponse{
+			PublicKey:      string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			KeyAlgorithm:   alg,
+			KeyFingerprint: fingerprint,
+		},
+	}, nil
+}
+
+// SetResponseSigner makes responses signed, key status queries answer 404 while rs is nil
+func (api *APIController) SetResponseSigner(rs *ResponseSigner) {
+	api.signer = rs
+}
+
+// SignResponse returns base64 signature over body, empty when signing is not configured
+func (api *APIController) SignResponse(body []byte) (signature string, err error) {
+	if api.signer == nil {
+		return "", nil
+	}
+	sig, err := signing.Sign(api.signer.signer, body)
+	if err != nil {
+		return
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
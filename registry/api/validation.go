@@ -0,0 +1,148 @@
+package api
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"net/url"
+	"unicode"
+
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+const (
+	organizationNameMaxLen  = 300
+	organizationLabelMaxLen = 512
+	organizationUrlMaxLen   = 900
+)
+
+// validator collects field errors, so that client gets all of them in one response
+type validator struct {
+	details []FieldError
+}
+
+func (v *validator) add(field, reason string) {
+	v.details = append(v.details, FieldError{Field: field, Reason: reason})
+}
+
+// err returns ErrValidation with collected details, nil if there are none
+func (v *validator) err() error {
+	if len(v.details) == 0 {
+		return nil
+	}
+	return ErrValidation.WithDetails(v.details...)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *validator) requiredString(field, value string, maxLen int) bool {
+	if value == "" {
+		v.add(field, FieldReasonRequired)
+		return false
+	}
+	if len(value) > maxLen {
+		v.add(field, FieldReasonTooLong)
+		return false
+	}
+	return true
+}
+
+func (v *validator) organizationFields(name, label string, dmsType entity.DMSType, orgUrl string) {
+	if v.requiredString("name", name, organizationNameMaxLen) && !isASCII(name) {
+		v.add("name", FieldReasonNotASCII)
+	}
+	v.requiredString("label", label, organizationLabelMaxLen)
+	if dmsType == "" {
+		v.add("type", FieldReasonRequired)
+	} else if !dmsType.Valid() {
+		v.add("type", FieldReasonInvalidValue)
+	}
+	if v.requiredString("url", orgUrl, organizationUrlMaxLen) {
+		u, err := url.Parse(orgUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.add("url", FieldReasonInvalidFormat)
+		}
+	}
+}
+
+func (v *validator) publicKey(field, publicKey string) {
+	if publicKey == "" {
+		v.add(field, FieldReasonRequired)
+		return
+	}
+	key, ok := parsePublicKey(publicKey)
+	if !ok {
+		v.add(field, FieldReasonInvalidFormat)
+		return
+	}
+	// RSA, ECDSA P-256/P-384 and Ed25519 only, other curves and key types cannot sign X-Signature
+	if _, err := signing.Algorithm(key); err != nil {
+		v.add(field, FieldReasonInvalidValue)
+	}
+}
+
+// parsePublicKey accepts PEM encoded PKIX or PKCS #1 public key
+func parsePublicKey(publicKey string) (key crypto.PublicKey, ok bool) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, false
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, true
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, true
+	}
+	return nil, false
+}
+
+// publicKeyHash is hex SHA-256 of PKIX encoding, so that the same key in PKCS #1 or differently wrapped PEM matches
+func publicKeyHash(key crypto.PublicKey) (hash string, err error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type uniqueField struct {
+	field   string
+	appCode string
+}
+
+var uniqueConstraintFields = map[string]uniqueField{
+	"uq_organization_name":            {"name", AppCodeOrganizationNameConflict},
+	"uq_organization_url":             {"url", AppCodeOrganizationUrlConflict},
+	"uq_organization_key_fingerprint": {"public_key", AppCodeOrganizationPublicKeyConflict},
+	"uq_admin_username":               {"username", AppCodeConflict},
+}
+
+// storeError maps datastore error to api error: unique violations become 409 naming the conflicting field,
+// writes to read-only datastore are 405, anything else is internal server error
+func storeError(err error) error {
+	if errors.Is(err, datastore.ErrReadOnly) {
+		return ErrReadOnly.Wrap(err)
+	}
+	constraint, ok := datastore.UniqueViolation(err)
+	if !ok {
+		return ErrInternalServerError.Wrap(err)
+	}
+	f, ok := uniqueConstraintFields[constraint]
+	if !ok {
+		return ErrConflict.Wrap(err)
+	}
+	return ErrConflict.WithAppCode(f.appCode).WithDetails(FieldError{Field: f.field, Reason: FieldReasonConflict}).Wrap(err)
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheEntry is a cached successful response body with its validator
+type CacheEntry struct {
+	ETag      string `json:"etag"`
+	Body      []byte `json:"body"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Cache stores responses by request path, so that unchanged data is revalidated with If-None-Match
+// and last known data is still available when no registry is reachable
+type Cache interface {
+	Get(key string) (entry *CacheEntry, ok bool)
+	Put(key string, entry *CacheEntry)
+}
+
+type memoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]*CacheEntry
+}
+
+func NewMemoryCache() Cache {
+	return &memoryCache{entries: make(map[string]*CacheEntry)}
+}
+
+func (c *memoryCache) Get(key string) (entry *CacheEntry, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok = c.entries[key]
+	return
+}
+
+func (c *memoryCache) Put(key string, entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+type fileCache struct {
+	dir string
+	mem Cache
+}
+
+// NewFileCache keeps entries in memory and persists them as files in dir, so that they survive restarts
+func NewFileCache(dir string) (Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &fileCache{dir: dir, mem: NewMemoryCache()}, nil
+}
+
+func (c *fileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *fileCache) Get(key string) (entry *CacheEntry, ok bool) {
+	if entry, ok = c.mem.Get(key); ok {
+		return
+	}
+	raw, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	entry = &CacheEntry{}
+	if err = json.Unmarshal(raw, entry); err != nil {
+		return nil, false
+	}
+	c.mem.Put(key, entry)
+	return entry, true
+}
+
+func (c *fileCache) Put(key string, entry *CacheEntry) {
+	c.mem.Put(key, entry)
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	tmp := c.path(key) + ".tmp"
+	if err = ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, c.path(key))
+}
@@ -0,0 +1,211 @@
+// Package client is a Go client for the registry API, for use by DMS vendors.
+//
+// It returns entity types, decodes the success envelope and error responses, revalidates cached
+// responses with If-None-Match, fails over between registry URLs and verifies response signatures.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+const (
+	HeaderSignature = "X-Registry-Signature"
+
+	pathOrganizations = "/api/v1/organizations"
+
+	defaultTimeout   = 15 * time.Second
+	maxResponseBytes = 32 << 20
+)
+
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type Client struct {
+	urls         []string
+	httpClient   *http.Client
+	cache        Cache
+	verifier     Verifier
+	staleOnError bool
+
+	mu        sync.Mutex
+	preferred int
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithCache replaces default in-memory cache, e.g. with NewFileCache
+func WithCache(cache Cache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithVerifier makes client reject responses without valid registry signature
+func WithVerifier(verifier Verifier) Option {
+	return func(c *Client) {
+		c.verifier = verifier
+	}
+}
+
+// WithStaleOnError makes client return last cached data when no registry URL is reachable
+func WithStaleOnError(stale bool) Option {
+	return func(c *Client) {
+		c.staleOnError = stale
+	}
+}
+
+// New creates client for registry base URLs, e.g. "https://registry.example.com".
+// URLs are tried in order; the one that answered last is tried first next time.
+func New(urls []string, opts ...Option) (c *Client, err error) {
+	if len(urls) == 0 {
+		return nil, ErrNoRegistryUrls
+	}
+	c = &Client{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		cache:      NewMemoryCache(),
+	}
+	for _, u := range urls {
+		c.urls = append(c.urls, strings.TrimRight(u, "/"))
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return
+}
+
+func (c *Client) OrganizationList(ctx context.Context) (items []*entity.OrganizationListResponse, err error) {
+	err = c.get(ctx, pathOrganizations, &items)
+	return
+}
+
+func (c *Client) Organization(ctx context.Context, id int) (item *entity.OrganizationResponse, err error) {
+	err = c.get(ctx, pathOrganizations+"/"+strconv.Itoa(id), &item)
+	return
+}
+
+// KeyValidity asks whether key with fingerprint is good for organization right now. Answers served stale on error
+// may be past NextUpdate, callers should not rely on those.
+func (c *Client) KeyValidity(ctx context.Context, organizationId int, fingerprint string) (item *entity.KeyValidityResponse, err error) {
+	err = c.get(ctx, pathOrganizations+"/"+strconv.Itoa(organizationId)+"/keys/"+url.PathEscape(fingerprint)+"/status", &item)
+	return
+}
+
+// get fetches path from registries in failover order and decodes envelope data into v
+func (c *Client) get(ctx context.Context, path string, v interface{}) (err error) {
+	c.mu.Lock()
+	start := c.preferred
+	c.mu.Unlock()
+	cached, hasCached := c.cache.Get(path)
+	var lastErr error
+	for i := 0; i < len(c.urls); i++ {
+		idx := (start + i) % len(c.urls)
+		var entry *CacheEntry
+		var retry bool
+		entry, retry, err = c.fetch(ctx, c.urls[idx]+path, cached)
+		if err == nil {
+			c.mu.Lock()
+			c.preferred = idx
+			c.mu.Unlock()
+			if entry != cached {
+				c.cache.Put(path, entry)
+			}
+			return decodeEnvelope(entry.Body, v)
+		}
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+	}
+	if c.staleOnError && hasCached {
+		return decodeEnvelope(cached.Body, v)
+	}
+	return errors.Wrap(lastErr, ErrAllRegistriesFailed.Error())
+}
+
+// fetch does conditional GET, retry tells whether another registry URL should be tried after err
+func (c *Client) fetch(ctx context.Context, url string, cached *CacheEntry) (entry *CacheEntry, retry bool, err error) {
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	var resp *http.Response
+	resp, err = c.httpClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, false, nil
+	}
+	var body []byte
+	body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, true, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := decodeError(resp.StatusCode, body)
+		return nil, apiErr.retryable(), apiErr
+	}
+	signature := resp.Header.Get(HeaderSignature)
+	if c.verifier != nil {
+		if signature == "" {
+			return nil, true, ErrSignatureMissing
+		}
+		if err = c.verifier.Verify(body, signature); err != nil {
+			return nil, true, err
+		}
+	}
+	entry = &CacheEntry{
+		ETag:      resp.Header.Get("ETag"),
+		Body:      body,
+		Signature: signature,
+	}
+	return entry, false, nil
+}
+
+func decodeEnvelope(body []byte, v interface{}) (err error) {
+	env := &envelope{}
+	if err = json.Unmarshal(body, env); err != nil || !env.Success {
+		return ErrInvalidResponse
+	}
+	if err = json.Unmarshal(env.Data, v); err != nil {
+		return errors.Wrap(ErrInvalidResponse, err.Error())
+	}
+	return nil
+}
+
+func decodeError(statusCode int, body []byte) *Error {
+	env := &envelope{}
+	apiErr := &Error{}
+	if json.Unmarshal(body, env) != nil || json.Unmarshal(env.Data, apiErr) != nil {
+		apiErr = &Error{Message: http.StatusText(statusCode)}
+	}
+	apiErr.StatusCode = statusCode
+	return apiErr
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+// fakeRegistry serves organization 1 named name, signing responses with key unless badSignature is set
+type fakeRegistry struct {
+	name         string
+	etag         string
+	key          *ecdsa.PrivateKey
+	badSignature bool
+	status       int
+	requests     int32
+	// If-None-Match of the last request
+	ifNoneMatch atomic.Value
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&f.requests, 1)
+	f.ifNoneMatch.Store(r.Header.Get("If-None-Match"))
+	if f.status != 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.status)
+		_, _ = w.Write([]byte(`{"success":false,"data":{"error_code":503,"error_msg":"Service unavailable","code":"SERVICE_UNAVAILABLE","request_id":"r1"}}`))
+		return
+	}
+	if r.URL.Path != pathOrganizations+"/1" {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"success":false,"data":{"error_code":404,"error_msg":"Not found","code":"NOT_FOUND"}}`))
+		return
+	}
+	if f.etag != "" && r.Header.Get("If-None-Match") == f.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	data, _ := json.Marshal(&entity.OrganizationResponse{Id: 1, Name: f.name})
+	body, _ := json.Marshal(&envelope{Success: true, Data: data})
+	if f.key != nil {
+		message := body
+		if f.badSignature {
+			message = append([]byte(nil), body...)
+			message[0] = ' '
+		}
+		sig, _ := signing.Sign(f.key, message)
+		w.Header().Set(HeaderSignature, base64.StdEncoding.EncodeToString(sig))
+	}
+	if f.etag != "" {
+		w.Header().Set("ETag", f.etag)
+	}
+	_, _ = w.Write(body)
+}
+
+func serve(t *testing.T, f *fakeRegistry) string {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func newKey(t *testing.T) (*ecdsa.PrivateKey, Verifier) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifierFromPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, verifier
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	failing := &fakeRegistry{status: http.StatusServiceUnavailable}
+	secondary := &fakeRegistry{name: "secondary"}
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+	c, err := New([]string{refused.URL, serve(t, failing), serve(t, secondary) + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := c.Organization(ctx, 1)
+	if err != nil || item.Name != "secondary" {
+		t.Fatalf("Organization = %+v, %v, want the one of secondary", item, err)
+	}
+	// the registry that answered is tried first next time
+	if _, err = c.Organization(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&failing.requests); n != 1 {
+		t.Errorf("failing registry got %d requests, want 1", n)
+	}
+	if n := atomic.LoadInt32(&secondary.requests); n != 2 {
+		t.Errorf("secondary registry got %d requests, want 2", n)
+	}
+
+	// not found is an answer, other registries are not asked
+	_, err = c.Organization(ctx, 2)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "NOT_FOUND" {
+		t.Fatalf("Organization(2) = %v, want not found error", err)
+	}
+	if n := atomic.LoadInt32(&failing.requests); n != 1 {
+		t.Errorf("not found was retried on another registry")
+	}
+
+	c, err = New([]string{serve(t, failing)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Organization(ctx, 1)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.RequestId != "r1" {
+		t.Errorf("Organization with every registry failing = %v, want the last registry error", err)
+	}
+}
+
+func TestRevalidation(t *testing.T) {
+	ctx := context.Background()
+	key, verifier := newKey(t)
+	registry := &fakeRegistry{name: "cached", etag: `"v1"`, key: key}
+	cache := NewMemoryCache()
+	c, err := New([]string{serve(t, registry)}, WithCache(cache), WithVerifier(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Organization(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.ifNoneMatch.Load(); got != "" {
+		t.Errorf("first request sent If-None-Match %q", got)
+	}
+	entry, ok := cache.Get(pathOrganizations + "/1")
+	if !ok || entry.ETag != `"v1"` || entry.Signature == "" {
+		t.Fatalf("cache entry = %+v, %v", entry, ok)
+	}
+
+	// 304 carries no body and no signature, the verified cached body is served
+	item, err := c.Organization(ctx, 1)
+	if err != nil || item.Name != "cached" {
+		t.Fatalf("Organization after 304 = %+v, %v", item, err)
+	}
+	if got := registry.ifNoneMatch.Load(); got != `"v1"` {
+		t.Errorf("revalidation sent If-None-Match %q, want %q", got, `"v1"`)
+	}
+	if again, _ := cache.Get(pathOrganizations + "/1"); again != entry {
+		t.Error("cache entry replaced on 304")
+	}
+
+	// changed data replaces the cache entry
+	registry.etag, registry.name = `"v2"`, "changed"
+	if item, err = c.Organization(ctx, 1); err != nil || item.Name != "changed" {
+		t.Fatalf("Organization after change = %+v, %v", item, err)
+	}
+	if entry, _ = cache.Get(pathOrganizations + "/1"); entry.ETag != `"v2"` {
+		t.Errorf("cache entry ETag = %s, want %s", entry.ETag, `"v2"`)
+	}
+
+	// stale data is served only when asked for
+	registry.status = http.StatusServiceUnavailable
+	if _, err = c.Organization(ctx, 1); err == nil {
+		t.Error("Organization with registry down succeeded without WithStaleOnError")
+	}
+	c, err = New([]string{serve(t, &fakeRegistry{status: http.StatusServiceUnavailable})}, WithCache(cache), WithStaleOnError(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item, err = c.Organization(ctx, 1); err != nil || item.Name != "changed" {
+		t.Errorf("stale Organization = %+v, %v", item, err)
+	}
+}
+
+func TestSignatureVerification(t *testing.T) {
+	ctx := context.Background()
+	key, verifier := newKey(t)
+	otherKey, _ := newKey(t)
+	tests := []struct {
+		name     string
+		registry *fakeRegistry
+		wantErr  error
+	}{
+		{"valid", &fakeRegistry{name: "signed", key: key}, nil},
+		{"body changed", &fakeRegistry{name: "signed", key: key, badSignature: true}, ErrSignatureInvalid},
+		{"other key", &fakeRegistry{name: "signed", key: otherKey}, ErrSignatureInvalid},
+		{"unsigned", &fakeRegistry{name: "signed"}, ErrSignatureMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New([]string{serve(t, tt.registry)}, WithVerifier(verifier))
+			if err != nil {
+				t.Fatal(err)
+			}
+			item, err := c.Organization(ctx, 1)
+			if tt.wantErr == nil {
+				if err != nil || item.Name != "signed" {
+					t.Fatalf("Organization = %+v, %v", item, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Organization = %+v, %v, want %v", item, err, tt.wantErr)
+			}
+		})
+	}
+
+	// a registry answering with bad signature is skipped for the next one
+	bad := &fakeRegistry{name: "forged", key: otherKey}
+	good := &fakeRegistry{name: "signed", key: key}
+	c, err := New([]string{serve(t, bad), serve(t, good)}, WithVerifier(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item, err := c.Organization(ctx, 1); err != nil || item.Name != "signed" {
+		t.Errorf("Organization = %+v, %v, want the one with valid signature", item, err)
+	}
+}
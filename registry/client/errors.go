@@ -0,0 +1,38 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoRegistryUrls      = errors.New("no registry URLs configured")
+	ErrInvalidResponse     = errors.New("invalid registry response")
+	ErrSignatureMissing    = errors.New("registry response is not signed")
+	ErrSignatureInvalid    = errors.New("registry response signature is invalid")
+	ErrAllRegistriesFailed = errors.New("all registry URLs failed")
+)
+
+// FieldError is a field level validation detail of Error
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// Error is an error response returned by registry
+type Error struct {
+	StatusCode int          `json:"error_code"`
+	Message    string       `json:"error_msg"`
+	Code       string       `json:"code"`
+	Details    []FieldError `json:"details,omitempty"`
+	RequestId  string       `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("registry error %d %s (request id %s)", e.StatusCode, e.Code, e.RequestId)
+}
+
+// retryable reports whether the same request may succeed on another registry URL
+func (e *Error) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == 429
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/snapshot"
+)
+
+// LoadSnapshot verifies signed snapshot bundle with verifier and puts its organization list and organizations
+// into cache, as if they were fetched from the registry. Clients created with that cache and WithStaleOnError
+// serve them while no registry is reachable. Entries already in cache are replaced, so load only bundles newer
+// than the data cache was last filled with.
+func LoadSnapshot(cache Cache, raw []byte, verifier Verifier) (manifest *entity.SnapshotManifest, err error) {
+	s, err := snapshot.Open(raw, verifier)
+	if err != nil {
+		return
+	}
+	list := make([]*entity.OrganizationListResponse, 0, len(s.Organizations))
+	entries := make(map[string]*CacheEntry, len(s.Organizations)+1)
+	for _, o := range s.Organizations {
+		list = append(list, &entity.OrganizationListResponse{
+			Id:             o.Id,
+			Name:           o.Name,
+			Label:          o.Label,
+			Type:           o.Type,
+			Url:            o.Url,
+			PublicKey:      o.PublicKey,
+			KeyAlgorithm:   o.KeyAlgorithm,
+			KeyFingerprint: o.KeyFingerprint,
+			CertExpireTs:   o.CertExpireTs,
+		})
+		if entries[pathOrganizations+"/"+strconv.Itoa(o.Id)], err = snapshotEntry(o); err != nil {
+			return
+		}
+	}
+	if entries[pathOrganizations], err = snapshotEntry(list); err != nil {
+		return
+	}
+	for key, entry := range entries {
+		cache.Put(key, entry)
+	}
+	return &s.Manifest, nil
+}
+
+// snapshotEntry wraps data in success envelope, without ETag so that the registry sends it in full once reachable
+func snapshotEntry(data interface{}) (entry *CacheEntry, err error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	body, err := json.Marshal(&envelope{Success: true, Data: raw})
+	if err != nil {
+		return
+	}
+	return &CacheEntry{Body: body}, nil
+}
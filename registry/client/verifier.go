@@ -0,0 +1,99 @@
+package client
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+// Verifier checks registry signature sent in X-Registry-Signature header over the response body
+type Verifier interface {
+	Verify(body []byte, signature string) error
+}
+
+type rsaVerifier struct {
+	key *rsa.PublicKey
+}
+
+// NewRSAVerifier verifies base64 RSA PKCS#1 v1.5 signatures over SHA-256 of the body,
+// the same scheme DMS nodes use for X-Signature
+func NewRSAVerifier(key *rsa.PublicKey) Verifier {
+	return &rsaVerifier{key: key}
+}
+
+// NewRSAVerifierFromPEM parses registry public key in PKIX or PKCS#1 PEM form
+func NewRSAVerifierFromPEM(publicKeyPem []byte) (Verifier, error) {
+	block, _ := pem.Decode(publicKeyPem)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return NewRSAVerifier(key), nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing public key")
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not RSA")
+	}
+	return NewRSAVerifier(key), nil
+}
+
+func (v *rsaVerifier) Verify(body []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	digest := sha256.Sum256(body)
+	if rsa.VerifyPKCS1v15(v.key, crypto.SHA256, digest[:], sig) != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+type keyVerifier struct {
+	alg entity.KeyAlgorithm
+	key crypto.PublicKey
+}
+
+// NewVerifierFromPEM parses PKIX PEM key of any algorithm the registry accepts, such as key_algorithm
+// and public_key of an organization, and verifies signatures with the scheme of that algorithm
+func NewVerifierFromPEM(publicKeyPem []byte) (Verifier, error) {
+	block, _ := pem.Decode(publicKeyPem)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return NewRSAVerifier(key), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing public key")
+	}
+	alg, err := signing.Algorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	return &keyVerifier{alg: alg, key: key}, nil
+}
+
+func (v *keyVerifier) Verify(body []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if signing.Verify(v.alg, v.key, body, sig) != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
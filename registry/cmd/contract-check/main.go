@@ -0,0 +1,28 @@
+// contract-check runs every registry HTTP route against an in-process server and checks it against the OpenAPI spec,
+// exits with 1 when any check fails
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/contract"
+)
+
+func main() {
+	log.SetOutput(io.Discard)
+	failed, err := contract.Run(context.Background(), os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if failed > 0 {
+		fmt.Printf("%d check(s) failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("all checks passed")
+}
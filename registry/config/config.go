@@ -3,93 +3,618 @@ package config
 import (
 	"encoding/json"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
+// EnvPrefix is prepended to upper-cased json name of a field to get its environment override, e.g. REGISTRY_DB_CONN
+const EnvPrefix = "REGISTRY_"
+
+// Fields tagged with reload:"true" are applied on Store.Reload, the rest need restart
 type Config struct {
 	DbConn           string   `json:"db_conn"`
 	EndpointUrl      string   `json:"endpoint_url"`
 	ListenAddress    string   `json:"listen_address"`
 	AllowedReferrers []string `json:"allowed_referrers"`
+	// gRPC API listens here with the same TLS settings, disabled when empty; a client may keep at most
+	// GrpcMaxWatchStreams watch streams open, DefaultGrpcMaxWatchStreams is used when 0
+	GrpcListenAddress   string `json:"grpc_listen_address"`
+	GrpcMaxWatchStreams int    `json:"grpc_max_watch_streams" reload:"true"`
+
+	// native TLS, when TlsCertFile is empty plain HTTP is served
+	TlsCertFile string `json:"tls_cert_file"`
+	TlsKeyFile  string `json:"tls_key_file"`
+	// CA bundle to verify DMS node client certificates against, client certificates are not requested when empty
+	TlsClientCaFile string `json:"tls_client_ca_file"`
+	// reject connections without valid client certificate, otherwise it is verified only if presented
+	TlsClientAuthRequired bool `json:"tls_client_auth_required"`
+
+	// organization keys can be given as X.509 certificates chaining to CAs in PkiRootCaFile (PEM bundle),
+	// through intermediates in optional PkiIntermediateCaFile; only bare public keys are accepted when empty
+	PkiRootCaFile         string `json:"pki_root_ca_file"`
+	PkiIntermediateCaFile string `json:"pki_intermediate_ca_file"`
+
+	// registry issues DMS node certificates signed by CaKeyFile (PEM private key) for CaCertFile when both are set,
+	// they are valid for CaCertValidityHours, DefaultCaCertValidityHours is used when 0
+	CaCertFile          string `json:"ca_cert_file"`
+	CaKeyFile           string `json:"ca_key_file"`
+	CaCertValidityHours int    `json:"ca_cert_validity_hours"`
+
+	// responses are signed with SigningKeyFile (PEM private key) when set, key status queries need it; their answers
+	// may be cached for KeyStatusMaxAgeSeconds, DefaultKeyStatusMaxAgeSeconds is used when 0
+	SigningKeyFile         string `json:"signing_key_file"`
+	KeyStatusMaxAgeSeconds int    `json:"key_status_max_age_seconds" reload:"true"`
+
+	// private keys are read from SigningKeyFile and CaKeyFile when KeyBackend is file or empty; with pkcs11 they stay
+	// in token labeled Pkcs11TokenLabel of Pkcs11Module library and are found by SigningKeyLabel and CaKeyLabel
+	KeyBackend       string `json:"key_backend"`
+	Pkcs11Module     string `json:"pkcs11_module"`
+	Pkcs11TokenLabel string `json:"pkcs11_token_label"`
+	Pkcs11Pin        string `json:"pkcs11_pin"`
+	SigningKeyLabel  string `json:"signing_key_label"`
+	CaKeyLabel       string `json:"ca_key_label"`
+
+	// when SnapshotFile is set the registry serves that bundle read-only instead of the database, and reads it again
+	// on SIGHUP; bundles must be signed by the registry key in SnapshotKeyFile (PEM public key)
+	SnapshotFile    string `json:"snapshot_file"`
+	SnapshotKeyFile string `json:"snapshot_key_file"`
+
+	// when MirrorUpstreamUrl is set the registry serves organizations of that registry read-only instead of the
+	// database, pulling them every MirrorSyncIntervalSeconds (DefaultMirrorSyncIntervalSeconds when 0); upstream
+	// responses must be signed by the key in MirrorKeyFile (PEM public key)
+	MirrorUpstreamUrl         string `json:"mirror_upstream_url"`
+	MirrorKeyFile             string `json:"mirror_key_file"`
+	MirrorSyncIntervalSeconds int    `json:"mirror_sync_interval_seconds"`
+
+	// on shutdown readiness fails for ShutdownDelaySeconds while requests are still served, so that load balancers
+	// stop routing here, then in-flight requests are given ShutdownGraceSeconds to finish
+	ShutdownDelaySeconds int `json:"shutdown_delay_seconds"`
+	ShutdownGraceSeconds int `json:"shutdown_grace_seconds"`
+
+	// CIDRs of reverse proxies whose Forwarded, X-Forwarded-For and X-Real-IP headers are honored
+	TrustedProxies []string `json:"trusted_proxies" reload:"true"`
+	// TrustedProxies parsed on load, since every request needs them
+	trustedProxyNets []*net.IPNet
+
+	// token bucket per client certificate common name (mTLS) or client address, disabled when RateLimitPerSecond is 0
+	RateLimitPerSecond float64 `json:"rate_limit_per_second" reload:"true"`
+	RateLimitBurst     int     `json:"rate_limit_burst" reload:"true"`
+	// requests with larger body are rejected with 413, DefaultMaxRequestBodyBytes is used when 0
+	MaxRequestBodyBytes int64 `json:"max_request_body_bytes" reload:"true"`
+
+	// enabled organization urls are probed every ProbeIntervalSeconds, disabled when 0;
+	// a probe gives up after ProbeTimeoutSeconds, DefaultProbeTimeoutSeconds is used when 0
+	ProbeIntervalSeconds int `json:"probe_interval_seconds"`
+	ProbeTimeoutSeconds  int `json:"probe_timeout_seconds"`
+
+	// text or json
+	LogFormat string `json:"log_format"`
+	// logrus level name, info when empty
+	LogLevel string `json:"log_level" reload:"true"`
+	// stdout, stderr or file path, stdout when empty
+	LogOutput string `json:"log_output"`
+	// same as LogOutput, access log is disabled when empty
+	AccessLogOutput string `json:"access_log_output"`
+
+	// OpenTelemetry spans are exported over OTLP/HTTP to TracingEndpoint (host:port) only when enabled
+	TracingEnabled     bool    `json:"tracing_enabled"`
+	TracingEndpoint    string  `json:"tracing_endpoint"`
+	TracingInsecure    bool    `json:"tracing_insecure"`
+	TracingSampleRatio float64 `json:"tracing_sample_ratio"`
 }
 
-var Conf *Config
-var isFirst bool = true
+const (
+	DefaultShutdownGraceSeconds      = 30
+	DefaultMaxRequestBodyBytes       = 1 << 20
+	DefaultProbeTimeoutSeconds       = 10
+	DefaultCaCertValidityHours       = 72
+	DefaultKeyStatusMaxAgeSeconds    = 300
+	DefaultMirrorSyncIntervalSeconds = 60
+	DefaultGrpcMaxWatchStreams       = 4
+)
 
-func ReadConfig(source string) (err error) {
-	if !isFirst {
-		for {
-			if Conf != nil {
-				break
-			}
-			time.Sleep(10 * time.Millisecond)
+const (
+	KeyBackendFile   = "file"
+	KeyBackendPkcs11 = "pkcs11"
+)
+
+// SigningKeyRef names the response signing key in KeyBackend, empty when responses are not signed
+func (c *Config) SigningKeyRef() string {
+	if c.KeyBackend == KeyBackendPkcs11 {
+		return c.SigningKeyLabel
+	}
+	return c.SigningKeyFile
+}
+
+// CaKeyRef names the CA key in KeyBackend
+func (c *Config) CaKeyRef() string {
+	if c.KeyBackend == KeyBackendPkcs11 {
+		return c.CaKeyLabel
+	}
+	return c.CaKeyFile
+}
+
+// ReadOnly tells whether registry serves data of another registry, rejecting writes
+func (c *Config) ReadOnly() bool {
+	return c.SnapshotFile != "" || c.MirrorUpstreamUrl != ""
+}
+
+func (c *Config) MaxRequestBody() int64 {
+	if c.MaxRequestBodyBytes == 0 {
+		return DefaultMaxRequestBodyBytes
+	}
+	return c.MaxRequestBodyBytes
+}
+
+// TrustedProxyNets returns TrustedProxies parsed on load, single addresses are treated as /32 or /128 networks
+func (c *Config) TrustedProxyNets() []*net.IPNet {
+	return c.trustedProxyNets
+}
+
+// prepare fills derived fields of valid config
+func (c *Config) prepare() {
+	c.trustedProxyNets = nil
+	for _, p := range c.TrustedProxies {
+		if n, err := parseCIDR(p); err == nil {
+			c.trustedProxyNets = append(c.trustedProxyNets, n)
 		}
-		if Conf != nil {
-			return nil
-		} else {
-			return errors.New("error Conf not found!!!")
+	}
+}
+
+func parseCIDR(s string) (n *net.IPNet, err error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, errors.Errorf("invalid address %q", s)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = 8 * net.IPv4len
 		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err = net.ParseCIDR(s)
+	return
+}
+
+func (c *Config) ShutdownDelay() time.Duration {
+	return time.Duration(c.ShutdownDelaySeconds) * time.Second
+}
+
+func (c *Config) ShutdownGrace() time.Duration {
+	if c.ShutdownGraceSeconds == 0 {
+		return DefaultShutdownGraceSeconds * time.Second
 	}
-	isFirst = false
+	return time.Duration(c.ShutdownGraceSeconds) * time.Second
+}
+
+func (c *Config) ProbeInterval() time.Duration {
+	return time.Duration(c.ProbeIntervalSeconds) * time.Second
+}
+
+func (c *Config) CaCertValidity() time.Duration {
+	if c.CaCertValidityHours == 0 {
+		return DefaultCaCertValidityHours * time.Hour
+	}
+	return time.Duration(c.CaCertValidityHours) * time.Hour
+}
+
+func (c *Config) KeyStatusMaxAge() time.Duration {
+	if c.KeyStatusMaxAgeSeconds == 0 {
+		return DefaultKeyStatusMaxAgeSeconds * time.Second
+	}
+	return time.Duration(c.KeyStatusMaxAgeSeconds) * time.Second
+}
+
+func (c *Config) MirrorSyncInterval() time.Duration {
+	if c.MirrorSyncIntervalSeconds == 0 {
+		return DefaultMirrorSyncIntervalSeconds * time.Second
+	}
+	return time.Duration(c.MirrorSyncIntervalSeconds) * time.Second
+}
+
+func (c *Config) MaxWatchStreams() int {
+	if c.GrpcMaxWatchStreams == 0 {
+		return DefaultGrpcMaxWatchStreams
+	}
+	return c.GrpcMaxWatchStreams
+}
+
+func (c *Config) ProbeTimeout() time.Duration {
+	if c.ProbeTimeoutSeconds == 0 {
+		return DefaultProbeTimeoutSeconds * time.Second
+	}
+	return time.Duration(c.ProbeTimeoutSeconds) * time.Second
+}
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Load reads config from json file, applies environment overrides and validates the result
+func Load(source string) (conf *Config, err error) {
+	clog := log.WithFields(log.Fields{
+		"method": "config.Load",
+		"source": source,
+	})
 	var raw []byte
 	raw, err = ioutil.ReadFile(source)
 	if err != nil {
-		wMsg := "error reading config from file, creating new sample"
-		log.Warn(wMsg)
-
-		err = createDefaultConfig(source)
-		if err != nil {
-			eMsg := "error creating config file"
-			log.WithError(err).Error(eMsg)
-			err = errors.Wrap(err, eMsg)
-			return
-		}
-
-		raw, err = ioutil.ReadFile(source)
-		if err != nil {
-			eMsg := "error reading config from file"
-			log.WithError(err).Error(eMsg)
-			err = errors.Wrap(err, eMsg)
-			return
-		}
+		eMsg := "error reading config from file"
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		return
 	}
-	err = json.Unmarshal(raw, &Conf)
+	conf = &Config{}
+	err = json.Unmarshal(raw, conf)
 	if err != nil {
 		eMsg := "error parsing config from json"
-		log.WithError(err).Error(eMsg)
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		conf = nil
+		return
+	}
+	err = applyEnv(conf, os.LookupEnv)
+	if err != nil {
+		eMsg := "error applying environment overrides"
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		conf = nil
+		return
+	}
+	err = conf.Validate()
+	if err != nil {
+		eMsg := "error validating config"
+		clog.WithError(err).Error(eMsg)
 		err = errors.Wrap(err, eMsg)
-		Conf = nil
+		conf = nil
 		return
 	}
+	conf.prepare()
 	return
 }
 
-func createDefaultConfig(source string) (err error) {
-	c := Config{
-		DbConn:           "user=test password=test dbname=test sslmode=disable",
-		EndpointUrl:      "http://127.0.0.1:5080",
-		ListenAddress:    "127.0.0.1:5080",
-		AllowedReferrers: []string{"localhost"},
+func invalid(field, reason string) error {
+	return errors.Wrapf(ErrInvalidConfig, "%s: %s", field, reason)
+}
+
+func fileExists(source string) bool {
+	info, err := os.Stat(source)
+	return err == nil && !info.IsDir()
+}
+
+// Validate checks every field, returns first problem found
+func (c *Config) Validate() (err error) {
+	if !c.ReadOnly() && c.DbConn == "" {
+		return invalid("db_conn", "is empty")
+	}
+	if c.DbConn != "" {
+		if _, err = pgxpool.ParseConfig(c.DbConn); err != nil {
+			return invalid("db_conn", "is not a valid connection string")
+		}
+	}
+	if _, _, err = net.SplitHostPort(c.ListenAddress); err != nil {
+		return invalid("listen_address", err.Error())
+	}
+	if c.GrpcListenAddress != "" {
+		if _, _, err = net.SplitHostPort(c.GrpcListenAddress); err != nil {
+			return invalid("grpc_listen_address", err.Error())
+		}
+		if c.GrpcListenAddress == c.ListenAddress {
+			return invalid("grpc_listen_address", "must differ from listen_address")
+		}
+	}
+	if c.EndpointUrl != "" {
+		u, pErr := url.Parse(c.EndpointUrl)
+		if pErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalid("endpoint_url", "must be absolute http(s) URL")
+		}
+	}
+	if c.TlsCertFile != "" || c.TlsKeyFile != "" {
+		if !fileExists(c.TlsCertFile) {
+			return invalid("tls_cert_file", "file not found")
+		}
+		if !fileExists(c.TlsKeyFile) {
+			return invalid("tls_key_file", "file not found")
+		}
+	}
+	if c.TlsClientCaFile != "" {
+		if c.TlsCertFile == "" {
+			return invalid("tls_client_ca_file", "requires tls_cert_file")
+		}
+		if !fileExists(c.TlsClientCaFile) {
+			return invalid("tls_client_ca_file", "file not found")
+		}
+	}
+	if c.TlsClientAuthRequired && c.TlsClientCaFile == "" {
+		return invalid("tls_client_auth_required", "requires tls_client_ca_file")
+	}
+	if c.PkiRootCaFile != "" && !fileExists(c.PkiRootCaFile) {
+		return invalid("pki_root_ca_file", "file not found")
+	}
+	if c.PkiIntermediateCaFile != "" {
+		if c.PkiRootCaFile == "" {
+			return invalid("pki_intermediate_ca_file", "requires pki_root_ca_file")
+		}
+		if !fileExists(c.PkiIntermediateCaFile) {
+			return invalid("pki_intermediate_ca_file", "file not found")
+		}
+	}
+	if err = c.validateKeys(); err != nil {
+		return
+	}
+	if c.CaCertFile != "" && !fileExists(c.CaCertFile) {
+		return invalid("ca_cert_file", "file not found")
+	}
+	if c.SnapshotFile != "" && !fileExists(c.SnapshotFile) {
+		return invalid("snapshot_file", "file not found")
+	}
+	if c.SnapshotKeyFile != "" && !fileExists(c.SnapshotKeyFile) {
+		return invalid("snapshot_key_file", "file not found")
+	}
+	if c.SnapshotFile != "" && c.SnapshotKeyFile == "" {
+		return invalid("snapshot_key_file", "required with snapshot_file")
+	}
+	if c.MirrorUpstreamUrl != "" {
+		u, pErr := url.Parse(c.MirrorUpstreamUrl)
+		if pErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalid("mirror_upstream_url", "must be absolute http(s) URL")
+		}
+		if c.SnapshotFile != "" {
+			return invalid("mirror_upstream_url", "must not be set together with snapshot_file")
+		}
+		if c.MirrorKeyFile == "" {
+			return invalid("mirror_key_file", "required with mirror_upstream_url")
+		}
+	}
+	if c.MirrorKeyFile != "" && !fileExists(c.MirrorKeyFile) {
+		return invalid("mirror_key_file", "file not found")
+	}
+	if c.MirrorSyncIntervalSeconds < 0 {
+		return invalid("mirror_sync_interval_seconds", "must not be negative")
+	}
+	if c.CaCertValidityHours < 0 {
+		return invalid("ca_cert_validity_hours", "must not be negative")
+	}
+	if c.KeyStatusMaxAgeSeconds < 0 {
+		return invalid("key_status_max_age_seconds", "must not be negative")
+	}
+	if c.ShutdownDelaySeconds < 0 {
+		return invalid("shutdown_delay_seconds", "must not be negative")
+	}
+	if c.ShutdownGraceSeconds < 0 {
+		return invalid("shutdown_grace_seconds", "must not be negative")
+	}
+	for _, p := range c.TrustedProxies {
+		if _, err = parseCIDR(p); err != nil {
+			return invalid("trusted_proxies", err.Error())
+		}
+	}
+	if c.GrpcMaxWatchStreams < 0 {
+		return invalid("grpc_max_watch_streams", "must not be negative")
+	}
+	if c.RateLimitPerSecond < 0 {
+		return invalid("rate_limit_per_second", "must not be negative")
+	}
+	if c.RateLimitPerSecond > 0 && c.RateLimitBurst < 1 {
+		return invalid("rate_limit_burst", "must be at least 1 when rate limit is enabled")
+	}
+	if c.MaxRequestBodyBytes < 0 {
+		return invalid("max_request_body_bytes", "must not be negative")
+	}
+	if c.ProbeIntervalSeconds < 0 {
+		return invalid("probe_interval_seconds", "must not be negative")
+	}
+	if c.ProbeTimeoutSeconds < 0 {
+		return invalid("probe_timeout_seconds", "must not be negative")
+	}
+	if c.ProbeIntervalSeconds > 0 && c.ProbeTimeout() > c.ProbeInterval() {
+		return invalid("probe_timeout_seconds", "must not exceed probe_interval_seconds")
+	}
+	if c.LogFormat != "" && c.LogFormat != "text" && c.LogFormat != "json" {
+		return invalid("log_format", "must be text or json")
+	}
+	if c.TracingEnabled {
+		if _, _, err = net.SplitHostPort(c.TracingEndpoint); err != nil {
+			return invalid("tracing_endpoint", err.Error())
+		}
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		return invalid("tracing_sample_ratio", "must be between 0 and 1")
+	}
+	if c.LogLevel != "" {
+		if _, err = log.ParseLevel(c.LogLevel); err != nil {
+			return invalid("log_level", err.Error())
+		}
+	}
+	return nil
+}
+
+// validateKeys checks that keys are referenced the way KeyBackend expects
+func (c *Config) validateKeys() error {
+	switch c.KeyBackend {
+	case "", KeyBackendFile:
+		if c.SigningKeyLabel != "" {
+			return invalid("signing_key_label", "requires pkcs11 key_backend")
+		}
+		if c.CaKeyLabel != "" {
+			return invalid("ca_key_label", "requires pkcs11 key_backend")
+		}
+		if c.SigningKeyFile != "" && !fileExists(c.SigningKeyFile) {
+			return invalid("signing_key_file", "file not found")
+		}
+		if c.CaKeyFile != "" && !fileExists(c.CaKeyFile) {
+			return invalid("ca_key_file", "file not found")
+		}
+		if (c.CaCertFile == "") != (c.CaKeyFile == "") {
+			return invalid("ca_key_file", "ca_cert_file and ca_key_file must be set together")
+		}
+	case KeyBackendPkcs11:
+		if !fileExists(c.Pkcs11Module) {
+			return invalid("pkcs11_module", "file not found")
+		}
+		if c.Pkcs11TokenLabel == "" {
+			return invalid("pkcs11_token_label", "is empty")
+		}
+		if c.Pkcs11Pin == "" {
+			return invalid("pkcs11_pin", "is empty")
+		}
+		if c.SigningKeyFile != "" {
+			return invalid("signing_key_file", "not used with pkcs11 key_backend, set signing_key_label")
+		}
+		if c.CaKeyFile != "" {
+			return invalid("ca_key_file", "not used with pkcs11 key_backend, set ca_key_label")
+		}
+		if (c.CaCertFile == "") != (c.CaKeyLabel == "") {
+			return invalid("ca_key_label", "ca_cert_file and ca_key_label must be set together")
+		}
+	default:
+		return invalid("key_backend", "must be file or pkcs11")
+	}
+	return nil
+}
+
+func jsonName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+// applyEnv overrides fields by REGISTRY_<JSON_NAME> variables, lists are comma separated
+func applyEnv(conf *Config, lookup func(string) (string, bool)) (err error) {
+	v := reflect.ValueOf(conf).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" || name == "-" {
+			continue
+		}
+		envName := EnvPrefix + strings.ToUpper(name)
+		val, ok := lookup(envName)
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(val)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(val)
+			if err != nil {
+				return errors.Wrap(err, envName)
+			}
+			field.SetBool(b)
+		case reflect.Int, reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return errors.Wrap(err, envName)
+			}
+			field.SetInt(n)
+		case reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(val, 64)
+			if err != nil {
+				return errors.Wrap(err, envName)
+			}
+			field.SetFloat(f)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				return errors.Errorf("%s: unsupported list type", envName)
+			}
+			items := make([]string, 0)
+			for _, item := range strings.Split(val, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return errors.Errorf("%s: unsupported field type", envName)
+		}
 	}
+	return nil
+}
 
-	b, err := json.MarshalIndent(c, "", "\t")
+// Store holds current config and allows safe reload of fields tagged reload:"true"
+type Store struct {
+	source string
+	mu     sync.RWMutex
+	conf   *Config
+}
 
+func NewStore(source string) (s *Store, err error) {
+	var conf *Config
+	conf, err = Load(source)
 	if err != nil {
-		eMsg := "error marshall config file"
-		log.WithError(err).Error(eMsg)
-		err = errors.Wrap(err, eMsg)
 		return
 	}
+	s = &Store{
+		source: source,
+		conf:   conf,
+	}
+	return
+}
+
+// NewStaticStore wraps conf that does not come from a file, such store cannot be reloaded
+func NewStaticStore(conf *Config) *Store {
+	conf.prepare()
+	return &Store{conf: conf}
+}
+
+// Get returns current config, it must not be modified by callers
+func (s *Store) Get() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conf
+}
 
-	err = ioutil.WriteFile(source, b, 0644)
+// Reload re-reads config source, applies reloadable fields and returns json names of applied fields.
+// Changes of other fields are logged and ignored until restart.
+func (s *Store) Reload() (changed []string, err error) {
+	clog := log.WithFields(log.Fields{
+		"method": "config.Store.Reload",
+		"source": s.source,
+	})
+	var fresh *Config
+	fresh, err = Load(s.source)
 	if err != nil {
-		eMsg := "error creating config file"
-		log.WithError(err).Error(eMsg)
+		eMsg := "error loading config, keeping previous one"
+		clog.WithError(err).Error(eMsg)
 		err = errors.Wrap(err, eMsg)
 		return
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := *s.conf
+	nv := reflect.ValueOf(&next).Elem()
+	fv := reflect.ValueOf(fresh).Elem()
+	t := nv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		if reflect.DeepEqual(nv.Field(i).Interface(), fv.Field(i).Interface()) {
+			continue
+		}
+		name := jsonName(t.Field(i))
+		if t.Field(i).Tag.Get("reload") != "true" {
+			clog.WithField("field", name).Warn("field changed but cannot be reloaded, restart required")
+			continue
+		}
+		nv.Field(i).Set(fv.Field(i))
+		changed = append(changed, name)
+	}
+	next.prepare()
+	s.conf = &next
+	clog.WithField("changed", changed).Info("config reloaded")
 	return
 }
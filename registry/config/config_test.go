@@ -0,0 +1,209 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes fields as json config file and returns its path
+func writeConfig(t *testing.T, path string, fields map[string]interface{}) string {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "config.json")
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func baseFields() map[string]interface{} {
+	return map[string]interface{}{
+		"db_conn":        "postgres://registry@localhost/registry",
+		"listen_address": ":8080",
+		"log_level":      "info",
+	}
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	fields := baseFields()
+	fields["rate_limit_per_second"] = 1
+	fields["rate_limit_burst"] = 5
+	fields["trusted_proxies"] = []string{"172.16.0.0/12"}
+	path := writeConfig(t, "", fields)
+	t.Setenv("REGISTRY_LOG_LEVEL", "debug")
+	t.Setenv("REGISTRY_RATE_LIMIT_PER_SECOND", "2.5")
+	t.Setenv("REGISTRY_MAX_REQUEST_BODY_BYTES", "2048")
+	t.Setenv("REGISTRY_TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1,")
+	t.Setenv("REGISTRY_TRACING_INSECURE", "true")
+
+	conf, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if conf.LogLevel != "debug" || conf.RateLimitPerSecond != 2.5 || conf.MaxRequestBodyBytes != 2048 || !conf.TracingInsecure {
+		t.Errorf("environment did not override file: %+v", conf)
+	}
+	if conf.RateLimitBurst != 5 || conf.ListenAddress != ":8080" {
+		t.Errorf("file values without override lost: burst=%d listen=%s", conf.RateLimitBurst, conf.ListenAddress)
+	}
+	if !reflect.DeepEqual(conf.TrustedProxies, []string{"10.0.0.0/8", "192.0.2.1"}) {
+		t.Errorf("TrustedProxies = %q", conf.TrustedProxies)
+	}
+	var nets []string
+	for _, n := range conf.TrustedProxyNets() {
+		nets = append(nets, n.String())
+	}
+	if !reflect.DeepEqual(nets, []string{"10.0.0.0/8", "192.0.2.1/32"}) {
+		t.Errorf("TrustedProxyNets = %q", nets)
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	path := writeConfig(t, "", baseFields())
+	for name, value := range map[string]string{
+		"REGISTRY_RATE_LIMIT_BURST":      "many",
+		"REGISTRY_TRACING_ENABLED":       "sometimes",
+		"REGISTRY_RATE_LIMIT_PER_SECOND": "fast",
+		"REGISTRY_LOG_LEVEL":             "loud",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if conf, err := Load(path); err == nil || conf != nil {
+				t.Errorf("Load with %s=%s succeeded", name, value)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	existing := writeConfig(t, "", nil)
+	tests := []struct {
+		field  string
+		change func(c *Config)
+	}{
+		{"", func(c *Config) {}},
+		{"db_conn", func(c *Config) { c.DbConn = "" }},
+		{"db_conn", func(c *Config) { c.DbConn = "host=localhost port=x" }},
+		{"", func(c *Config) { c.DbConn = ""; c.SnapshotFile = existing; c.SnapshotKeyFile = existing }},
+		{"listen_address", func(c *Config) { c.ListenAddress = "8080" }},
+		{"grpc_listen_address", func(c *Config) { c.GrpcListenAddress = c.ListenAddress }},
+		{"endpoint_url", func(c *Config) { c.EndpointUrl = "registry.example.com" }},
+		{"tls_cert_file", func(c *Config) { c.TlsCertFile = "/nonexistent/cert.pem" }},
+		{"tls_client_ca_file", func(c *Config) { c.TlsClientCaFile = existing }},
+		{"tls_client_auth_required", func(c *Config) { c.TlsClientAuthRequired = true }},
+		{"pki_intermediate_ca_file", func(c *Config) { c.PkiIntermediateCaFile = existing }},
+		{"ca_key_file", func(c *Config) { c.CaCertFile = existing }},
+		{"signing_key_label", func(c *Config) { c.SigningKeyLabel = "signing" }},
+		{"key_backend", func(c *Config) { c.KeyBackend = "vault" }},
+		{"pkcs11_module", func(c *Config) { c.KeyBackend = KeyBackendPkcs11 }},
+		{"snapshot_key_file", func(c *Config) { c.SnapshotFile = existing }},
+		{"mirror_upstream_url", func(c *Config) { c.MirrorUpstreamUrl = "ftp://registry.example.com" }},
+		{"mirror_key_file", func(c *Config) { c.MirrorUpstreamUrl = "https://registry.example.com" }},
+		{"trusted_proxies", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/33"} }},
+		{"trusted_proxies", func(c *Config) { c.TrustedProxies = []string{"proxy.example.com"} }},
+		{"grpc_max_watch_streams", func(c *Config) { c.GrpcMaxWatchStreams = -1 }},
+		{"rate_limit_per_second", func(c *Config) { c.RateLimitPerSecond = -1 }},
+		{"rate_limit_burst", func(c *Config) { c.RateLimitPerSecond = 1 }},
+		{"max_request_body_bytes", func(c *Config) { c.MaxRequestBodyBytes = -1 }},
+		{"probe_timeout_seconds", func(c *Config) { c.ProbeIntervalSeconds = 5; c.ProbeTimeoutSeconds = 10 }},
+		{"log_format", func(c *Config) { c.LogFormat = "xml" }},
+		{"log_level", func(c *Config) { c.LogLevel = "loud" }},
+		{"tracing_endpoint", func(c *Config) { c.TracingEnabled = true }},
+		{"tracing_sample_ratio", func(c *Config) { c.TracingSampleRatio = 1.5 }},
+	}
+	for _, tt := range tests {
+		name := tt.field
+		if name == "" {
+			name = "valid"
+		}
+		t.Run(name, func(t *testing.T) {
+			c := &Config{DbConn: "postgres://registry@localhost/registry", ListenAddress: ":8080"}
+			tt.change(c)
+			err := c.Validate()
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidConfig) || !strings.HasPrefix(err.Error(), tt.field+":") {
+				t.Errorf("Validate = %v, want %s to be reported", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestReloadableFields(t *testing.T) {
+	var reloadable []string
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).Tag.Get("reload") == "true" {
+			reloadable = append(reloadable, jsonName(typ.Field(i)))
+		}
+	}
+	// the list documented in README
+	want := []string{"grpc_max_watch_streams", "key_status_max_age_seconds", "trusted_proxies", "rate_limit_per_second",
+		"rate_limit_burst", "max_request_body_bytes", "log_level"}
+	if !reflect.DeepEqual(reloadable, want) {
+		t.Errorf("reloadable fields = %q, want %q", reloadable, want)
+	}
+}
+
+func TestReload(t *testing.T) {
+	fields := baseFields()
+	fields["endpoint_url"] = "https://registry.example.com"
+	path := writeConfig(t, "", fields)
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	before := s.Get()
+
+	fields["log_level"] = "warn"
+	fields["trusted_proxies"] = []string{"10.0.0.0/8"}
+	fields["listen_address"] = ":9090"
+	fields["endpoint_url"] = "https://other.example.com"
+	fields["allowed_referrers"] = []string{"https://other.example.com"}
+	writeConfig(t, path, fields)
+	changed, err := s.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !reflect.DeepEqual(changed, []string{"trusted_proxies", "log_level"}) {
+		t.Errorf("changed = %q, want only the reloadable fields", changed)
+	}
+	conf := s.Get()
+	if conf.LogLevel != "warn" || len(conf.TrustedProxyNets()) != 1 || conf.TrustedProxyNets()[0].String() != "10.0.0.0/8" {
+		t.Errorf("reloadable fields not applied: log_level=%s trusted=%v", conf.LogLevel, conf.TrustedProxyNets())
+	}
+	if conf.ListenAddress != ":8080" || conf.EndpointUrl != "https://registry.example.com" || len(conf.AllowedReferrers) != 0 {
+		t.Errorf("fields needing restart applied: %+v", conf)
+	}
+	if before.LogLevel != "info" || len(before.TrustedProxyNets()) != 0 {
+		t.Errorf("config taken before reload changed: %+v", before)
+	}
+
+	if changed, err = s.Reload(); err != nil || len(changed) != 0 {
+		t.Errorf("Reload of unchanged file = %q, %v", changed, err)
+	}
+	conf = s.Get()
+
+	fields["log_level"] = "loud"
+	writeConfig(t, path, fields)
+	if _, err = s.Reload(); err == nil {
+		t.Fatal("Reload of invalid config succeeded")
+	}
+	if s.Get() != conf {
+		t.Error("invalid config replaced the current one")
+	}
+}
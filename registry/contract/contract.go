@@ -0,0 +1,754 @@
+// Package contract runs every registry HTTP route against an in-process server backed by in-memory datastore
+// and checks requests and responses against the OpenAPI specification served by the daemon
+package contract
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/client"
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/openapi"
+	"ykjam/doc-registry-go/ratelimit"
+	"ykjam/doc-registry-go/signing"
+	"ykjam/doc-registry-go/web"
+)
+
+const (
+	adminPassword    = "contract-password"
+	operator         = "operator"
+	securityOfficer  = "security-officer"
+	auditor          = "auditor"
+	maxRequestBody   = 1 << 20
+	harnessActorName = "contract"
+)
+
+// step is one request of the scenario, steps run in order against the same server
+type step struct {
+	method string
+	// {serial} in path is replaced with serial of the last issued certificate
+	path string
+	// admin authenticates with basic auth when set, bearer and registrationToken use the last token
+	// returned in the scenario, either admin or registration one
+	admin             string
+	bearer            bool
+	registrationToken bool
+	// body is sent as JSON, func(h *harness) interface{} is called right before sending, raw is sent as is
+	body        interface{}
+	raw         string
+	ifNoneMatch bool
+	status      int
+	// invalid marks requests that the spec must reject as well
+	invalid bool
+	// unrouted marks requests to paths or with methods the spec does not have, answered by router fallbacks
+	unrouted bool
+}
+
+func (st *step) String() string {
+	return fmt.Sprintf("%s %s -> %d", st.method, st.path, st.status)
+}
+
+type harness struct {
+	doc     *openapi3.T
+	router  routers.Router
+	srv     *httptest.Server
+	out     io.Writer
+	covered map[string]bool
+	token   string
+	etag    string
+	// last issued key challenge
+	challengeId int
+	nonce       string
+	// last issued certificate
+	serial string
+	// responses carrying signature are verified with it
+	signingKey crypto.PublicKey
+	failed     int
+}
+
+func publicKeyPEM(key crypto.Signer) string {
+	der, _ := x509.MarshalPKIXPublicKey(key.Public())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// fingerprint is hex SHA-256 of key in PKIX DER encoding, colon separated upper case when openssl is set
+func fingerprint(key crypto.Signer, openssl bool) string {
+	der, _ := x509.MarshalPKIXPublicKey(key.Public())
+	sum := sha256.Sum256(der)
+	if !openssl {
+		return hex.EncodeToString(sum[:])
+	}
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// sign signs message with the scheme of key algorithm, ECDSA keys hash with the size matching the curve
+func sign(key crypto.Signer, message []byte) []byte {
+	var sig []byte
+	switch k := key.Public().(type) {
+	case ed25519.PublicKey:
+		sig, _ = key.Sign(rand.Reader, message, crypto.Hash(0))
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P384() {
+			digest := sha512.Sum384(message)
+			sig, _ = key.Sign(rand.Reader, digest[:], crypto.SHA384)
+			break
+		}
+		digest := sha256.Sum256(message)
+		sig, _ = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	default:
+		digest := sha256.Sum256(message)
+		sig, _ = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	return sig
+}
+
+// prove adds to body the last issued challenge signed by key, as the applicant does
+func prove(key crypto.Signer, body map[string]interface{}) func(h *harness) interface{} {
+	return func(h *harness) interface{} {
+		sig := sign(key, []byte(h.nonce))
+		proved := map[string]interface{}{"challenge_id": h.challengeId, "signature": base64.StdEncoding.EncodeToString(sig)}
+		for k, v := range body {
+			proved[k] = v
+		}
+		return proved
+	}
+}
+
+// csr is PEM encoded certificate signing request for key, the subject is ignored by the registry
+func csr(key crypto.Signer) string {
+	der, _ := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "node"}}, key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+// newCertificateAuthority creates CA the registry issues DMS node certificates with
+func newCertificateAuthority() (ca *api.CertificateAuthority, err error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "contract registry CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		return
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return
+	}
+	return api.NewCertificateAuthority(cert, caKey, 72*time.Hour, "http://registry.example.com/api/v1/ca/crl")
+}
+
+// pki is a test CA with organization certificate for keys[6]
+type pki struct {
+	root *x509.Certificate
+	// issued to Edara 5
+	valid string
+}
+
+func newPKI(key *rsa.PrivateKey) (p *pki, err error) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "contract root CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return
+	}
+	root, err := x509.ParseCertificate(der)
+	if err != nil {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Edara 5"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(12 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err = x509.CreateCertificate(rand.Reader, template, root, &key.PublicKey, caKey)
+	if err != nil {
+		return
+	}
+	return &pki{root: root, valid: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))}, nil
+}
+
+// scenario exercises every documented operation with enough steps to check its success and error responses
+// against the spec, what handlers decide is tested in the packages implementing them.
+// It runs with RSA keys, other holds ECDSA P-256, ECDSA P-384 and Ed25519 keys
+func scenario(keys []*rsa.PrivateKey, other []crypto.Signer, certs *pki) []*step {
+	pub := func(i int) string {
+		return publicKeyPEM(keys[i])
+	}
+	orgWithKey := func(name string, key crypto.Signer) map[string]interface{} {
+		return map[string]interface{}{
+			"name":       name,
+			"label":      name + " label",
+			"type":       entity.SRD,
+			"url":        "https://" + strings.ToLower(strings.ReplaceAll(name, " ", "-")) + ".example.com/api/document/receive",
+			"public_key": publicKeyPEM(key),
+		}
+	}
+	org := func(name string, key int) map[string]interface{} {
+		return orgWithKey(name, keys[key])
+	}
+	challengeWithKey := func(key crypto.Signer) *step {
+		return &step{method: http.MethodPost, path: "/api/v1/key-challenges", body: map[string]interface{}{"public_key": publicKeyPEM(key)}, status: http.StatusOK}
+	}
+	challenge := func(key int) *step {
+		return challengeWithKey(keys[key])
+	}
+	update := map[string]interface{}{"name": "Edara 1", "label": "Edara, Müdirlik", "type": entity.Netije, "url": "https://edara-1.example.com/receive"}
+	legacyUpdate := map[string]interface{}{"id": 2, "name": "Edara 2", "label": "Edara 2", "type": entity.EResminama, "url": "https://edara-2.example.com/receive"}
+	return []*step{
+		{method: http.MethodGet, path: "/health/live", status: http.StatusOK},
+		{method: http.MethodGet, path: "/health/ready", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/openapi.yaml", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/nothing", status: http.StatusNotFound, unrouted: true},
+		{method: http.MethodDelete, path: "/api/v1/organizations", status: http.StatusMethodNotAllowed, unrouted: true},
+
+		{method: http.MethodPost, path: "/api/v1/key-challenges", body: map[string]interface{}{"public_key": "not a key"}, status: http.StatusBadRequest},
+		challenge(0),
+		{method: http.MethodPost, path: "/api/v1/organizations", body: prove(keys[0], org("Edara 1", 0)), status: http.StatusUnauthorized, invalid: true},
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: auditor, body: prove(keys[0], org("Edara 1", 0)), status: http.StatusForbidden},
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: operator, body: map[string]interface{}{"name": "Edara 1"}, status: http.StatusBadRequest, invalid: true},
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: operator, raw: "{", status: http.StatusBadRequest, invalid: true},
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: operator, body: org("Edara 1", 0), status: http.StatusBadRequest, invalid: true},
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: operator, body: prove(keys[0], org("Edara 1", 0)), status: http.StatusOK},
+		challenge(1),
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: operator, body: prove(keys[1], org("Edara 1", 1)), status: http.StatusConflict},
+		challenge(1),
+		{method: http.MethodPost, path: "/api/organization/add", admin: operator, body: prove(keys[1], org("Edara 2", 1)), status: http.StatusOK},
+
+		{method: http.MethodGet, path: "/api/v1/organizations", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations", ifNoneMatch: true, status: http.StatusNotModified},
+		{method: http.MethodGet, path: "/api/organization", status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/organization", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations/1", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations/99", status: http.StatusNotFound},
+
+		{method: http.MethodPut, path: "/api/v1/organizations/1", admin: operator, body: update, status: http.StatusOK},
+		{method: http.MethodPut, path: "/api/v1/organizations/99", admin: operator, body: update, status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/api/organization/update", admin: operator, body: legacyUpdate, status: http.StatusOK},
+		challenge(2),
+		{method: http.MethodPut, path: "/api/v1/organizations/1/key", admin: operator, body: prove(keys[2], map[string]interface{}{"public_key": pub(2)}), status: http.StatusForbidden},
+		{method: http.MethodPut, path: "/api/v1/organizations/1/key", admin: securityOfficer, body: map[string]interface{}{"public_key": "not a key"}, status: http.StatusBadRequest, invalid: true},
+		{method: http.MethodPut, path: "/api/v1/organizations/1/key", admin: securityOfficer, body: prove(keys[2], map[string]interface{}{"public_key": pub(2)}), status: http.StatusOK},
+		challenge(3),
+		{method: http.MethodPost, path: "/api/organization/key", admin: securityOfficer, body: prove(keys[3], map[string]interface{}{"id": 2, "public_key": pub(3)}), status: http.StatusOK},
+		{method: http.MethodPut, path: "/api/v1/organizations/1/state", admin: securityOfficer, body: map[string]interface{}{"state": entity.EntityStateDisabled}, status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/organization/state", admin: securityOfficer, body: map[string]interface{}{"id": 2, "state": entity.EntityStateDeleted}, status: http.StatusOK},
+
+		{method: http.MethodPost, path: "/api/v1/admin/tokens", admin: auditor, body: map[string]interface{}{"ttl_hours": 100000}, status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/api/v1/admin/tokens", admin: auditor, body: map[string]interface{}{"label": "contract"}, status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/admin/token", admin: auditor, body: map[string]interface{}{"label": "legacy"}, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/audit-log", bearer: true, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/audit", admin: auditor, status: http.StatusOK},
+
+		{method: http.MethodPost, path: "/api/v1/registrations", body: map[string]interface{}{"name": "Edara 3"}, status: http.StatusBadRequest, invalid: true},
+		challenge(4),
+		{method: http.MethodPost, path: "/api/v1/registrations", body: prove(keys[4], org("Edara 3", 4)), status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/registrations/3", registrationToken: true, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/registrations/3", status: http.StatusNotFound, invalid: true},
+		{method: http.MethodGet, path: "/api/v1/registrations", admin: auditor, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/registrations?state=DELETED", admin: auditor, status: http.StatusBadRequest, invalid: true},
+		{method: http.MethodPut, path: "/api/v1/registrations/3/reject", admin: auditor, body: map[string]interface{}{"reason": "unknown"}, status: http.StatusForbidden},
+		{method: http.MethodPut, path: "/api/v1/registrations/3/reject", admin: operator, body: map[string]interface{}{"reason": "unknown organization"}, status: http.StatusOK},
+		challenge(5),
+		{method: http.MethodPost, path: "/api/v1/registrations", body: prove(keys[5], org("Edara 4", 5)), status: http.StatusOK},
+		{method: http.MethodPut, path: "/api/v1/registrations/4/approve", admin: operator, body: map[string]interface{}{}, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations/4", status: http.StatusOK},
+
+		challenge(6),
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: operator, body: prove(keys[6], withCertificate(org("Edara 5", 6), certs.valid)), status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations/5", status: http.StatusOK},
+
+		challengeWithKey(other[0]),
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: operator, body: prove(other[0], orgWithKey("Edara 6", other[0])), status: http.StatusOK},
+		challengeWithKey(other[1]),
+		{method: http.MethodPut, path: "/api/v1/organizations/6/key", admin: securityOfficer, body: prove(other[1], map[string]interface{}{"public_key": publicKeyPEM(other[1])}), status: http.StatusOK},
+		challengeWithKey(other[2]),
+		{method: http.MethodPost, path: "/api/v1/organizations", admin: operator, body: prove(other[2], orgWithKey("Edara 7", other[2])), status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations", status: http.StatusOK},
+
+		{method: http.MethodGet, path: "/api/v1/keys/" + fingerprint(keys[2], false), status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/keys/" + fingerprint(other[2], true), status: http.StatusOK},
+
+		{method: http.MethodGet, path: "/api/v1/ca/certificate", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/ca/certificate", ifNoneMatch: true, status: http.StatusNotModified},
+		{method: http.MethodPost, path: "/api/v1/organizations/4/certificates", body: map[string]interface{}{"csr": csr(keys[5])}, status: http.StatusBadRequest, invalid: true},
+		{method: http.MethodPost, path: "/api/v1/organizations/7/certificates", body: map[string]interface{}{"csr": csr(other[2]), "usage": entity.CertificateUsageServer}, status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/v1/organizations/4/certificates", body: map[string]interface{}{"csr": csr(keys[5]), "usage": entity.CertificateUsageClient}, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations/4/certificates", status: http.StatusUnauthorized, invalid: true},
+		{method: http.MethodGet, path: "/api/v1/organizations/4/certificates", admin: auditor, status: http.StatusOK},
+		{method: http.MethodPut, path: "/api/v1/certificates/{serial}/revoke", admin: operator, body: map[string]interface{}{}, status: http.StatusForbidden},
+		{method: http.MethodPut, path: "/api/v1/certificates/{serial}/revoke", admin: securityOfficer, body: map[string]interface{}{"reason": "forgotten"}, status: http.StatusBadRequest, invalid: true},
+		{method: http.MethodPut, path: "/api/v1/certificates/{serial}/revoke", admin: securityOfficer, body: map[string]interface{}{"reason": entity.RevocationReasonKeyCompromise}, status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/ca/crl", status: http.StatusOK},
+
+		{method: http.MethodGet, path: "/api/v1/signing-key", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations/4/keys/" + fingerprint(keys[5], false) + "/status", status: http.StatusOK},
+	}
+}
+
+// withCertificate replaces public_key of body with certificate
+func withCertificate(body map[string]interface{}, certificate string) map[string]interface{} {
+	c := map[string]interface{}{"certificate": certificate}
+	for k, v := range body {
+		if k != "public_key" {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// probeScenario runs after one probe round, when Edara 4 to Edara 7 are enabled
+func probeScenario() []*step {
+	return []*step{
+		{method: http.MethodGet, path: "/api/v1/admin/probes", status: http.StatusUnauthorized, invalid: true},
+		{method: http.MethodGet, path: "/api/v1/admin/probes", admin: auditor, status: http.StatusOK},
+		{method: http.MethodGet, path: "/metrics", admin: auditor, status: http.StatusOK},
+	}
+}
+
+// mirrorScenario runs against a mirror of the harness registry after its first sync
+func mirrorScenario() []*step {
+	return []*step{
+		{method: http.MethodGet, path: "/api/v1/mirror/status", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/v1/organizations/4", status: http.StatusOK},
+		{method: http.MethodGet, path: "/api/organization", status: http.StatusOK},
+		{method: http.MethodPost, path: "/api/organization", status: http.StatusOK},
+		{method: http.MethodPut, path: "/api/v1/organizations/4/state", admin: securityOfficer, body: map[string]interface{}{"state": entity.EntityStateDisabled}, status: http.StatusMethodNotAllowed},
+	}
+}
+
+// Run executes the scenario, writes one line per step to out and returns number of failed checks.
+// Every route registered by web.Server must be documented and every documented operation must be exercised.
+func Run(ctx context.Context, out io.Writer) (failed int, err error) {
+	doc, err := openapi.Load(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "error loading OpenAPI spec")
+	}
+	specRouter, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return 0, errors.Wrap(err, "error building router from OpenAPI spec")
+	}
+
+	access := datastore.NewMemoryAccess()
+	apiController := api.NewAPIController(access)
+	for username, role := range map[string]entity.AdminRole{
+		operator:        entity.AdminRoleOperator,
+		securityOfficer: entity.AdminRoleSecurityOfficer,
+		auditor:         entity.AdminRoleAuditor,
+	} {
+		if _, err = apiController.AdminAdd(ctx, harnessActorName, username, adminPassword, role); err != nil {
+			return 0, errors.Wrap(err, "error adding admin")
+		}
+	}
+	s := web.NewServer(apiController, config.NewStaticStore(&config.Config{MaxRequestBodyBytes: maxRequestBody}), ratelimit.New())
+	r := mux.NewRouter()
+	s.RegisterRoutes(r, s.RequestIdMiddleware, s.LimitMiddleware)
+
+	h := &harness{
+		doc:     doc,
+		router:  specRouter,
+		srv:     httptest.NewServer(r),
+		out:     out,
+		covered: make(map[string]bool),
+	}
+	defer h.srv.Close()
+
+	h.checkDocumented(r)
+	keys := make([]*rsa.PrivateKey, 7)
+	for i := range keys {
+		if keys[i], err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			return 0, errors.Wrap(err, "error generating key")
+		}
+	}
+	other := make([]crypto.Signer, 0, 3)
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384()} {
+		ecKey, gErr := ecdsa.GenerateKey(curve, rand.Reader)
+		if gErr != nil {
+			return 0, errors.Wrap(gErr, "error generating key")
+		}
+		other = append(other, ecKey)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return 0, errors.Wrap(err, "error generating key")
+	}
+	other = append(other, edKey)
+	certs, err := newPKI(keys[6])
+	if err != nil {
+		return 0, errors.Wrap(err, "error issuing test certificates")
+	}
+	apiController.SetCertificateTrust(api.NewCertificateTrust([]*x509.Certificate{certs.root}, nil))
+	ca, err := newCertificateAuthority()
+	if err != nil {
+		return 0, errors.Wrap(err, "error creating certificate authority")
+	}
+	apiController.SetCertificateAuthority(ca)
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return 0, errors.Wrap(err, "error generating key")
+	}
+	signer, err := api.NewResponseSigner(signingKey)
+	if err != nil {
+		return 0, errors.Wrap(err, "error creating response signer")
+	}
+	apiController.SetResponseSigner(signer)
+	h.signingKey = signingKey.Public()
+	openapi3filter.RegisterBodyDecoder("application/pkix-crl", openapi3filter.FileBodyDecoder)
+	for _, st := range scenario(keys, other, certs) {
+		h.run(ctx, st)
+	}
+	probeOrganizations(ctx, apiController)
+	for _, st := range probeScenario() {
+		h.run(ctx, st)
+	}
+	mirror, err := newMirror(ctx, h.srv.URL, signingKey, signer)
+	if err != nil {
+		return 0, errors.Wrap(err, "error creating mirror")
+	}
+	primary := h.srv
+	h.srv = mirror
+	for _, st := range mirrorScenario() {
+		h.run(ctx, st)
+	}
+	h.srv = primary
+	mirror.Close()
+	h.checkCovered()
+	return h.failed, nil
+}
+
+// newMirror serves read-only mirror of upstreamUrl synced once, signing responses with signer of signingKey
+// as upstream does
+func newMirror(ctx context.Context, upstreamUrl string, signingKey crypto.Signer, signer *api.ResponseSigner) (srv *httptest.Server, err error) {
+	verifier, err := client.NewVerifierFromPEM([]byte(publicKeyPEM(signingKey)))
+	if err != nil {
+		return
+	}
+	access := datastore.NewReadOnlyAccess()
+	apiController := api.NewAPIController(access)
+	apiController.SetResponseSigner(signer)
+	mirror, err := api.NewMirror(upstreamUrl, verifier, access, 5*time.Second)
+	if err != nil {
+		return
+	}
+	apiController.SetMirror(mirror)
+	if err = apiController.MirrorSync(ctx); err != nil {
+		return
+	}
+	s := web.NewServer(apiController, config.NewStaticStore(&config.Config{MaxRequestBodyBytes: maxRequestBody}), ratelimit.New())
+	r := mux.NewRouter()
+	s.RegisterRoutes(r, s.RequestIdMiddleware, s.LimitMiddleware, s.ReadOnlyMiddleware)
+	return httptest.NewServer(r), nil
+}
+
+// probeOrganizations runs one probe round with every organization url served by a local TLS server
+func probeOrganizations(ctx context.Context, apiController *api.APIController) {
+	endpoint := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer endpoint.Close()
+	client := api.NewProbeClient(5 * time.Second)
+	client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, endpoint.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	apiController.OrganizationProbeAll(ctx, client)
+}
+
+func (h *harness) fail(format string, args ...interface{}) {
+	h.failed++
+	fmt.Fprintf(h.out, "FAIL "+format+"\n", args...)
+}
+
+var muxVarPattern = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+
+// checkDocumented walks routes registered on r and fails for every method and path missing in the spec
+func (h *harness) checkDocumented(r *mux.Router) {
+	_ = r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		path := muxVarPattern.ReplaceAllString(tpl, "{$1}")
+		item := h.doc.Paths.Find(path)
+		for _, method := range methods {
+			if item == nil || item.GetOperation(method) == nil {
+				h.fail("%s %s is served but not documented", method, path)
+			}
+		}
+		return nil
+	})
+}
+
+// checkCovered fails for every documented operation not exercised by the scenario
+func (h *harness) checkCovered() {
+	var missing []string
+	for path, item := range h.doc.Paths.Map() {
+		for method := range item.Operations() {
+			if !h.covered[method+" "+path] {
+				missing = append(missing, method+" "+path)
+			}
+		}
+	}
+	sort.Strings(missing)
+	for _, m := range missing {
+		h.fail("%s is documented but not exercised", m)
+	}
+}
+
+func (h *harness) request(ctx context.Context, st *step) (req *http.Request, body []byte, err error) {
+	switch {
+	case st.raw != "":
+		body = []byte(st.raw)
+	case st.body != nil:
+		v := st.body
+		if f, ok := v.(func(h *harness) interface{}); ok {
+			v = f(h)
+		}
+		if body, err = json.Marshal(v); err != nil {
+			return
+		}
+	}
+	path := strings.ReplaceAll(st.path, "{serial}", h.serial)
+	req, err = http.NewRequestWithContext(ctx, st.method, h.srv.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if st.admin != "" {
+		req.SetBasicAuth(st.admin, adminPassword)
+	}
+	if st.bearer {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	if st.registrationToken {
+		req.Header.Set(web.HeaderRegistrationToken, h.token)
+	}
+	if st.ifNoneMatch {
+		req.Header.Set("If-None-Match", h.etag)
+	}
+	return
+}
+
+// runUnrouted sends request the spec does not describe and checks that the response is ErrorResponse
+// carrying the request id sent in X-Request-ID, as every other error response
+func (h *harness) runUnrouted(ctx context.Context, st *step) {
+	req, _, err := h.request(ctx, st)
+	if err != nil {
+		h.fail("%s: error building request: %v", st, err)
+		return
+	}
+	if _, _, err = h.router.FindRoute(req); err == nil {
+		h.fail("%s: documented, but expected to be answered by router fallback", st)
+		return
+	}
+	resp, err := h.srv.Client().Do(req)
+	if err != nil {
+		h.fail("%s: %v", st, err)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.fail("%s: error reading response: %v", st, err)
+		return
+	}
+	if resp.StatusCode != st.status {
+		h.fail("%s: got status %d, body %s", st, resp.StatusCode, respBody)
+		return
+	}
+	var decoded interface{}
+	if err = json.Unmarshal(respBody, &decoded); err != nil {
+		h.fail("%s: response is not JSON: %s", st, respBody)
+		return
+	}
+	if err = h.doc.Components.Schemas["ErrorResponse"].Value.VisitJSON(decoded); err != nil {
+		h.fail("%s: response does not match ErrorResponse: %v", st, err)
+		return
+	}
+	var errResp struct {
+		Data struct {
+			RequestId string `json:"request_id"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(respBody, &errResp)
+	requestId := resp.Header.Get(web.HeaderRequestId)
+	if requestId == "" || errResp.Data.RequestId != requestId {
+		h.fail("%s: request id %q in header, %q in body", st, requestId, errResp.Data.RequestId)
+		return
+	}
+	fmt.Fprintf(h.out, "ok   %s\n", st)
+}
+
+func (h *harness) run(ctx context.Context, st *step) {
+	if st.unrouted {
+		h.runUnrouted(ctx, st)
+		return
+	}
+	req, reqBody, err := h.request(ctx, st)
+	if err != nil {
+		h.fail("%s: error building request: %v", st, err)
+		return
+	}
+	route, pathParams, err := h.router.FindRoute(req)
+	if err != nil {
+		h.fail("%s: not documented: %v", st, err)
+		return
+	}
+	h.covered[req.Method+" "+route.Path] = true
+
+	// validation reads body, so it gets its own copy
+	specReq := req.Clone(ctx)
+	specReq.Body = io.NopCloser(bytes.NewReader(reqBody))
+	input := &openapi3filter.RequestValidationInput{
+		Request:    specReq,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc: func(ctx context.Context, in *openapi3filter.AuthenticationInput) error {
+				if req.Header.Get("Authorization") == "" {
+					return errors.New("no credentials")
+				}
+				return nil
+			},
+		},
+	}
+	err = openapi3filter.ValidateRequest(ctx, input)
+	if st.invalid && err == nil {
+		h.fail("%s: spec accepts request that server must reject", st)
+	}
+	if !st.invalid && err != nil {
+		h.fail("%s: request does not match spec: %v", st, err)
+	}
+
+	resp, err := h.srv.Client().Do(req)
+	if err != nil {
+		h.fail("%s: %v", st, err)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.fail("%s: error reading response: %v", st, err)
+		return
+	}
+	if resp.StatusCode != st.status {
+		h.fail("%s: got status %d, body %s", st, resp.StatusCode, respBody)
+		return
+	}
+	if signature := resp.Header.Get(web.HeaderSignature); signature != "" {
+		alg, _ := signing.Algorithm(h.signingKey)
+		sig, _ := base64.StdEncoding.DecodeString(signature)
+		if signing.Verify(alg, h.signingKey, respBody, sig) != nil {
+			h.fail("%s: response signature is invalid", st)
+		}
+	}
+	if route.Operation.Deprecated && resp.Header.Get("Deprecation") == "" {
+		h.fail("%s: deprecated operation response has no Deprecation header", st)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		h.etag = etag
+	}
+	var created struct {
+		Data struct {
+			Id     int    `json:"id"`
+			Token  string `json:"token"`
+			Nonce  string `json:"nonce"`
+			Serial string `json:"serial"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(respBody, &created) == nil {
+		if created.Data.Token != "" {
+			h.token = created.Data.Token
+		}
+		if created.Data.Nonce != "" {
+			h.challengeId, h.nonce = created.Data.Id, created.Data.Nonce
+		}
+		if created.Data.Serial != "" {
+			h.serial = created.Data.Serial
+		}
+	}
+
+	err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 resp.StatusCode,
+		Header:                 resp.Header,
+		Body:                   io.NopCloser(bytes.NewReader(respBody)),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+	if err != nil {
+		h.fail("%s: response does not match spec: %v", st, err)
+		return
+	}
+	fmt.Fprintf(h.out, "ok   %s\n", st)
+}
@@ -0,0 +1,25 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TestContract runs the harness of cmd/contract-check, so that routes drifting from the OpenAPI spec fail go test
+func TestContract(t *testing.T) {
+	out := log.StandardLogger().Out
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+	report := &bytes.Buffer{}
+	failed, err := Run(context.Background(), report)
+	if err != nil {
+		t.Fatalf("contract harness: %v\n%s", err, report)
+	}
+	if failed > 0 {
+		t.Fatalf("%d contract check(s) failed:\n%s", failed, report)
+	}
+}
@@ -1,50 +1,248 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"flag"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/client"
 	"ykjam/doc-registry-go/config"
 	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/grpcapi"
+	"ykjam/doc-registry-go/grpcapi/registryv1"
+	"ykjam/doc-registry-go/keystore"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/ratelimit"
+	"ykjam/doc-registry-go/snapshot"
+	"ykjam/doc-registry-go/tlsconf"
+	"ykjam/doc-registry-go/tracing"
 	"ykjam/doc-registry-go/web"
 )
 
 func main() {
+	configPath := flag.String("config", "config.json", "path to config file, fields can be overridden by "+config.EnvPrefix+"<FIELD> environment variables")
+	adminAdd := flag.String("admin-add", "", "create admin with given username (password is read from stdin) and exit")
+	adminRole := flag.String("admin-role", string(entity.AdminRoleAuditor), "role of admin created by -admin-add: AUDITOR, OPERATOR or SECURITY_OFFICER")
+	snapshotExport := flag.String("snapshot-export", "", "write organizations signed with the response signing key to given bundle file and exit")
+	snapshotVerify := flag.String("snapshot-verify", "", "verify given bundle file with snapshot_key_file, print its manifest and exit")
+	flag.Parse()
+
 	signalChan := make(chan os.Signal, 1)
 	quitChan := make(chan interface{})
 
-	signal.Notify(signalChan, os.Interrupt, os.Kill, syscall.SIGTERM)
-
-	customFormatter := new(log.TextFormatter)
-	customFormatter.TimestampFormat = "01-02 15:04:05.000"
-	customFormatter.FullTimestamp = true
-	log.SetFormatter(customFormatter)
-	log.SetLevel(log.DebugLevel)
+	signal.Notify(signalChan, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGHUP)
 
-	err := config.ReadConfig("config.json")
+	confStore, err := config.NewStore(*configPath)
 	if err != nil {
 		log.WithError(err).Panic("error reading config file")
 	}
+	err = logging.Setup(confStore.Get())
+	if err != nil {
+		log.WithError(err).Panic("error setting up logging")
+	}
+
+	if *adminAdd != "" {
+		addAdmin(confStore.Get(), *adminAdd, entity.AdminRole(*adminRole))
+		return
+	}
+	if *snapshotExport != "" {
+		exportSnapshot(confStore.Get(), *snapshotExport)
+		return
+	}
+	if *snapshotVerify != "" {
+		verifySnapshot(confStore.Get(), *snapshotVerify)
+		return
+	}
 
-	setupServer(quitChan, signalChan, config.Conf)
+	os.Exit(setupServer(quitChan, signalChan, confStore))
 }
 
-func setupServer(quit chan interface{}, signalChan chan os.Signal, conf *config.Config) {
-	var err error
-	var access datastore.Access
-	access, err = datastore.NewPgAccess(conf)
+func addAdmin(conf *config.Config, username string, role entity.AdminRole) {
+	access, err := datastore.NewPgAccess(conf)
 	if err != nil {
 		log.WithError(err).Panic("Could not initialize datastore.Access")
 		return
 	}
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.WithError(err).Panic("error reading password from stdin")
+		return
+	}
+	password = strings.TrimRight(password, "\r\n")
+	admin, err := api.NewAPIController(access).AdminAdd(context.Background(), "cli", username, password, role)
+	if err != nil {
+		log.WithError(err).Panic("error creating admin")
+		return
+	}
+	log.WithFields(log.Fields{
+		"id":       admin.Id,
+		"username": admin.Username,
+		"role":     admin.Role,
+	}).Info("admin created")
+}
+
+func exportSnapshot(conf *config.Config, path string) {
+	if conf.SigningKeyRef() == "" {
+		log.Panic("snapshot export needs response signing key")
+		return
+	}
+	access, err := datastore.NewPgAccess(conf)
+	if err != nil {
+		log.WithError(err).Panic("Could not initialize datastore.Access")
+		return
+	}
+	defer access.Close()
+	keys, err := keystore.Open(conf)
+	if err != nil {
+		log.WithError(err).Panic("Error in opening key backend")
+		return
+	}
+	defer keys.Close()
+	signer, err := keys.Signer(conf.SigningKeyRef())
+	if err != nil {
+		log.WithError(err).Panic("Error in loading response signing key")
+		return
+	}
+	rs, err := api.NewResponseSigner(signer)
+	if err != nil {
+		log.WithError(err).Panic("Error in loading response signing key")
+		return
+	}
+	apiController := api.NewAPIController(access)
+	apiController.SetResponseSigner(rs)
+	bundle, err := apiController.SnapshotExport(context.Background(), conf.EndpointUrl)
+	if err != nil {
+		log.WithError(err).Panic("error exporting snapshot")
+		return
+	}
+	// a half written bundle on removable media must not look like a complete one
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, bundle, 0644); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		log.WithError(err).Panic("error writing snapshot")
+		return
+	}
+	log.WithField("path", path).Info("snapshot written")
+}
+
+// openSnapshot reads bundle at path and verifies it with registry public key in keyFile
+func openSnapshot(path, keyFile string) (s *entity.Snapshot, err error) {
+	keyPem, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading snapshot key")
+	}
+	verifier, err := client.NewVerifierFromPEM(keyPem)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing snapshot key")
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading snapshot")
+	}
+	return snapshot.Open(raw, verifier)
+}
+
+func snapshotFields(m *entity.SnapshotManifest) log.Fields {
+	return log.Fields{
+		"registry":      m.RegistryUrl,
+		"created":       time.Unix(m.CreateTs, 0).UTC().Format(time.RFC3339),
+		"organizations": m.OrganizationCount,
+		"fingerprint":   m.SigningKeyFingerprint,
+	}
+}
+
+func verifySnapshot(conf *config.Config, path string) {
+	if conf.SnapshotKeyFile == "" {
+		log.Panic("snapshot verification needs snapshot_key_file")
+		return
+	}
+	s, err := openSnapshot(path, conf.SnapshotKeyFile)
+	if err != nil {
+		log.WithError(err).Panic("snapshot is not valid")
+		return
+	}
+	log.WithFields(snapshotFields(&s.Manifest)).Info("snapshot is valid")
+}
+
+// loadSnapshot replaces organizations of access with those of conf.SnapshotFile
+func loadSnapshot(access *datastore.ReadOnlyAccess, conf *config.Config) (err error) {
+	s, err := openSnapshot(conf.SnapshotFile, conf.SnapshotKeyFile)
+	if err != nil {
+		return
+	}
+	access.Replace(snapshot.Organizations(s.Organizations))
+	log.WithFields(snapshotFields(&s.Manifest)).Info("snapshot loaded")
+	return
+}
+
+const (
+	exitCodeOK           = 0
+	exitCodeDrainTimeout = 1
+
+	tracingShutdownTimeout = 5 * time.Second
+	mirrorSyncTimeout      = 30 * time.Second
+)
+
+// setupServer serves API until quit is closed and returns process exit code
+func setupServer(quit chan interface{}, signalChan chan os.Signal, confStore *config.Store) (exitCode int) {
+	conf := confStore.Get()
+	var err error
+	tracingShutdown, err := tracing.Setup(context.Background(), conf)
+	if err != nil {
+		log.WithError(err).Panic("Could not initialize tracing")
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := tracingShutdown(ctx); err != nil {
+			log.WithError(err).Error("error flushing spans")
+		}
+	}()
+
+	var access datastore.Access
+	var snapshotAccess, mirrorAccess *datastore.ReadOnlyAccess
+	switch {
+	case conf.SnapshotFile != "":
+		snapshotAccess = datastore.NewReadOnlyAccess()
+		if err = loadSnapshot(snapshotAccess, conf); err != nil {
+			log.WithError(err).Panic("Could not load snapshot")
+			return
+		}
+		access = snapshotAccess
+	case conf.MirrorUpstreamUrl != "":
+		mirrorAccess = datastore.NewReadOnlyAccess()
+		access = mirrorAccess
+	default:
+		access, err = datastore.NewPgAccess(conf)
+		if err != nil {
+			log.WithError(err).Panic("Could not initialize datastore.Access")
+			return
+		}
+	}
+	defer func() {
+		access.Close()
+		log.Info("datastore closed")
+	}()
 
 	apiController := api.NewAPIController(access)
 	if apiController == nil {
@@ -52,14 +250,118 @@ func setupServer(quit chan interface{}, signalChan chan os.Signal, conf *config.
 		return
 	}
 
-	s := web.NewServer(apiController)
+	if mirrorAccess != nil {
+		keyPem, err := ioutil.ReadFile(conf.MirrorKeyFile)
+		if err != nil {
+			log.WithError(err).Panic("Error in reading mirror key")
+			return
+		}
+		verifier, err := client.NewVerifierFromPEM(keyPem)
+		if err != nil {
+			log.WithError(err).Panic("Error in parsing mirror key")
+			return
+		}
+		mirror, err := api.NewMirror(conf.MirrorUpstreamUrl, verifier, mirrorAccess, mirrorSyncTimeout)
+		if err != nil {
+			log.WithError(err).Panic("Error in setting up mirror")
+			return
+		}
+		apiController.SetMirror(mirror)
+	}
+
+	if conf.PkiRootCaFile != "" {
+		trust, err := api.LoadCertificateTrust(conf.PkiRootCaFile, conf.PkiIntermediateCaFile)
+		if err != nil {
+			log.WithError(err).Panic("Error in loading PKI trust anchors")
+			return
+		}
+		apiController.SetCertificateTrust(trust)
+	}
+
+	keys, err := keystore.Open(conf)
+	if err != nil {
+		log.WithError(err).Panic("Error in opening key backend")
+		return
+	}
+	defer keys.Close()
+
+	if ref := conf.SigningKeyRef(); ref != "" {
+		signer, err := keys.Signer(ref)
+		if err != nil {
+			log.WithError(err).Panic("Error in loading response signing key")
+			return
+		}
+		rs, err := api.NewResponseSigner(signer)
+		if err != nil {
+			log.WithError(err).Panic("Error in loading response signing key")
+			return
+		}
+		apiController.SetResponseSigner(rs)
+	}
+
+	if conf.CaCertFile != "" {
+		// issued certificates point to the CRL only when the public address of the registry is known
+		crlUrl := ""
+		if conf.EndpointUrl != "" {
+			crlUrl = strings.TrimSuffix(conf.EndpointUrl, "/") + web.PrefixV1 + "/ca/crl"
+		}
+		signer, err := keys.Signer(conf.CaKeyRef())
+		if err != nil {
+			log.WithError(err).Panic("Error in loading CA key")
+			return
+		}
+		ca, err := api.LoadCertificateAuthority(conf.CaCertFile, signer, conf.CaCertValidity(), crlUrl)
+		if err != nil {
+			log.WithError(err).Panic("Error in loading certificate authority")
+			return
+		}
+		apiController.SetCertificateAuthority(ca)
+	}
+
+	// keys stored before fingerprints were introduced, lookups by fingerprint miss them until this runs
+	if !conf.ReadOnly() {
+		if err = apiController.OrganizationFingerprintBackfill(context.Background()); err != nil {
+			log.WithError(err).Error("Error in computing key fingerprints")
+		}
+	}
+
+	limiter := ratelimit.New()
+	s := web.NewServer(apiController, confStore, limiter)
+	accessLogger, err := logging.NewAccessLogger(conf)
+	if err != nil {
+		log.WithError(err).Panic("Error in setting up access log")
+		return
+	}
+
+	middlewares := []mux.MiddlewareFunc{
+		s.RequestIdMiddleware,
+		s.TracingMiddleware,
+		s.AccessLogMiddleware(accessLogger),
+		s.LimitMiddleware,
+		s.ClientCertificateMiddleware,
+	}
+	if conf.ReadOnly() {
+		middlewares = append(middlewares, s.ReadOnlyMiddleware)
+	}
+
 	r := mux.NewRouter()
+	s.RegisterRoutes(r, middlewares...)
 
-	r.HandleFunc("/api/organization", s.HandleOrganizationList)
+	tlsReloader, err := tlsconf.NewReloader(conf)
+	if err != nil {
+		log.WithError(err).Panic("Error in setting up TLS")
+		return
+	}
+	var tlsConfig *tls.Config
+	if tlsReloader != nil {
+		tlsConfig = tlsReloader.ServerConfig()
+		go tlsReloader.Watch(quit)
+	}
 
 	srv := &http.Server{
 		Addr:         conf.ListenAddress,
 		Handler:      r,
+		TLSConfig:    tlsConfig,
 		ReadTimeout:  60 * time.Second,
 		WriteTimeout: 45 * time.Second,
 	}
@@ -71,27 +373,88 @@ func setupServer(quit chan interface{}, signalChan chan os.Signal, conf *config.
 		return
 	}
 
-	log.WithField("listen", conf.ListenAddress).Info("Starting HTTP API Server")
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	log.WithFields(log.Fields{
+		"listen": conf.ListenAddress,
+		"tls":    tlsConfig != nil,
+		"mtls":   tlsReloader != nil && tlsReloader.MutualTLS(),
+	}).Info("Starting HTTP API Server")
 	go startServer(srv, listener)
 
+	var grpcSrv *grpc.Server
+	if conf.GrpcListenAddress != "" {
+		var grpcTlsConfig *tls.Config
+		if tlsReloader != nil {
+			grpcTlsConfig = tlsReloader.ServerConfig("h2")
+		}
+		grpcSrv, err = startGrpcServer(grpcapi.NewServer(apiController, confStore, limiter), conf.GrpcListenAddress, grpcTlsConfig)
+		if err != nil {
+			log.WithError(err).Panic("Error in setting up gRPC listener")
+			return
+		}
+	}
+
+	if mirrorAccess != nil {
+		mirrorCtx, mirrorCancel := context.WithCancel(context.Background())
+		go func() {
+			<-quit
+			mirrorCancel()
+		}()
+		log.WithFields(log.Fields{
+			"upstream": conf.MirrorUpstreamUrl,
+			"interval": conf.MirrorSyncInterval(),
+		}).Info("Starting mirror sync")
+		go apiController.MirrorSyncLoop(mirrorCtx, conf.MirrorSyncInterval())
+	}
+
+	// probe results are stored, which read-only registries cannot do
+	if interval := conf.ProbeInterval(); interval > 0 && !conf.ReadOnly() {
+		probeCtx, probeCancel := context.WithCancel(context.Background())
+		go func() {
+			<-quit
+			probeCancel()
+		}()
+		log.WithFields(log.Fields{
+			"interval": interval,
+			"timeout":  conf.ProbeTimeout(),
+		}).Info("Starting organization endpoint prober")
+		go apiController.OrganizationProbeLoop(probeCtx, api.NewProbeClient(conf.ProbeTimeout()), interval)
+	}
+
 	for {
 		select {
 		case <-quit:
-			log.Warn("quit channel closed, closing listener")
-			err = srv.Close()
-			if err != nil {
-				log.WithError(err).Error("error during HTTP Server close")
-			}
-			err = listener.Close()
-			if err != nil {
-				log.WithError(err).Error("error during TCP Listener close")
-			}
-			return
+			log.Warn("quit channel closed, draining HTTP server")
+			return shutdown(s, srv, grpcSrv, confStore.Get())
 		case sig := <-signalChan:
 			switch sig {
 			case os.Interrupt, os.Kill, syscall.SIGTERM:
 				log.Info("interrupt signal received, sending Quit signal")
 				close(quit)
+			case syscall.SIGHUP:
+				log.Info("hangup signal received, reloading config")
+				_, err = confStore.Reload()
+				if err != nil {
+					log.WithError(err).Error("error reloading config, keeping previous one")
+				}
+				err = logging.SetLevel(confStore.Get())
+				if err != nil {
+					log.WithError(err).Error("error applying log level")
+				}
+				if snapshotAccess != nil {
+					if err = loadSnapshot(snapshotAccess, confStore.Get()); err != nil {
+						log.WithError(err).Error("error reloading snapshot, keeping previous one")
+					}
+				}
+				if tlsReloader != nil {
+					err = tlsReloader.Reload()
+					if err != nil {
+						log.WithError(err).Error("error reloading TLS certificate, keeping previous one")
+					}
+				}
 			default:
 				log.WithField("signal", sig).Info("signal received")
 			}
@@ -99,9 +462,66 @@ func setupServer(quit chan interface{}, signalChan chan os.Signal, conf *config.
 	}
 }
 
+// shutdown fails readiness, waits for shutdown delay, then stops accepting connections
+// and waits up to grace period for in-flight requests before closing them forcibly.
+// gRPC watch streams never finish by themselves, so gRPC server is stopped without waiting for them.
+func shutdown(s *web.Server, srv *http.Server, grpcSrv *grpc.Server, conf *config.Config) (exitCode int) {
+	s.SetDraining()
+	if delay := conf.ShutdownDelay(); delay > 0 {
+		log.WithField("delay", delay).Info("readiness failing, waiting before closing listener")
+		time.Sleep(delay)
+	}
+	grace := conf.ShutdownGrace()
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	log.WithField("grace", grace).Info("closing listener, waiting for in-flight requests")
+	if grpcSrv != nil {
+		grpcSrv.Stop()
+		log.Info("gRPC server stopped")
+	}
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		log.WithError(err).Error("in-flight requests did not finish in grace period, closing connections")
+		err = srv.Close()
+		if err != nil {
+			log.WithError(err).Error("error during HTTP Server close")
+		}
+		return exitCodeDrainTimeout
+	}
+	log.Info("HTTP server drained")
+	return exitCodeOK
+}
+
 func startServer(srv *http.Server, listener net.Listener) {
 	err := srv.Serve(listener)
-	if err != nil {
+	if err != nil && err != http.ErrServerClosed {
 		log.WithError(err).Error("HTTP server Error")
 	}
 }
+
+// startGrpcServer serves gRPC API on address, with TLS when tlsConfig is set
+func startGrpcServer(gs *grpcapi.Server, address string, tlsConfig *tls.Config) (srv *grpc.Server, err error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return
+	}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(gs.UnaryInterceptor),
+		grpc.StreamInterceptor(gs.StreamInterceptor),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	srv = grpc.NewServer(opts...)
+	registryv1.RegisterRegistryServiceServer(srv, gs)
+	log.WithFields(log.Fields{
+		"listen": address,
+		"tls":    tlsConfig != nil,
+	}).Info("Starting gRPC API Server")
+	go func() {
+		if err := srv.Serve(listener); err != nil {
+			log.WithError(err).Error("gRPC server Error")
+		}
+	}()
+	return
+}
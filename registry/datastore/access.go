@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v4"
 
@@ -9,9 +10,47 @@ import (
 )
 
 type Access interface {
-	OrganizationAdd(ctx context.Context, pTx pgx.Tx, name, label string, dmsType entity.DMSType, url, publicKey string) (item *entity.Organization, err error)
-	OrganizationUpdate(ctx context.Context, pTx pgx.Tx, item *entity.Organization, name, label string, dmsType entity.DMSType, url, publicKey string) (err error)
-	OrganizationChangeState(ctx context.Context, pTx pgx.Tx, item *entity.Organization, state entity.EntityState) (err error)
+	Ping(ctx context.Context) (err error)
+	Close()
+	// RunInTx runs f in one transaction, which is committed when f returns nil and rolled back otherwise;
+	// methods given tx join it. Error of f is returned as is.
+	RunInTx(ctx context.Context, f func(tx pgx.Tx) error) (err error)
+
+	OrganizationAdd(ctx context.Context, pTx pgx.Tx, actor string, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey) (item *entity.Organization, err error)
+	OrganizationUpdate(ctx context.Context, pTx pgx.Tx, actor string, item *entity.Organization, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey) (err error)
+	OrganizationChangeState(ctx context.Context, pTx pgx.Tx, actor string, item *entity.Organization, state entity.EntityState) (err error)
 	OrganizationById(ctx context.Context, id int) (item *entity.Organization, err error)
+	OrganizationByName(ctx context.Context, name string) (item *entity.Organization, err error)
 	OrganizationList(ctx context.Context) (items []*entity.Organization, err error)
+	OrganizationByKeyFingerprint(ctx context.Context, fingerprint string) (item *entity.Organization, err error)
+	OrganizationNoFingerprintList(ctx context.Context) (items []*entity.Organization, err error)
+	OrganizationFingerprintSet(ctx context.Context, pTx pgx.Tx, item *entity.Organization, fingerprint string) (err error)
+
+	OrganizationRegister(ctx context.Context, pTx pgx.Tx, actor string, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey, tokenHash string) (item *entity.OrganizationRegistration, err error)
+	OrganizationRegistrationReview(ctx context.Context, pTx pgx.Tx, actor string, item *entity.OrganizationRegistration, state entity.EntityState, reason string) (err error)
+	OrganizationRegistrationById(ctx context.Context, id int) (item *entity.OrganizationRegistration, err error)
+	OrganizationRegistrationList(ctx context.Context, state entity.EntityState) (items []*entity.OrganizationRegistration, err error)
+
+	KeyChallengeAdd(ctx context.Context, pTx pgx.Tx, nonce, keyHash string, expireTs time.Time) (item *entity.KeyChallenge, err error)
+	KeyChallengeById(ctx context.Context, id int) (item *entity.KeyChallenge, err error)
+	KeyChallengeUse(ctx context.Context, pTx pgx.Tx, item *entity.KeyChallenge) (err error)
+
+	IssuedCertificateAdd(ctx context.Context, pTx pgx.Tx, actor string, item *entity.IssuedCertificate) (err error)
+	IssuedCertificateBySerial(ctx context.Context, serial string) (item *entity.IssuedCertificate, err error)
+	IssuedCertificateList(ctx context.Context, organizationId int) (items []*entity.IssuedCertificate, err error)
+	IssuedCertificateRevokedList(ctx context.Context) (items []*entity.IssuedCertificate, err error)
+	IssuedCertificateRevoke(ctx context.Context, pTx pgx.Tx, actor string, item *entity.IssuedCertificate, reason entity.RevocationReason) (err error)
+
+	RevokedKeyByFingerprint(ctx context.Context, organizationId int, fingerprint string) (item *entity.RevokedKey, err error)
+
+	OrganizationProbeSave(ctx context.Context, pTx pgx.Tx, item *entity.OrganizationProbe) (err error)
+	OrganizationProbeList(ctx context.Context) (items []*entity.OrganizationProbe, err error)
+
+	AdminAdd(ctx context.Context, pTx pgx.Tx, actor string, username, passwordHash string, role entity.AdminRole) (item *entity.Admin, err error)
+	AdminById(ctx context.Context, id int) (item *entity.Admin, err error)
+	AdminByUsername(ctx context.Context, username string) (item *entity.Admin, err error)
+	AdminTokenAdd(ctx context.Context, pTx pgx.Tx, actor string, admin *entity.Admin, tokenHash, label string, expireTs time.Time) (item *entity.AdminToken, err error)
+	AdminTokenByHash(ctx context.Context, tokenHash string) (item *entity.AdminToken, err error)
+
+	AuditLogList(ctx context.Context, limit int) (items []*entity.AuditLog, err error)
 }
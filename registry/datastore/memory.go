@@ -0,0 +1,546 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+// MemoryAccess keeps everything in memory, it runs handlers without database in the contract check and tests.
+// Unique constraints are reported the way PostgreSQL does, so that api maps them to the same responses.
+type MemoryAccess struct {
+	mu            sync.Mutex
+	organizations []*entity.Organization
+	registrations map[int]*entity.OrganizationRegistration
+	admins        []*entity.Admin
+	tokens        []*entity.AdminToken
+	auditLogs     []*entity.AuditLog
+	challenges    []*entity.KeyChallenge
+	probes        map[int]*entity.OrganizationProbe
+	certificates  []*entity.IssuedCertificate
+	revokedKeys   []*entity.RevokedKey
+}
+
+var _ Access = (*MemoryAccess)(nil)
+
+func NewMemoryAccess() *MemoryAccess {
+	return &MemoryAccess{
+		registrations: make(map[int]*entity.OrganizationRegistration),
+		probes:        make(map[int]*entity.OrganizationProbe),
+	}
+}
+
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{Code: pgCodeUniqueViolation, ConstraintName: constraint}
+}
+
+func (m *MemoryAccess) audit(actor string, action entity.AuditAction, objectType string, objectId int, details string) {
+	m.auditLogs = append(m.auditLogs, &entity.AuditLog{
+		Id:         len(m.auditLogs) + 1,
+		Actor:      actor,
+		Action:     action,
+		ObjectType: objectType,
+		ObjectId:   objectId,
+		Details:    details,
+		CreateTs:   time.Now().UTC(),
+	})
+}
+
+func (m *MemoryAccess) Ping(ctx context.Context) (err error) {
+	return nil
+}
+
+func (m *MemoryAccess) Close() {
+}
+
+// RunInTx runs f without isolation, changes made before f fails are kept
+func (m *MemoryAccess) RunInTx(ctx context.Context, f func(tx pgx.Tx) error) (err error) {
+	return f(nil)
+}
+
+// organizationConflict checks unique constraints, which hold only among enabled organizations
+func (m *MemoryAccess) organizationConflict(id int, name, url string, key entity.OrganizationKey, state entity.EntityState) error {
+	if state != entity.EntityStateEnabled {
+		return nil
+	}
+	for _, o := range m.organizations {
+		switch {
+		case o.Id == id || o.State != entity.EntityStateEnabled:
+		case o.Name == name:
+			return uniqueViolation("uq_organization_name")
+		case o.Url == url:
+			return uniqueViolation("uq_organization_url")
+		case o.Fingerprint == key.Fingerprint:
+			return uniqueViolation("uq_organization_key_fingerprint")
+		}
+	}
+	return nil
+}
+
+func (m *MemoryAccess) OrganizationAdd(ctx context.Context, pTx pgx.Tx, actor string, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey) (item *entity.Organization, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.organizationAdd(actor, entity.AuditActionOrganizationAdd, name, label, dmsType, url, key, entity.EntityStateEnabled)
+}
+
+func (m *MemoryAccess) organizationAdd(actor string, action entity.AuditAction, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey, state entity.EntityState) (item *entity.Organization, err error) {
+	if err = m.organizationConflict(0, name, url, key, state); err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	item = &entity.Organization{
+		Id:              len(m.organizations) + 1,
+		Name:            name,
+		Label:           label,
+		Url:             url,
+		Type:            dmsType,
+		OrganizationKey: key,
+		State:           state,
+		CreateTs:        now,
+		UpdateTs:        now,
+		Version:         1,
+	}
+	m.organizations = append(m.organizations, item)
+	m.audit(actor, action, "organization", item.Id, fmt.Sprintf("name=%s state=%s", item.Name, item.State))
+	c := *item
+	return &c, nil
+}
+
+func (m *MemoryAccess) organizationUpdate(actor string, action entity.AuditAction, item *entity.Organization, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey, state entity.EntityState) (err error) {
+	stored := m.organizations[item.Id-1]
+	if stored.Version != item.Version {
+		return ErrNoRowsAffected
+	}
+	if err = m.organizationConflict(item.Id, name, url, key, state); err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	if reason, revoke := KeyRevocationReason(item, key, state); revoke {
+		count := 0
+		for _, c := range m.certificates {
+			if c.OrganizationId == item.Id && c.RevokedTs == nil && c.NotAfter.After(now) {
+				c.RevokedTs = &now
+				c.RevocationReason = reason
+				count++
+			}
+		}
+		if count > 0 {
+			m.audit(actor, entity.AuditActionCertificateRevoke, "organization", item.Id, fmt.Sprintf("count=%d reason=%s", count, reason))
+		}
+		if item.Fingerprint != "" {
+			m.revokedKeys = append(m.revokedKeys, &entity.RevokedKey{
+				Id:               len(m.revokedKeys) + 1,
+				OrganizationId:   item.Id,
+				KeyFingerprint:   item.Fingerprint,
+				RevocationReason: reason,
+				RevokedTs:        now,
+			})
+		}
+	}
+	item.Name = name
+	item.Label = label
+	item.Type = dmsType
+	item.Url = url
+	item.OrganizationKey = key
+	item.State = state
+	item.UpdateTs = now
+	item.Version++
+	*stored = *item
+	m.audit(actor, action, "organization", item.Id, fmt.Sprintf("name=%s state=%s", name, state))
+	return nil
+}
+
+func (m *MemoryAccess) OrganizationUpdate(ctx context.Context, pTx pgx.Tx, actor string, item *entity.Organization, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey) (err error) {
+	action := entity.AuditActionOrganizationUpdate
+	if !key.SameKey(item.OrganizationKey) {
+		action = entity.AuditActionOrganizationKeyChange
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.organizationUpdate(actor, action, item, name, label, dmsType, url, key, item.State)
+}
+
+func (m *MemoryAccess) OrganizationChangeState(ctx context.Context, pTx pgx.Tx, actor string, item *entity.Organization, state entity.EntityState) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.organizationUpdate(actor, entity.AuditActionOrganizationChangeState, item, item.Name, item.Label, item.Type, item.Url, item.OrganizationKey, state)
+}
+
+func (m *MemoryAccess) OrganizationById(ctx context.Context, id int) (item *entity.Organization, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.organizations {
+		if o.Id == id && o.State != entity.EntityStateDeleted {
+			c := *o
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryAccess) OrganizationByName(ctx context.Context, name string) (item *entity.Organization, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.organizations {
+		if o.Name == name && o.State == entity.EntityStateEnabled {
+			c := *o
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryAccess) OrganizationList(ctx context.Context) (items []*entity.Organization, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.organizations {
+		if o.State == entity.EntityStateEnabled || o.State == entity.EntityStateDisabled {
+			c := *o
+			items = append(items, &c)
+		}
+	}
+	return
+}
+
+func (m *MemoryAccess) OrganizationByKeyFingerprint(ctx context.Context, fingerprint string) (item *entity.Organization, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.organizations {
+		if o.Fingerprint != fingerprint {
+			continue
+		}
+		if item == nil || o.State == entity.EntityStateEnabled ||
+			(item.State != entity.EntityStateEnabled && !o.UpdateTs.Before(item.UpdateTs)) {
+			item = o
+		}
+	}
+	if item != nil {
+		c := *item
+		item = &c
+	}
+	return
+}
+
+func (m *MemoryAccess) OrganizationNoFingerprintList(ctx context.Context) (items []*entity.Organization, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.organizations {
+		if o.Fingerprint == "" {
+			c := *o
+			items = append(items, &c)
+		}
+	}
+	return
+}
+
+func (m *MemoryAccess) OrganizationFingerprintSet(ctx context.Context, pTx pgx.Tx, item *entity.Organization, fingerprint string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.organizations {
+		if o.Id == item.Id && o.PublicKey == item.PublicKey && o.Fingerprint == "" {
+			o.Fingerprint = fingerprint
+			item.Fingerprint = fingerprint
+			return nil
+		}
+	}
+	return ErrNoRowsAffected
+}
+
+func (m *MemoryAccess) OrganizationRegister(ctx context.Context, pTx pgx.Tx, actor string, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey, tokenHash string) (item *entity.OrganizationRegistration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	organization, err := m.organizationAdd(actor, entity.AuditActionOrganizationRegister, name, label, dmsType, url, key, entity.EntityStatePending)
+	if err != nil {
+		return
+	}
+	m.registrations[organization.Id] = &entity.OrganizationRegistration{TokenHash: tokenHash}
+	return &entity.OrganizationRegistration{Organization: *organization, TokenHash: tokenHash}, nil
+}
+
+func (m *MemoryAccess) OrganizationRegistrationReview(ctx context.Context, pTx pgx.Tx, actor string, item *entity.OrganizationRegistration, state entity.EntityState, reason string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	action := entity.AuditActionOrganizationApprove
+	if state == entity.EntityStateRejected {
+		action = entity.AuditActionOrganizationReject
+	}
+	if err = m.organizationUpdate(actor, action, &item.Organization, item.Name, item.Label, item.Type, item.Url, item.OrganizationKey, state); err != nil {
+		return
+	}
+	m.registrations[item.Id].Reason = reason
+	item.Reason = reason
+	return nil
+}
+
+func (m *MemoryAccess) registration(o *entity.Organization) *entity.OrganizationRegistration {
+	r := m.registrations[o.Id]
+	return &entity.OrganizationRegistration{Organization: *o, TokenHash: r.TokenHash, Reason: r.Reason}
+}
+
+func (m *MemoryAccess) OrganizationRegistrationById(ctx context.Context, id int) (item *entity.OrganizationRegistration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.registrations[id]; !ok {
+		return nil, nil
+	}
+	return m.registration(m.organizations[id-1]), nil
+}
+
+func (m *MemoryAccess) OrganizationRegistrationList(ctx context.Context, state entity.EntityState) (items []*entity.OrganizationRegistration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.organizations {
+		if _, ok := m.registrations[o.Id]; ok && o.State == state {
+			items = append(items, m.registration(o))
+		}
+	}
+	return
+}
+
+func (m *MemoryAccess) KeyChallengeAdd(ctx context.Context, pTx pgx.Tx, nonce, keyHash string, expireTs time.Time) (item *entity.KeyChallenge, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item = &entity.KeyChallenge{
+		Id:       len(m.challenges) + 1,
+		Nonce:    nonce,
+		KeyHash:  keyHash,
+		ExpireTs: expireTs,
+		CreateTs: time.Now().UTC(),
+	}
+	m.challenges = append(m.challenges, item)
+	c := *item
+	return &c, nil
+}
+
+func (m *MemoryAccess) KeyChallengeById(ctx context.Context, id int) (item *entity.KeyChallenge, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id <= 0 || id > len(m.challenges) {
+		return nil, nil
+	}
+	c := *m.challenges[id-1]
+	return &c, nil
+}
+
+func (m *MemoryAccess) KeyChallengeUse(ctx context.Context, pTx pgx.Tx, item *entity.KeyChallenge) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.challenges[item.Id-1]
+	if stored.UsedTs != nil {
+		return ErrNoRowsAffected
+	}
+	now := time.Now().UTC()
+	stored.UsedTs = &now
+	item.UsedTs = &now
+	return nil
+}
+
+func (m *MemoryAccess) IssuedCertificateAdd(ctx context.Context, pTx pgx.Tx, actor string, item *entity.IssuedCertificate) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.certificates {
+		if c.Serial == item.Serial {
+			return uniqueViolation("uq_issued_certificate_serial")
+		}
+	}
+	item.Id = len(m.certificates) + 1
+	item.CreateTs = time.Now().UTC()
+	c := *item
+	m.certificates = append(m.certificates, &c)
+	m.audit(actor, entity.AuditActionCertificateIssue, "organization", item.OrganizationId, fmt.Sprintf("serial=%s usage=%s", item.Serial, item.Usage))
+	return nil
+}
+
+func (m *MemoryAccess) IssuedCertificateBySerial(ctx context.Context, serial string) (item *entity.IssuedCertificate, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.certificates {
+		if c.Serial == serial {
+			cp := *c
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryAccess) IssuedCertificateList(ctx context.Context, organizationId int) (items []*entity.IssuedCertificate, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items = make([]*entity.IssuedCertificate, 0)
+	for i := len(m.certificates) - 1; i >= 0; i-- {
+		if c := m.certificates[i]; c.OrganizationId == organizationId {
+			cp := *c
+			items = append(items, &cp)
+		}
+	}
+	return
+}
+
+func (m *MemoryAccess) IssuedCertificateRevokedList(ctx context.Context) (items []*entity.IssuedCertificate, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items = make([]*entity.IssuedCertificate, 0)
+	now := time.Now()
+	for _, c := range m.certificates {
+		if c.RevokedTs != nil && c.NotAfter.After(now) {
+			cp := *c
+			items = append(items, &cp)
+		}
+	}
+	return
+}
+
+func (m *MemoryAccess) IssuedCertificateRevoke(ctx context.Context, pTx pgx.Tx, actor string, item *entity.IssuedCertificate, reason entity.RevocationReason) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.certificates[item.Id-1]
+	if stored.RevokedTs != nil {
+		return ErrNoRowsAffected
+	}
+	now := time.Now().UTC()
+	stored.RevokedTs = &now
+	stored.RevocationReason = reason
+	item.RevokedTs = &now
+	item.RevocationReason = reason
+	m.audit(actor, entity.AuditActionCertificateRevoke, "organization", item.OrganizationId, fmt.Sprintf("serial=%s reason=%s", item.Serial, reason))
+	return nil
+}
+
+func (m *MemoryAccess) RevokedKeyByFingerprint(ctx context.Context, organizationId int, fingerprint string) (item *entity.RevokedKey, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.revokedKeys) - 1; i >= 0; i-- {
+		if k := m.revokedKeys[i]; k.OrganizationId == organizationId && k.KeyFingerprint == fingerprint {
+			c := *k
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryAccess) OrganizationProbeSave(ctx context.Context, pTx pgx.Tx, item *entity.OrganizationProbe) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev, ok := m.probes[item.OrganizationId]; ok {
+		if item.CertExpireTs == nil {
+			item.CertExpireTs = prev.CertExpireTs
+		}
+		if item.SuccessTs == nil {
+			item.SuccessTs = prev.SuccessTs
+		}
+	}
+	c := *item
+	m.probes[item.OrganizationId] = &c
+	return nil
+}
+
+func (m *MemoryAccess) OrganizationProbeList(ctx context.Context) (items []*entity.OrganizationProbe, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items = make([]*entity.OrganizationProbe, 0)
+	for _, o := range m.organizations {
+		p, ok := m.probes[o.Id]
+		if !ok || o.State != entity.EntityStateEnabled {
+			continue
+		}
+		c := *p
+		c.OrganizationName = o.Name
+		items = append(items, &c)
+	}
+	return
+}
+
+func (m *MemoryAccess) AdminAdd(ctx context.Context, pTx pgx.Tx, actor string, username, passwordHash string, role entity.AdminRole) (item *entity.Admin, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.admins {
+		if a.Username == username {
+			return nil, uniqueViolation("uq_admin_username")
+		}
+	}
+	now := time.Now().UTC()
+	item = &entity.Admin{
+		Id:           len(m.admins) + 1,
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         role,
+		State:        entity.EntityStateEnabled,
+		CreateTs:     now,
+		UpdateTs:     now,
+		Version:      1,
+	}
+	m.admins = append(m.admins, item)
+	m.audit(actor, entity.AuditActionAdminAdd, "admin", item.Id, fmt.Sprintf("username=%s role=%s", item.Username, item.Role))
+	c := *item
+	return &c, nil
+}
+
+func (m *MemoryAccess) AdminById(ctx context.Context, id int) (item *entity.Admin, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.admins {
+		if a.Id == id && a.State != entity.EntityStateDeleted {
+			c := *a
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryAccess) AdminByUsername(ctx context.Context, username string) (item *entity.Admin, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.admins {
+		if a.Username == username && a.State != entity.EntityStateDeleted {
+			c := *a
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryAccess) AdminTokenAdd(ctx context.Context, pTx pgx.Tx, actor string, admin *entity.Admin, tokenHash, label string, expireTs time.Time) (item *entity.AdminToken, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item = &entity.AdminToken{
+		Id:        len(m.tokens) + 1,
+		AdminId:   admin.Id,
+		TokenHash: tokenHash,
+		Label:     label,
+		State:     entity.EntityStateEnabled,
+		ExpireTs:  expireTs,
+		CreateTs:  time.Now().UTC(),
+	}
+	m.tokens = append(m.tokens, item)
+	m.audit(actor, entity.AuditActionAdminTokenAdd, "admin", admin.Id, fmt.Sprintf("token_id=%d label=%s", item.Id, item.Label))
+	c := *item
+	return &c, nil
+}
+
+func (m *MemoryAccess) AdminTokenByHash(ctx context.Context, tokenHash string) (item *entity.AdminToken, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash && t.State != entity.EntityStateDeleted {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryAccess) AuditLogList(ctx context.Context, limit int) (items []*entity.AuditLog, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.auditLogs) - 1; i >= 0 && len(items) < limit; i-- {
+		c := *m.auditLogs[i]
+		items = append(items, &c)
+	}
+	return
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"math"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
 )
 
 type PgAccess struct {
@@ -31,9 +34,20 @@ type pgxQuery func(conn *pgxpool.Conn) (err error)
 
 var ErrNoRowsAffected = errors.New("no rows affected")
 
+const pgCodeUniqueViolation = "23505"
+
+// UniqueViolation reports name of the unique index violated by err, if any
+func UniqueViolation(err error) (constraint string, ok bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgCodeUniqueViolation {
+		return pgErr.ConstraintName, true
+	}
+	return "", false
+}
+
 func NewPgAccess(conf *config.Config) (pg *PgAccess, err error) {
 	var pool *pgxpool.Pool
-	pool, err = pgxpool.Connect(context.Background(), config.Conf.DbConn)
+	pool, err = pgxpool.Connect(context.Background(), conf.DbConn)
 	if err != nil {
 		eMsg := "error creating connection pool"
 		log.WithError(err).Error(eMsg)
@@ -48,6 +62,39 @@ func NewPgAccessWithPool(pool *pgxpool.Pool) (pg *PgAccess) {
 	return &PgAccess{pool: pool}
 }
 
+func (d *PgAccess) Ping(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.Ping")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.Ping",
+	})
+	return d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		return conn.Conn().Ping(ctx)
+	})
+}
+
+// Close waits for acquired connections to be released and closes the pool
+func (d *PgAccess) Close() {
+	d.pool.Close()
+}
+
+func (d *PgAccess) RunInTx(ctx context.Context, f func(tx pgx.Tx) error) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.RunInTx")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.RunInTx",
+	})
+	var fErr error
+	err = d.runInTx(ctx, nil, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		fErr = f(tx)
+		return fErr != nil, nil
+	})
+	if fErr != nil {
+		return fErr
+	}
+	return
+}
+
 func (d *PgAccess) runInTx(ctx context.Context, pTx pgx.Tx, clog *log.Entry, f pgxWithTx) (err error) {
 	var conn *pgxpool.Conn
 	defer func() {
@@ -66,7 +113,9 @@ func (d *PgAccess) runInTx(ctx context.Context, pTx pgx.Tx, clog *log.Entry, f p
 		}
 	}()
 	if pTx == nil {
-		conn, err = d.pool.Acquire(ctx)
+		acquireCtx, acquireSpan := tracing.Start(ctx, "pgxpool.Acquire")
+		conn, err = d.pool.Acquire(acquireCtx)
+		tracing.End(acquireSpan, err)
 		if err != nil {
 			clog.WithError(err).Error("error acquiring connection")
 			return
@@ -92,7 +141,9 @@ func (d *PgAccess) runInTx(ctx context.Context, pTx pgx.Tx, clog *log.Entry, f p
 		return errors.Wrap(err, eMsg)
 	}
 	if !rollback {
-		err = tx.Commit(ctx)
+		commitCtx, commitSpan := tracing.Start(ctx, "pgx.Commit")
+		err = tx.Commit(commitCtx)
+		tracing.End(commitSpan, err)
 		if err != nil {
 			eMsg := "Error in tx.Commit"
 			clog.WithError(err).Error(eMsg)
@@ -109,7 +160,9 @@ func (d *PgAccess) runQuery(ctx context.Context, clog *log.Entry, f pgxQuery) (e
 			conn.Release()
 		}
 	}()
-	conn, err = d.pool.Acquire(ctx)
+	acquireCtx, acquireSpan := tracing.Start(ctx, "pgxpool.Acquire")
+	conn, err = d.pool.Acquire(acquireCtx)
+	tracing.End(acquireSpan, err)
 	if err != nil {
 		clog.WithError(err).Error("error acquiring connection")
 		return
@@ -0,0 +1,208 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	sqlAdminAdd        = `INSERT INTO tbl_admin(username, password_hash, role, state, create_ts, update_ts, version) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	sqlAdminById       = `SELECT id, username, password_hash, role, state, create_ts, update_ts, version FROM tbl_admin WHERE id=$1 AND state!=$2`
+	sqlAdminByUsername = `SELECT id, username, password_hash, role, state, create_ts, update_ts, version FROM tbl_admin WHERE username=$1 AND state!=$2`
+
+	sqlAdminTokenAdd    = `INSERT INTO tbl_admin_token(admin_id, token_hash, label, state, expire_ts, create_ts) VALUES($1, $2, $3, $4, $5, $6) RETURNING id`
+	sqlAdminTokenByHash = `SELECT id, admin_id, token_hash, label, state, expire_ts, create_ts FROM tbl_admin_token WHERE token_hash=$1 AND state!=$2`
+)
+
+func (d *PgAccess) AdminAdd(ctx context.Context, pTx pgx.Tx, actor string, username, passwordHash string, role entity.AdminRole) (item *entity.Admin, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.AdminAdd", tracing.Statement("sqlAdminAdd"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.AdminAdd",
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		now := time.Now().UTC().Round(time.Microsecond)
+		item = &entity.Admin{
+			Username:     username,
+			PasswordHash: passwordHash,
+			Role:         role,
+			State:        entity.EntityStateEnabled,
+			CreateTs:     now,
+			UpdateTs:     now,
+			Version:      0,
+		}
+		// sqlAdminAdd        = `INSERT INTO tbl_admin(username, password_hash, role, state, create_ts, update_ts, version) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+		row := tx.QueryRow(ctx, sqlAdminAdd, item.Username, item.PasswordHash, item.Role, item.State, item.CreateTs, item.UpdateTs, item.Version)
+		err = row.Scan(&item.Id)
+		if err != nil {
+			eMsg := "error in sqlAdminAdd"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		err = d.auditLogAddAtomic(ctx, tx, actor, entity.AuditActionAdminAdd, auditObjectAdmin, item.Id, fmt.Sprintf("username=%s role=%s", item.Username, item.Role))
+		if err != nil {
+			eMsg := "error in d.auditLogAddAtomic"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in d.runInTx()"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+func (d *PgAccess) adminQueryRow(ctx context.Context, clog *log.Entry, sql string, args ...interface{}) (item *entity.Admin, err error) {
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.Admin{}
+		row := conn.QueryRow(ctx, sql, args...)
+		err = row.Scan(&item.Id, &item.Username, &item.PasswordHash, &item.Role, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				err = nil
+				item = nil
+				return
+			}
+			eMsg := "error in row.Scan"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+func (d *PgAccess) AdminById(ctx context.Context, id int) (item *entity.Admin, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.AdminById", tracing.Statement("sqlAdminById"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.AdminById",
+	})
+	// sqlAdminById       = `SELECT id, username, password_hash, role, state, create_ts, update_ts, version FROM tbl_admin WHERE id=$1 AND state!=$2`
+	return d.adminQueryRow(ctx, clog, sqlAdminById, id, entity.EntityStateDeleted)
+}
+
+func (d *PgAccess) AdminByUsername(ctx context.Context, username string) (item *entity.Admin, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.AdminByUsername", tracing.Statement("sqlAdminByUsername"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.AdminByUsername",
+	})
+	// sqlAdminByUsername = `SELECT id, username, password_hash, role, state, create_ts, update_ts, version FROM tbl_admin WHERE username=$1 AND state!=$2`
+	return d.adminQueryRow(ctx, clog, sqlAdminByUsername, username, entity.EntityStateDeleted)
+}
+
+func (d *PgAccess) AdminTokenAdd(ctx context.Context, pTx pgx.Tx, actor string, admin *entity.Admin, tokenHash, label string, expireTs time.Time) (item *entity.AdminToken, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.AdminTokenAdd", tracing.Statement("sqlAdminTokenAdd"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.AdminTokenAdd",
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		now := time.Now().UTC().Round(time.Microsecond)
+		item = &entity.AdminToken{
+			AdminId:   admin.Id,
+			TokenHash: tokenHash,
+			Label:     label,
+			State:     entity.EntityStateEnabled,
+			ExpireTs:  expireTs.UTC().Round(time.Microsecond),
+			CreateTs:  now,
+		}
+		// sqlAdminTokenAdd    = `INSERT INTO tbl_admin_token(admin_id, token_hash, label, state, expire_ts, create_ts) VALUES($1, $2, $3, $4, $5, $6) RETURNING id`
+		row := tx.QueryRow(ctx, sqlAdminTokenAdd, item.AdminId, item.TokenHash, item.Label, item.State, item.ExpireTs, item.CreateTs)
+		err = row.Scan(&item.Id)
+		if err != nil {
+			eMsg := "error in sqlAdminTokenAdd"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		err = d.auditLogAddAtomic(ctx, tx, actor, entity.AuditActionAdminTokenAdd, auditObjectAdmin, admin.Id, fmt.Sprintf("token_id=%d label=%s", item.Id, item.Label))
+		if err != nil {
+			eMsg := "error in d.auditLogAddAtomic"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in d.runInTx()"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+func (d *PgAccess) AdminTokenByHash(ctx context.Context, tokenHash string) (item *entity.AdminToken, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.AdminTokenByHash", tracing.Statement("sqlAdminTokenByHash"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.AdminTokenByHash",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.AdminToken{}
+		// sqlAdminTokenByHash = `SELECT id, admin_id, token_hash, label, state, expire_ts, create_ts FROM tbl_admin_token WHERE token_hash=$1 AND state!=$2`
+		row := conn.QueryRow(ctx, sqlAdminTokenByHash, tokenHash, entity.EntityStateDeleted)
+		err = row.Scan(&item.Id, &item.AdminId, &item.TokenHash, &item.Label, &item.State, &item.ExpireTs, &item.CreateTs)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				err = nil
+				item = nil
+				return
+			}
+			eMsg := "error in sqlAdminTokenByHash"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
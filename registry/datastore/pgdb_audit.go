@@ -0,0 +1,87 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	auditObjectOrganization = "organization"
+	auditObjectAdmin        = "admin"
+)
+
+const (
+	sqlAuditLogAdd  = `INSERT INTO tbl_audit_log(actor, action, object_type, object_id, details, create_ts) VALUES($1, $2, $3, $4, $5, $6)`
+	sqlAuditLogList = `SELECT id, actor, action, object_type, object_id, details, create_ts FROM tbl_audit_log ORDER BY id DESC LIMIT $1`
+)
+
+// auditLogAddAtomic must be called inside the same transaction as the change being audited,
+// so that the change and its audit record are committed or rolled back together
+func (d *PgAccess) auditLogAddAtomic(ctx context.Context, tx pgx.Tx, actor string, action entity.AuditAction, objectType string, objectId int, details string) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.auditLogAddAtomic", tracing.Statement("sqlAuditLogAdd"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.auditLogAddAtomic",
+	})
+	now := time.Now().UTC().Round(time.Microsecond)
+	// sqlAuditLogAdd  = `INSERT INTO tbl_audit_log(actor, action, object_type, object_id, details, create_ts) VALUES($1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(ctx, sqlAuditLogAdd, actor, action, objectType, objectId, details, now)
+	if err != nil {
+		eMsg := "error in sqlAuditLogAdd"
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		return
+	}
+	return
+}
+
+func (d *PgAccess) AuditLogList(ctx context.Context, limit int) (items []*entity.AuditLog, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.AuditLogList", tracing.Statement("sqlAuditLogList"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.AuditLogList",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				items = nil
+			}
+		}()
+		items = make([]*entity.AuditLog, 0)
+		// sqlAuditLogList = `SELECT id, actor, action, object_type, object_id, details, create_ts FROM tbl_audit_log ORDER BY id DESC LIMIT $1`
+		rows, err := conn.Query(ctx, sqlAuditLogList, limit)
+		if err != nil {
+			eMsg := "error in sqlAuditLogList"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			item := &entity.AuditLog{}
+			err = rows.Scan(&item.Id, &item.Actor, &item.Action, &item.ObjectType, &item.ObjectId, &item.Details, &item.CreateTs)
+			if err != nil {
+				eMsg := "error in rows.Scan"
+				clog.WithError(err).Error(eMsg)
+				err = errors.Wrap(err, eMsg)
+				return
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
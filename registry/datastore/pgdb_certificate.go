@@ -0,0 +1,243 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	sqlIssuedCertificateAdd                  = `INSERT INTO tbl_issued_certificate(organization_id, serial, cert_usage, key_fingerprint, certificate, not_before, not_after, revocation_reason, create_ts) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	sqlIssuedCertificateBySerial             = `SELECT id, organization_id, serial, cert_usage, key_fingerprint, certificate, not_before, not_after, revoked_ts, revocation_reason, create_ts FROM tbl_issued_certificate WHERE serial=$1`
+	sqlIssuedCertificateList                 = `SELECT id, organization_id, serial, cert_usage, key_fingerprint, certificate, not_before, not_after, revoked_ts, revocation_reason, create_ts FROM tbl_issued_certificate WHERE organization_id=$1 ORDER BY id DESC`
+	sqlIssuedCertificateRevokedList          = `SELECT id, organization_id, serial, cert_usage, key_fingerprint, certificate, not_before, not_after, revoked_ts, revocation_reason, create_ts FROM tbl_issued_certificate WHERE revoked_ts IS NOT NULL AND not_after>$1 ORDER BY id ASC`
+	sqlIssuedCertificateRevoke               = `UPDATE tbl_issued_certificate SET revoked_ts=$2, revocation_reason=$3 WHERE id=$1 AND revoked_ts IS NULL`
+	sqlIssuedCertificateRevokeByOrganization = `UPDATE tbl_issued_certificate SET revoked_ts=$2, revocation_reason=$3 WHERE organization_id=$1 AND revoked_ts IS NULL AND not_after>$2`
+)
+
+func scanIssuedCertificate(row pgx.Row, item *entity.IssuedCertificate) error {
+	return row.Scan(&item.Id, &item.OrganizationId, &item.Serial, &item.Usage, &item.KeyFingerprint, &item.Certificate, &item.NotBefore, &item.NotAfter, &item.RevokedTs, &item.RevocationReason, &item.CreateTs)
+}
+
+// IssuedCertificateAdd records certificate issued by the registry CA, audited for its organization
+func (d *PgAccess) IssuedCertificateAdd(ctx context.Context, pTx pgx.Tx, actor string, item *entity.IssuedCertificate) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.IssuedCertificateAdd", tracing.Statement("sqlIssuedCertificateAdd"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.IssuedCertificateAdd",
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		item.CreateTs = time.Now().UTC().Round(time.Microsecond)
+		// sqlIssuedCertificateAdd                  = `INSERT INTO tbl_issued_certificate(organization_id, serial, cert_usage, key_fingerprint, certificate, not_before, not_after, revocation_reason, create_ts) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+		row := tx.QueryRow(ctx, sqlIssuedCertificateAdd, item.OrganizationId, item.Serial, item.Usage, item.KeyFingerprint, item.Certificate, item.NotBefore, item.NotAfter, item.RevocationReason, item.CreateTs)
+		err = row.Scan(&item.Id)
+		if err != nil {
+			eMsg := "error in sqlIssuedCertificateAdd"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		err = d.auditLogAddAtomic(ctx, tx, actor, entity.AuditActionCertificateIssue, auditObjectOrganization, item.OrganizationId, fmt.Sprintf("serial=%s usage=%s", item.Serial, item.Usage))
+		if err != nil {
+			eMsg := "error in d.auditLogAddAtomic"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInTx"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+func (d *PgAccess) IssuedCertificateBySerial(ctx context.Context, serial string) (item *entity.IssuedCertificate, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.IssuedCertificateBySerial", tracing.Statement("sqlIssuedCertificateBySerial"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.IssuedCertificateBySerial",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.IssuedCertificate{}
+		// sqlIssuedCertificateBySerial             = `SELECT id, organization_id, serial, cert_usage, key_fingerprint, certificate, not_before, not_after, revoked_ts, revocation_reason, create_ts FROM tbl_issued_certificate WHERE serial=$1`
+		err = scanIssuedCertificate(conn.QueryRow(ctx, sqlIssuedCertificateBySerial, serial), item)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				err = nil
+				item = nil
+				return
+			}
+			eMsg := "error in sqlIssuedCertificateBySerial"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+func (d *PgAccess) issuedCertificateList(ctx context.Context, clog *log.Entry, statement, sql string, args ...interface{}) (items []*entity.IssuedCertificate, err error) {
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				items = nil
+			}
+		}()
+		items = make([]*entity.IssuedCertificate, 0)
+		rows, err := conn.Query(ctx, sql, args...)
+		if err != nil {
+			eMsg := "error in " + statement
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		for rows.Next() {
+			item := &entity.IssuedCertificate{}
+			err = scanIssuedCertificate(rows, item)
+			if err != nil {
+				eMsg := "error in rows.Scan"
+				clog.WithError(err).Error(eMsg)
+				err = errors.Wrap(err, eMsg)
+				return
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+// IssuedCertificateList returns certificates issued for organization, newest first
+func (d *PgAccess) IssuedCertificateList(ctx context.Context, organizationId int) (items []*entity.IssuedCertificate, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.IssuedCertificateList", tracing.Statement("sqlIssuedCertificateList"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.IssuedCertificateList",
+	})
+	// sqlIssuedCertificateList                 = `SELECT id, organization_id, serial, cert_usage, key_fingerprint, certificate, not_before, not_after, revoked_ts, revocation_reason, create_ts FROM tbl_issued_certificate WHERE organization_id=$1 ORDER BY id DESC`
+	return d.issuedCertificateList(ctx, clog, "sqlIssuedCertificateList", sqlIssuedCertificateList, organizationId)
+}
+
+// IssuedCertificateRevokedList returns revoked certificates that have not expired yet, the ones CRL must list
+func (d *PgAccess) IssuedCertificateRevokedList(ctx context.Context) (items []*entity.IssuedCertificate, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.IssuedCertificateRevokedList", tracing.Statement("sqlIssuedCertificateRevokedList"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.IssuedCertificateRevokedList",
+	})
+	// sqlIssuedCertificateRevokedList          = `SELECT id, organization_id, serial, cert_usage, key_fingerprint, certificate, not_before, not_after, revoked_ts, revocation_reason, create_ts FROM tbl_issued_certificate WHERE revoked_ts IS NOT NULL AND not_after>$1 ORDER BY id ASC`
+	return d.issuedCertificateList(ctx, clog, "sqlIssuedCertificateRevokedList", sqlIssuedCertificateRevokedList, time.Now().UTC())
+}
+
+// IssuedCertificateRevoke revokes certificate, returns ErrNoRowsAffected if it was revoked already
+func (d *PgAccess) IssuedCertificateRevoke(ctx context.Context, pTx pgx.Tx, actor string, item *entity.IssuedCertificate, reason entity.RevocationReason) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.IssuedCertificateRevoke", tracing.Statement("sqlIssuedCertificateRevoke"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.IssuedCertificateRevoke",
+		"serial": item.Serial,
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		now := time.Now().UTC().Round(time.Microsecond)
+		// sqlIssuedCertificateRevoke               = `UPDATE tbl_issued_certificate SET revoked_ts=$2, revocation_reason=$3 WHERE id=$1 AND revoked_ts IS NULL`
+		var cmdTag pgconn.CommandTag
+		cmdTag, err = tx.Exec(ctx, sqlIssuedCertificateRevoke, item.Id, now, reason)
+		if err != nil {
+			eMsg := "error in sqlIssuedCertificateRevoke"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		if cmdTag.RowsAffected() == 0 {
+			eMsg := "certificate is already revoked"
+			clog.Warn(eMsg)
+			rollback = true
+			err = errors.Wrap(ErrNoRowsAffected, eMsg)
+			return
+		}
+		err = d.auditLogAddAtomic(ctx, tx, actor, entity.AuditActionCertificateRevoke, auditObjectOrganization, item.OrganizationId, fmt.Sprintf("serial=%s reason=%s", item.Serial, reason))
+		if err != nil {
+			eMsg := "error in d.auditLogAddAtomic"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		item.RevokedTs = &now
+		item.RevocationReason = reason
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInTx"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+// KeyRevocationReason tells whether the current key of item and certificates issued for it must be revoked
+// when item gets key and state
+func KeyRevocationReason(item *entity.Organization, key entity.OrganizationKey, state entity.EntityState) (reason entity.RevocationReason, revoke bool) {
+	switch {
+	case !key.SameKey(item.OrganizationKey):
+		return entity.RevocationReasonSuperseded, true
+	case item.State == entity.EntityStateEnabled && state != entity.EntityStateEnabled:
+		return entity.RevocationReasonCessationOfOperation, true
+	}
+	return "", false
+}
+
+// issuedCertificateRevokeByOrganizationAtomic revokes every valid certificate of organization within tx,
+// when its key is changed or it stops being enabled
+func (d *PgAccess) issuedCertificateRevokeByOrganizationAtomic(ctx context.Context, tx pgx.Tx, actor string, organizationId int, reason entity.RevocationReason, now time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.issuedCertificateRevokeByOrganizationAtomic", tracing.Statement("sqlIssuedCertificateRevokeByOrganization"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.issuedCertificateRevokeByOrganizationAtomic",
+	})
+	// sqlIssuedCertificateRevokeByOrganization = `UPDATE tbl_issued_certificate SET revoked_ts=$2, revocation_reason=$3 WHERE organization_id=$1 AND revoked_ts IS NULL AND not_after>$2`
+	cmdTag, err := tx.Exec(ctx, sqlIssuedCertificateRevokeByOrganization, organizationId, now, reason)
+	if err != nil {
+		eMsg := "error in sqlIssuedCertificateRevokeByOrganization"
+		clog.WithError(err).Error(eMsg)
+		return errors.Wrap(err, eMsg)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return nil
+	}
+	err = d.auditLogAddAtomic(ctx, tx, actor, entity.AuditActionCertificateRevoke, auditObjectOrganization, organizationId, fmt.Sprintf("count=%d reason=%s", cmdTag.RowsAffected(), reason))
+	if err != nil {
+		eMsg := "error in d.auditLogAddAtomic"
+		clog.WithError(err).Error(eMsg)
+		return errors.Wrap(err, eMsg)
+	}
+	return nil
+}
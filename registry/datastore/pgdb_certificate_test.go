@@ -0,0 +1,50 @@
+package datastore
+
+import (
+	"testing"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func TestKeyRevocationReason(t *testing.T) {
+	const (
+		bare = "-----BEGIN PUBLIC KEY-----\nAAAA\n-----END PUBLIC KEY-----\n"
+		cert = "-----BEGIN CERTIFICATE-----\nBBBB\n-----END CERTIFICATE-----\n"
+	)
+	current := entity.OrganizationKey{PublicKey: bare, Fingerprint: "f1"}
+	tests := []struct {
+		name   string
+		stored entity.OrganizationKey
+		state  entity.EntityState
+		key    entity.OrganizationKey
+		newSt  entity.EntityState
+		reason entity.RevocationReason
+		revoke bool
+	}{
+		{"same key", current, entity.EntityStateEnabled, current, entity.EntityStateEnabled, "", false},
+		{"same key in another PEM form", current, entity.EntityStateEnabled,
+			entity.OrganizationKey{PublicKey: "-----BEGIN RSA PUBLIC KEY-----\nCCCC\n-----END RSA PUBLIC KEY-----\n", Fingerprint: "f1"},
+			entity.EntityStateEnabled, "", false},
+		{"certificate for the same key", current, entity.EntityStateEnabled,
+			entity.OrganizationKey{PublicKey: bare, Fingerprint: "f1", Certificate: cert},
+			entity.EntityStateEnabled, "", false},
+		{"new key", current, entity.EntityStateEnabled,
+			entity.OrganizationKey{PublicKey: bare, Fingerprint: "f2"},
+			entity.EntityStateEnabled, entity.RevocationReasonSuperseded, true},
+		{"stored key without fingerprint, same text", entity.OrganizationKey{PublicKey: bare}, entity.EntityStateEnabled,
+			current, entity.EntityStateEnabled, "", false},
+		{"stored key without fingerprint, other text", entity.OrganizationKey{PublicKey: cert}, entity.EntityStateEnabled,
+			current, entity.EntityStateEnabled, entity.RevocationReasonSuperseded, true},
+		{"disabled", current, entity.EntityStateEnabled, current, entity.EntityStateDisabled, entity.RevocationReasonCessationOfOperation, true},
+		{"enabled again", current, entity.EntityStateDisabled, current, entity.EntityStateEnabled, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := &entity.Organization{OrganizationKey: tt.stored, State: tt.state}
+			reason, revoke := KeyRevocationReason(item, tt.key, tt.newSt)
+			if reason != tt.reason || revoke != tt.revoke {
+				t.Errorf("KeyRevocationReason = %q, %v, want %q, %v", reason, revoke, tt.reason, tt.revoke)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	sqlKeyChallengeAdd  = `INSERT INTO tbl_key_challenge(nonce, key_hash, expire_ts, create_ts) VALUES($1, $2, $3, $4) RETURNING id`
+	sqlKeyChallengeById = `SELECT id, nonce, key_hash, expire_ts, used_ts, create_ts FROM tbl_key_challenge WHERE id=$1`
+	sqlKeyChallengeUse  = `UPDATE tbl_key_challenge SET used_ts=$2 WHERE id=$1 AND used_ts IS NULL`
+)
+
+func (d *PgAccess) KeyChallengeAdd(ctx context.Context, pTx pgx.Tx, nonce, keyHash string, expireTs time.Time) (item *entity.KeyChallenge, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.KeyChallengeAdd", tracing.Statement("sqlKeyChallengeAdd"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.KeyChallengeAdd",
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.KeyChallenge{
+			Nonce:    nonce,
+			KeyHash:  keyHash,
+			ExpireTs: expireTs.UTC().Round(time.Microsecond),
+			CreateTs: time.Now().UTC().Round(time.Microsecond),
+		}
+		// sqlKeyChallengeAdd  = `INSERT INTO tbl_key_challenge(nonce, key_hash, expire_ts, create_ts) VALUES($1, $2, $3, $4) RETURNING id`
+		row := tx.QueryRow(ctx, sqlKeyChallengeAdd, item.Nonce, item.KeyHash, item.ExpireTs, item.CreateTs)
+		err = row.Scan(&item.Id)
+		if err != nil {
+			eMsg := "error in sqlKeyChallengeAdd"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInTx"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+func (d *PgAccess) KeyChallengeById(ctx context.Context, id int) (item *entity.KeyChallenge, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.KeyChallengeById", tracing.Statement("sqlKeyChallengeById"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.KeyChallengeById",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.KeyChallenge{}
+		// sqlKeyChallengeById = `SELECT id, nonce, key_hash, expire_ts, used_ts, create_ts FROM tbl_key_challenge WHERE id=$1`
+		row := conn.QueryRow(ctx, sqlKeyChallengeById, id)
+		err = row.Scan(&item.Id, &item.Nonce, &item.KeyHash, &item.ExpireTs, &item.UsedTs, &item.CreateTs)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				err = nil
+				item = nil
+				return
+			}
+			eMsg := "error in sqlKeyChallengeById"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+// KeyChallengeUse marks challenge as used, returns ErrNoRowsAffected if it was used already
+func (d *PgAccess) KeyChallengeUse(ctx context.Context, pTx pgx.Tx, item *entity.KeyChallenge) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.KeyChallengeUse", tracing.Statement("sqlKeyChallengeUse"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.KeyChallengeUse",
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		now := time.Now().UTC().Round(time.Microsecond)
+		// sqlKeyChallengeUse  = `UPDATE tbl_key_challenge SET used_ts=$2 WHERE id=$1 AND used_ts IS NULL`
+		var cmdTag pgconn.CommandTag
+		cmdTag, err = tx.Exec(ctx, sqlKeyChallengeUse, item.Id, now)
+		if err != nil {
+			eMsg := "error in sqlKeyChallengeUse"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		if cmdTag.RowsAffected() == 0 {
+			eMsg := "challenge is already used"
+			clog.Warn(eMsg)
+			rollback = true
+			err = errors.Wrap(ErrNoRowsAffected, eMsg)
+			return
+		}
+		item.UsedTs = &now
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInTx"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
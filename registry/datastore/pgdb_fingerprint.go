@@ -0,0 +1,138 @@
+package datastore
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	sqlOrganizationByKeyFingerprint  = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE key_fingerprint=$1 ORDER BY state=$2 DESC, update_ts DESC LIMIT 1`
+	sqlOrganizationNoFingerprintList = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE key_fingerprint='' ORDER BY id ASC`
+	sqlOrganizationFingerprintSet    = `UPDATE tbl_organization SET key_fingerprint=$3 WHERE id=$1 AND public_key=$2 AND key_fingerprint=''`
+)
+
+// OrganizationByKeyFingerprint returns organization holding key with fingerprint in any state. Fingerprints are unique
+// only among enabled organizations, so the enabled one is preferred, then the most recently updated one.
+func (d *PgAccess) OrganizationByKeyFingerprint(ctx context.Context, fingerprint string) (item *entity.Organization, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationByKeyFingerprint", tracing.Statement("sqlOrganizationByKeyFingerprint"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationByKeyFingerprint",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.Organization{}
+		// sqlOrganizationByKeyFingerprint = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE key_fingerprint=$1 ORDER BY state=$2 DESC, update_ts DESC LIMIT 1`
+		row := conn.QueryRow(ctx, sqlOrganizationByKeyFingerprint, fingerprint, entity.EntityStateEnabled)
+		err = row.Scan(&item.Id, &item.Name, &item.Label, &item.Type, &item.Url, &item.PublicKey, &item.Algorithm, &item.Fingerprint, &item.Certificate, &item.CertExpireTs, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				err = nil
+				item = nil
+				return
+			}
+			eMsg := "error in sqlOrganizationByKeyFingerprint"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+// OrganizationNoFingerprintList returns organizations in any state stored before key fingerprints were computed
+func (d *PgAccess) OrganizationNoFingerprintList(ctx context.Context) (items []*entity.Organization, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationNoFingerprintList", tracing.Statement("sqlOrganizationNoFingerprintList"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationNoFingerprintList",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				items = nil
+			}
+		}()
+		items = make([]*entity.Organization, 0)
+		// sqlOrganizationNoFingerprintList = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE key_fingerprint='' ORDER BY id ASC`
+		rows, err := conn.Query(ctx, sqlOrganizationNoFingerprintList)
+		if err != nil {
+			eMsg := "error in sqlOrganizationNoFingerprintList"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		for rows.Next() {
+			item := &entity.Organization{}
+			err = rows.Scan(&item.Id, &item.Name, &item.Label, &item.Type, &item.Url, &item.PublicKey, &item.Algorithm, &item.Fingerprint, &item.Certificate, &item.CertExpireTs, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version)
+			if err != nil {
+				eMsg := "error in rows.Scan"
+				clog.WithError(err).Error(eMsg)
+				err = errors.Wrap(err, eMsg)
+				return
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+// OrganizationFingerprintSet stores fingerprint computed for the current key of item. Version is kept, as fingerprint
+// is derived from the key, ErrNoRowsAffected is returned if the key changed meanwhile.
+func (d *PgAccess) OrganizationFingerprintSet(ctx context.Context, pTx pgx.Tx, item *entity.Organization, fingerprint string) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationFingerprintSet", tracing.Statement("sqlOrganizationFingerprintSet"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationFingerprintSet",
+		"id":     item.Id,
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		// sqlOrganizationFingerprintSet    = `UPDATE tbl_organization SET key_fingerprint=$3 WHERE id=$1 AND public_key=$2 AND key_fingerprint=''`
+		var cmdTag pgconn.CommandTag
+		cmdTag, err = tx.Exec(ctx, sqlOrganizationFingerprintSet, item.Id, item.PublicKey, fingerprint)
+		if err != nil {
+			eMsg := "error in sqlOrganizationFingerprintSet"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		if cmdTag.RowsAffected() == 0 {
+			eMsg := "organization key changed"
+			clog.Warn(eMsg)
+			rollback = true
+			err = errors.Wrap(ErrNoRowsAffected, eMsg)
+			return
+		}
+		item.Fingerprint = fingerprint
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInTx"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
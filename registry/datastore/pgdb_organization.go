@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgconn"
@@ -11,35 +12,40 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
 )
 
 const (
-	sqlOrganizationAdd    = `INSERT INTO tbl_organization(name, label, type, url, public_key, state, create_ts, update_ts, version) VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
-	sqlOrganizationUpdate = `UPDATE tbl_organization SET name=$3, label=$4, type=$5, url=$6, public_key=$7, state=$8, update_ts=$9, version=$10 WHERE id=$1 AND version=$2`
-	sqlOrganizationById   = `SELECT id, name, label, type, url, public_key, state, create_ts, update_ts, version FROM tbl_organization WHERE id=$1 AND state!=$2`
-	sqlOrganizationByList = `SELECT id, name, label, type, url, public_key, state, create_ts, update_ts, version FROM tbl_organization WHERE state!=$1 ORDER BY id ASC`
+	sqlOrganizationAdd    = `INSERT INTO tbl_organization(name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`
+	sqlOrganizationUpdate = `UPDATE tbl_organization SET name=$3, label=$4, type=$5, url=$6, public_key=$7, key_algorithm=$8, key_fingerprint=$9, certificate=$10, cert_expire_ts=$11, state=$12, update_ts=$13, version=$14 WHERE id=$1 AND version=$2`
+	sqlOrganizationById   = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE id=$1 AND state!=$2`
+	sqlOrganizationByName = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE name=$1 AND state=$2`
+	sqlOrganizationByList = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE state IN ($1, $2) ORDER BY id ASC`
 )
 
-func (d *PgAccess) organizationAddAtomic(ctx context.Context, pTx pgx.Tx, name, label string, dmsType entity.DMSType, url, publicKey string, state entity.EntityState) (item *entity.Organization, err error) {
-	clog := log.WithFields(log.Fields{
+func (d *PgAccess) organizationAddAtomic(ctx context.Context, pTx pgx.Tx, actor string, action entity.AuditAction, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey, state entity.EntityState) (item *entity.Organization, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.organizationAddAtomic", tracing.Statement("sqlOrganizationAdd"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
 		"method": "PgAccess.organizationAddAtomic",
 	})
 	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
 		now := time.Now().UTC().Round(time.Microsecond)
 		item = &entity.Organization{
-			Name:      name,
-			Label:     label,
-			Type:      dmsType,
-			Url:       url,
-			PublicKey: publicKey,
-			State:     state,
-			CreateTs:  now,
-			UpdateTs:  now,
-			Version:   0,
+			Name:            name,
+			Label:           label,
+			Type:            dmsType,
+			Url:             url,
+			OrganizationKey: key,
+			State:           state,
+			CreateTs:        now,
+			UpdateTs:        now,
+			Version:         0,
 		}
-		//	sqlOrganizationAdd    = `INSERT INTO tbl_organization(name, label, type, url, public_key, state, create_ts, update_ts, version) VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
-		var cmdTag pgconn.CommandTag
-		cmdTag, err = tx.Exec(ctx, sqlOrganizationAdd, item.Name, item.Label, item.Type, item.Url, item.PublicKey, item.State, item.CreateTs, item.UpdateTs, item.Version)
+		//	sqlOrganizationAdd    = `INSERT INTO tbl_organization(name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`
+		row := tx.QueryRow(ctx, sqlOrganizationAdd, item.Name, item.Label, item.Type, item.Url, item.PublicKey, item.Algorithm, item.Fingerprint, item.Certificate, item.CertExpireTs, item.State, item.CreateTs, item.UpdateTs, item.Version)
+		err = row.Scan(&item.Id)
 		if err != nil {
 			eMsg := "error in sqlOrganizationAdd"
 			clog.WithError(err).Error(eMsg)
@@ -47,11 +53,12 @@ func (d *PgAccess) organizationAddAtomic(ctx context.Context, pTx pgx.Tx, name,
 			err = errors.Wrap(err, eMsg)
 			return
 		}
-		if cmdTag.RowsAffected() == 0 {
-			eMsg := "no rows affected during update"
-			clog.Warn(eMsg)
+		err = d.auditLogAddAtomic(ctx, tx, actor, action, auditObjectOrganization, item.Id, fmt.Sprintf("name=%s state=%s", item.Name, item.State))
+		if err != nil {
+			eMsg := "error in d.auditLogAddAtomic"
+			clog.WithError(err).Error(eMsg)
 			rollback = true
-			err = errors.Wrap(ErrNoRowsAffected, eMsg)
+			err = errors.Wrap(err, eMsg)
 			return
 		}
 		return false, nil
@@ -62,16 +69,18 @@ func (d *PgAccess) organizationAddAtomic(ctx context.Context, pTx pgx.Tx, name,
 	}
 	return
 }
-func (d *PgAccess) organizationUpdateAtomic(ctx context.Context, pTx pgx.Tx, item *entity.Organization, name, label string, dmsType entity.DMSType, url, publicKey string, state entity.EntityState) (err error) {
-	clog := log.WithFields(log.Fields{
+func (d *PgAccess) organizationUpdateAtomic(ctx context.Context, pTx pgx.Tx, actor string, action entity.AuditAction, item *entity.Organization, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey, state entity.EntityState) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.organizationUpdateAtomic", tracing.Statement("sqlOrganizationUpdate"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
 		"method": "PgAccess.organizationUpdateAtomic",
 	})
 	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
 		now := time.Now().UTC().Round(time.Microsecond)
 		nv := newVersion(item.Version)
-		// sqlOrganizationUpdate = `UPDATE tbl_organization SET name=$3, label=$4, type=$5, url=$6, public_key=$7, state=$8, update_ts=$9, version=$10 WHERE id=$1 AND version=$2`
+		// sqlOrganizationUpdate = `UPDATE tbl_organization SET name=$3, label=$4, type=$5, url=$6, public_key=$7, key_algorithm=$8, key_fingerprint=$9, certificate=$10, cert_expire_ts=$11, state=$12, update_ts=$13, version=$14 WHERE id=$1 AND version=$2`
 		var cmdTag pgconn.CommandTag
-		cmdTag, err = tx.Exec(ctx, sqlOrganizationUpdate, item.Id, item.Version, name, label, dmsType, url, publicKey, state, now, nv)
+		cmdTag, err = tx.Exec(ctx, sqlOrganizationUpdate, item.Id, item.Version, name, label, dmsType, url, key.PublicKey, key.Algorithm, key.Fingerprint, key.Certificate, key.CertExpireTs, state, now, nv)
 		if err != nil {
 			eMsg := "error in sqlOrganizationUpdate"
 			clog.WithError(err).Error(eMsg)
@@ -86,11 +95,39 @@ func (d *PgAccess) organizationUpdateAtomic(ctx context.Context, pTx pgx.Tx, ite
 			err = errors.Wrap(ErrNoRowsAffected, eMsg)
 			return
 		}
+		err = d.auditLogAddAtomic(ctx, tx, actor, action, auditObjectOrganization, item.Id, fmt.Sprintf("name=%s state=%s", name, state))
+		if err != nil {
+			eMsg := "error in d.auditLogAddAtomic"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		if reason, revoke := KeyRevocationReason(item, key, state); revoke {
+			err = d.issuedCertificateRevokeByOrganizationAtomic(ctx, tx, actor, item.Id, reason, now)
+			if err != nil {
+				eMsg := "error in d.issuedCertificateRevokeByOrganizationAtomic"
+				clog.WithError(err).Error(eMsg)
+				rollback = true
+				err = errors.Wrap(err, eMsg)
+				return
+			}
+			if item.Fingerprint != "" {
+				err = d.revokedKeyAddAtomic(ctx, tx, item.Id, item.Fingerprint, reason, now)
+				if err != nil {
+					eMsg := "error in d.revokedKeyAddAtomic"
+					clog.WithError(err).Error(eMsg)
+					rollback = true
+					err = errors.Wrap(err, eMsg)
+					return
+				}
+			}
+		}
 		item.Name = name
 		item.Label = label
 		item.Type = dmsType
 		item.Url = url
-		item.PublicKey = publicKey
+		item.OrganizationKey = key
 		item.State = state
 		item.UpdateTs = now
 		item.Version = nv
@@ -102,8 +139,10 @@ func (d *PgAccess) organizationUpdateAtomic(ctx context.Context, pTx pgx.Tx, ite
 	}
 	return
 }
-func (d *PgAccess) OrganizationAdd(ctx context.Context, pTx pgx.Tx, name, label string, dmsType entity.DMSType, url, publicKey string) (item *entity.Organization, err error) {
-	clog := log.WithFields(log.Fields{
+func (d *PgAccess) OrganizationAdd(ctx context.Context, pTx pgx.Tx, actor string, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey) (item *entity.Organization, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationAdd")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
 		"method": "PgAccess.OrganizationAdd",
 	})
 	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
@@ -112,7 +151,7 @@ func (d *PgAccess) OrganizationAdd(ctx context.Context, pTx pgx.Tx, name, label
 				item = nil
 			}
 		}()
-		item, err = d.organizationAddAtomic(ctx, tx, name, label, dmsType, url, publicKey, entity.EntityStateEnabled)
+		item, err = d.organizationAddAtomic(ctx, tx, actor, entity.AuditActionOrganizationAdd, name, label, dmsType, url, key, entity.EntityStateEnabled)
 		if err != nil {
 			eMsg := "error in d.organizationAddAtomic"
 			clog.WithError(err).Error(eMsg)
@@ -124,12 +163,18 @@ func (d *PgAccess) OrganizationAdd(ctx context.Context, pTx pgx.Tx, name, label
 	})
 	return
 }
-func (d *PgAccess) OrganizationUpdate(ctx context.Context, pTx pgx.Tx, item *entity.Organization, name, label string, dmsType entity.DMSType, url, publicKey string) (err error) {
-	clog := log.WithFields(log.Fields{
+func (d *PgAccess) OrganizationUpdate(ctx context.Context, pTx pgx.Tx, actor string, item *entity.Organization, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationUpdate")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
 		"method": "PgAccess.OrganizationUpdate",
 	})
 	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
-		err = d.organizationUpdateAtomic(ctx, tx, item, name, label, dmsType, url, publicKey, item.State)
+		action := entity.AuditActionOrganizationUpdate
+		if !key.SameKey(item.OrganizationKey) {
+			action = entity.AuditActionOrganizationKeyChange
+		}
+		err = d.organizationUpdateAtomic(ctx, tx, actor, action, item, name, label, dmsType, url, key, item.State)
 		if err != nil {
 			eMsg := "error in d.organizationUpdateAtomic"
 			clog.WithError(err).Error(eMsg)
@@ -145,12 +190,14 @@ func (d *PgAccess) OrganizationUpdate(ctx context.Context, pTx pgx.Tx, item *ent
 	}
 	return
 }
-func (d *PgAccess) OrganizationChangeState(ctx context.Context, pTx pgx.Tx, item *entity.Organization, state entity.EntityState) (err error) {
-	clog := log.WithFields(log.Fields{
+func (d *PgAccess) OrganizationChangeState(ctx context.Context, pTx pgx.Tx, actor string, item *entity.Organization, state entity.EntityState) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationChangeState")
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
 		"method": "PgAccess.OrganizationChangeState",
 	})
 	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
-		err = d.organizationUpdateAtomic(ctx, tx, item, item.Name, item.Label, item.Type, item.Url, item.PublicKey, state)
+		err = d.organizationUpdateAtomic(ctx, tx, actor, entity.AuditActionOrganizationChangeState, item, item.Name, item.Label, item.Type, item.Url, item.OrganizationKey, state)
 		if err != nil {
 			eMsg := "error in d.organizationUpdateAtomic"
 			clog.WithError(err).Error(eMsg)
@@ -167,7 +214,9 @@ func (d *PgAccess) OrganizationChangeState(ctx context.Context, pTx pgx.Tx, item
 	return
 }
 func (d *PgAccess) OrganizationById(ctx context.Context, id int) (item *entity.Organization, err error) {
-	clog := log.WithFields(log.Fields{
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationById", tracing.Statement("sqlOrganizationById"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
 		"method": "PgAccess.OrganizationById",
 	})
 	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
@@ -177,9 +226,9 @@ func (d *PgAccess) OrganizationById(ctx context.Context, id int) (item *entity.O
 			}
 		}()
 		item = &entity.Organization{}
-		//sqlOrganizationById = `SELECT id, name, label, type, url, public_key, state, create_ts, update_ts, version FROM tbl_organization WHERE id=$1 AND state!=$2`
+		//sqlOrganizationById = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE id=$1 AND state!=$2`
 		row := conn.QueryRow(ctx, sqlOrganizationById, id, entity.EntityStateDeleted)
-		err = row.Scan(&item.Id, &item.Name, &item.Label, &item.Type, &item.Url, &item.PublicKey, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version)
+		err = row.Scan(&item.Id, &item.Name, &item.Label, &item.Type, &item.Url, &item.PublicKey, &item.Algorithm, &item.Fingerprint, &item.Certificate, &item.CertExpireTs, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version)
 		if err != nil {
 			if err == pgx.ErrNoRows {
 				err = nil
@@ -199,8 +248,49 @@ func (d *PgAccess) OrganizationById(ctx context.Context, id int) (item *entity.O
 	}
 	return
 }
+
+// OrganizationByName returns enabled organization with given name, names are unique only among enabled organizations
+func (d *PgAccess) OrganizationByName(ctx context.Context, name string) (item *entity.Organization, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationByName", tracing.Statement("sqlOrganizationByName"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationByName",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.Organization{}
+		//sqlOrganizationByName = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE name=$1 AND state=$2`
+		row := conn.QueryRow(ctx, sqlOrganizationByName, name, entity.EntityStateEnabled)
+		err = row.Scan(&item.Id, &item.Name, &item.Label, &item.Type, &item.Url, &item.PublicKey, &item.Algorithm, &item.Fingerprint, &item.Certificate, &item.CertExpireTs, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				err = nil
+				item = nil
+				return
+			}
+			eMsg := "error in sqlOrganizationByName"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+// OrganizationList returns enabled and disabled organizations, deleted and not approved ones are left out
 func (d *PgAccess) OrganizationList(ctx context.Context) (items []*entity.Organization, err error) {
-	clog := log.WithFields(log.Fields{
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationList", tracing.Statement("sqlOrganizationByList"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
 		"method": "PgAccess.OrganizationList",
 	})
 	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
@@ -210,8 +300,8 @@ func (d *PgAccess) OrganizationList(ctx context.Context) (items []*entity.Organi
 			}
 		}()
 		items = make([]*entity.Organization, 0)
-		//sqlOrganizationByList = `SELECT id, name, label, type, url, public_key, state, create_ts, update_ts, version FROM tbl_organization WHERE state!=$1 ORDER BY id ASC`
-		rows, err := conn.Query(ctx, sqlOrganizationByList, entity.EntityStateDeleted)
+		//sqlOrganizationByList = `SELECT id, name, label, type, url, public_key, key_algorithm, key_fingerprint, certificate, cert_expire_ts, state, create_ts, update_ts, version FROM tbl_organization WHERE state IN ($1, $2) ORDER BY id ASC`
+		rows, err := conn.Query(ctx, sqlOrganizationByList, entity.EntityStateEnabled, entity.EntityStateDisabled)
 		if err != nil {
 			eMsg := "error in sqlOrganizationByList"
 			clog.WithError(err).Error(eMsg)
@@ -220,7 +310,7 @@ func (d *PgAccess) OrganizationList(ctx context.Context) (items []*entity.Organi
 		}
 		for rows.Next() {
 			item := &entity.Organization{}
-			err = rows.Scan(&item.Id, &item.Name, &item.Label, &item.Type, &item.Url, &item.PublicKey, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version)
+			err = rows.Scan(&item.Id, &item.Name, &item.Label, &item.Type, &item.Url, &item.PublicKey, &item.Algorithm, &item.Fingerprint, &item.Certificate, &item.CertExpireTs, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version)
 			if err != nil {
 				eMsg := "error in rows.Scan"
 				clog.WithError(err).Error(eMsg)
@@ -0,0 +1,107 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	sqlOrganizationProbeSave = `INSERT INTO tbl_organization_probe(organization_id, url, status_code, latency_ms, error, cert_expire_ts, success_ts, probe_ts)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (organization_id) DO UPDATE SET url=EXCLUDED.url, status_code=EXCLUDED.status_code, latency_ms=EXCLUDED.latency_ms,
+error=EXCLUDED.error, cert_expire_ts=COALESCE(EXCLUDED.cert_expire_ts, tbl_organization_probe.cert_expire_ts),
+success_ts=COALESCE(EXCLUDED.success_ts, tbl_organization_probe.success_ts), probe_ts=EXCLUDED.probe_ts
+RETURNING cert_expire_ts, success_ts`
+	sqlOrganizationProbeList = `SELECT p.organization_id, o.name, p.url, p.status_code, p.latency_ms, p.error, p.cert_expire_ts, p.success_ts, p.probe_ts
+FROM tbl_organization_probe p JOIN tbl_organization o ON o.id=p.organization_id WHERE o.state=$1 ORDER BY p.organization_id`
+)
+
+// OrganizationProbeSave stores the latest probe of organization, last success and certificate expiry are kept
+// from earlier probes when item has none
+func (d *PgAccess) OrganizationProbeSave(ctx context.Context, pTx pgx.Tx, item *entity.OrganizationProbe) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationProbeSave", tracing.Statement("sqlOrganizationProbeSave"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method":          "PgAccess.OrganizationProbeSave",
+		"organization_id": item.OrganizationId,
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		item.ProbeTs = item.ProbeTs.UTC().Round(time.Microsecond)
+		// sqlOrganizationProbeSave = `INSERT INTO tbl_organization_probe(organization_id, url, status_code, latency_ms, error, cert_expire_ts, success_ts, probe_ts)
+		// VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		// ON CONFLICT (organization_id) DO UPDATE SET url=EXCLUDED.url, status_code=EXCLUDED.status_code, latency_ms=EXCLUDED.latency_ms,
+		// error=EXCLUDED.error, cert_expire_ts=COALESCE(EXCLUDED.cert_expire_ts, tbl_organization_probe.cert_expire_ts),
+		// success_ts=COALESCE(EXCLUDED.success_ts, tbl_organization_probe.success_ts), probe_ts=EXCLUDED.probe_ts
+		// RETURNING cert_expire_ts, success_ts`
+		row := tx.QueryRow(ctx, sqlOrganizationProbeSave, item.OrganizationId, item.Url, item.StatusCode, item.LatencyMs, item.Error,
+			item.CertExpireTs, item.SuccessTs, item.ProbeTs)
+		err = row.Scan(&item.CertExpireTs, &item.SuccessTs)
+		if err != nil {
+			eMsg := "error in sqlOrganizationProbeSave"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInTx"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+// OrganizationProbeList returns latest probes of enabled organizations
+func (d *PgAccess) OrganizationProbeList(ctx context.Context) (items []*entity.OrganizationProbe, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationProbeList", tracing.Statement("sqlOrganizationProbeList"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationProbeList",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				items = nil
+			}
+		}()
+		items = make([]*entity.OrganizationProbe, 0)
+		// sqlOrganizationProbeList = `SELECT p.organization_id, o.name, p.url, p.status_code, p.latency_ms, p.error, p.cert_expire_ts, p.success_ts, p.probe_ts
+		// FROM tbl_organization_probe p JOIN tbl_organization o ON o.id=p.organization_id WHERE o.state=$1 ORDER BY p.organization_id`
+		rows, err := conn.Query(ctx, sqlOrganizationProbeList, entity.EntityStateEnabled)
+		if err != nil {
+			eMsg := "error in sqlOrganizationProbeList"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			item := &entity.OrganizationProbe{}
+			err = rows.Scan(&item.OrganizationId, &item.OrganizationName, &item.Url, &item.StatusCode, &item.LatencyMs, &item.Error,
+				&item.CertExpireTs, &item.SuccessTs, &item.ProbeTs)
+			if err != nil {
+				eMsg := "error in rows.Scan"
+				clog.WithError(err).Error(eMsg)
+				err = errors.Wrap(err, eMsg)
+				return
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
@@ -0,0 +1,185 @@
+package datastore
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	sqlOrganizationRegistrationAdd    = `INSERT INTO tbl_organization_registration(organization_id, token_hash, reason) VALUES($1, $2, $3)`
+	sqlOrganizationRegistrationUpdate = `UPDATE tbl_organization_registration SET reason=$2 WHERE organization_id=$1`
+	sqlOrganizationRegistrationById   = `SELECT o.id, o.name, o.label, o.type, o.url, o.public_key, o.key_algorithm, o.key_fingerprint, o.certificate, o.cert_expire_ts, o.state, o.create_ts, o.update_ts, o.version, r.token_hash, r.reason FROM tbl_organization o JOIN tbl_organization_registration r ON r.organization_id=o.id WHERE o.id=$1`
+	sqlOrganizationRegistrationList   = `SELECT o.id, o.name, o.label, o.type, o.url, o.public_key, o.key_algorithm, o.key_fingerprint, o.certificate, o.cert_expire_ts, o.state, o.create_ts, o.update_ts, o.version, r.token_hash, r.reason FROM tbl_organization o JOIN tbl_organization_registration r ON r.organization_id=o.id WHERE o.state=$1 ORDER BY o.id ASC`
+)
+
+func scanOrganizationRegistration(row pgx.Row, item *entity.OrganizationRegistration) error {
+	return row.Scan(&item.Id, &item.Name, &item.Label, &item.Type, &item.Url, &item.PublicKey, &item.Algorithm, &item.Fingerprint, &item.Certificate, &item.CertExpireTs, &item.State, &item.CreateTs, &item.UpdateTs, &item.Version, &item.TokenHash, &item.Reason)
+}
+
+// OrganizationRegister adds PENDING organization, which is not listed until an operator approves it
+func (d *PgAccess) OrganizationRegister(ctx context.Context, pTx pgx.Tx, actor string, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey, tokenHash string) (item *entity.OrganizationRegistration, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationRegister", tracing.Statement("sqlOrganizationRegistrationAdd"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationRegister",
+	})
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		var organization *entity.Organization
+		organization, err = d.organizationAddAtomic(ctx, tx, actor, entity.AuditActionOrganizationRegister, name, label, dmsType, url, key, entity.EntityStatePending)
+		if err != nil {
+			eMsg := "error in d.organizationAddAtomic"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		item = &entity.OrganizationRegistration{
+			Organization: *organization,
+			TokenHash:    tokenHash,
+		}
+		// sqlOrganizationRegistrationAdd    = `INSERT INTO tbl_organization_registration(organization_id, token_hash, reason) VALUES($1, $2, $3)`
+		_, err = tx.Exec(ctx, sqlOrganizationRegistrationAdd, item.Id, item.TokenHash, item.Reason)
+		if err != nil {
+			eMsg := "error in sqlOrganizationRegistrationAdd"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInTx"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+// OrganizationRegistrationReview moves PENDING organization to ENABLED on approval or REJECTED, keeping operator's reason
+func (d *PgAccess) OrganizationRegistrationReview(ctx context.Context, pTx pgx.Tx, actor string, item *entity.OrganizationRegistration, state entity.EntityState, reason string) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationRegistrationReview", tracing.Statement("sqlOrganizationRegistrationUpdate"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationRegistrationReview",
+	})
+	action := entity.AuditActionOrganizationApprove
+	if state == entity.EntityStateRejected {
+		action = entity.AuditActionOrganizationReject
+	}
+	err = d.runInTx(ctx, pTx, clog, func(tx pgx.Tx) (rollback bool, err error) {
+		err = d.organizationUpdateAtomic(ctx, tx, actor, action, &item.Organization, item.Name, item.Label, item.Type, item.Url, item.OrganizationKey, state)
+		if err != nil {
+			eMsg := "error in d.organizationUpdateAtomic"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		// sqlOrganizationRegistrationUpdate = `UPDATE tbl_organization_registration SET reason=$2 WHERE organization_id=$1`
+		_, err = tx.Exec(ctx, sqlOrganizationRegistrationUpdate, item.Id, reason)
+		if err != nil {
+			eMsg := "error in sqlOrganizationRegistrationUpdate"
+			clog.WithError(err).Error(eMsg)
+			rollback = true
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		item.Reason = reason
+		return false, nil
+	})
+	if err != nil {
+		eMsg := "error in d.runInTx()"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+func (d *PgAccess) OrganizationRegistrationById(ctx context.Context, id int) (item *entity.OrganizationRegistration, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationRegistrationById", tracing.Statement("sqlOrganizationRegistrationById"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationRegistrationById",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.OrganizationRegistration{}
+		// sqlOrganizationRegistrationById   = `SELECT o.id, o.name, o.label, o.type, o.url, o.public_key, o.key_algorithm, o.key_fingerprint, o.certificate, o.cert_expire_ts, o.state, o.create_ts, o.update_ts, o.version, r.token_hash, r.reason FROM tbl_organization o JOIN tbl_organization_registration r ON r.organization_id=o.id WHERE o.id=$1`
+		err = scanOrganizationRegistration(conn.QueryRow(ctx, sqlOrganizationRegistrationById, id), item)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				err = nil
+				item = nil
+				return
+			}
+			eMsg := "error in sqlOrganizationRegistrationById"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
+
+func (d *PgAccess) OrganizationRegistrationList(ctx context.Context, state entity.EntityState) (items []*entity.OrganizationRegistration, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.OrganizationRegistrationList", tracing.Statement("sqlOrganizationRegistrationList"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.OrganizationRegistrationList",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				items = nil
+			}
+		}()
+		items = make([]*entity.OrganizationRegistration, 0)
+		// sqlOrganizationRegistrationList   = `SELECT o.id, o.name, o.label, o.type, o.url, o.public_key, o.key_algorithm, o.key_fingerprint, o.certificate, o.cert_expire_ts, o.state, o.create_ts, o.update_ts, o.version, r.token_hash, r.reason FROM tbl_organization o JOIN tbl_organization_registration r ON r.organization_id=o.id WHERE o.state=$1 ORDER BY o.id ASC`
+		rows, err := conn.Query(ctx, sqlOrganizationRegistrationList, state)
+		if err != nil {
+			eMsg := "error in sqlOrganizationRegistrationList"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			item := &entity.OrganizationRegistration{}
+			err = scanOrganizationRegistration(rows, item)
+			if err != nil {
+				eMsg := "error in rows.Scan"
+				clog.WithError(err).Error(eMsg)
+				err = errors.Wrap(err, eMsg)
+				return
+			}
+			items = append(items, item)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
@@ -0,0 +1,74 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/tracing"
+)
+
+const (
+	sqlRevokedKeyAdd           = `INSERT INTO tbl_revoked_key(organization_id, key_fingerprint, revocation_reason, revoked_ts) VALUES($1, $2, $3, $4)`
+	sqlRevokedKeyByFingerprint = `SELECT id, organization_id, key_fingerprint, revocation_reason, revoked_ts FROM tbl_revoked_key WHERE organization_id=$1 AND key_fingerprint=$2 ORDER BY id DESC LIMIT 1`
+)
+
+// revokedKeyAddAtomic records within tx that organization stopped using key with fingerprint
+func (d *PgAccess) revokedKeyAddAtomic(ctx context.Context, tx pgx.Tx, organizationId int, fingerprint string, reason entity.RevocationReason, now time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.revokedKeyAddAtomic", tracing.Statement("sqlRevokedKeyAdd"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.revokedKeyAddAtomic",
+	})
+	// sqlRevokedKeyAdd           = `INSERT INTO tbl_revoked_key(organization_id, key_fingerprint, revocation_reason, revoked_ts) VALUES($1, $2, $3, $4)`
+	_, err = tx.Exec(ctx, sqlRevokedKeyAdd, organizationId, fingerprint, reason, now)
+	if err != nil {
+		eMsg := "error in sqlRevokedKeyAdd"
+		clog.WithError(err).Error(eMsg)
+		return errors.Wrap(err, eMsg)
+	}
+	return nil
+}
+
+// RevokedKeyByFingerprint returns the latest revocation of key with fingerprint for organization in any state
+func (d *PgAccess) RevokedKeyByFingerprint(ctx context.Context, organizationId int, fingerprint string) (item *entity.RevokedKey, err error) {
+	ctx, span := tracing.Start(ctx, "PgAccess.RevokedKeyByFingerprint", tracing.Statement("sqlRevokedKeyByFingerprint"))
+	defer func() { tracing.End(span, err) }()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "PgAccess.RevokedKeyByFingerprint",
+	})
+	err = d.runQuery(ctx, clog, func(conn *pgxpool.Conn) (err error) {
+		defer func() {
+			if err != nil {
+				item = nil
+			}
+		}()
+		item = &entity.RevokedKey{}
+		// sqlRevokedKeyByFingerprint = `SELECT id, organization_id, key_fingerprint, revocation_reason, revoked_ts FROM tbl_revoked_key WHERE organization_id=$1 AND key_fingerprint=$2 ORDER BY id DESC LIMIT 1`
+		row := conn.QueryRow(ctx, sqlRevokedKeyByFingerprint, organizationId, fingerprint)
+		err = row.Scan(&item.Id, &item.OrganizationId, &item.KeyFingerprint, &item.RevocationReason, &item.RevokedTs)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				err = nil
+				item = nil
+				return
+			}
+			eMsg := "error in sqlRevokedKeyByFingerprint"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+		return nil
+	})
+	if err != nil {
+		eMsg := "error in pgxAccess.runInQuery"
+		clog.WithError(err).Error(eMsg)
+	}
+	return
+}
@@ -0,0 +1,212 @@
+package datastore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+var ErrReadOnly = errors.New("datastore is read-only")
+
+var _ Access = (*ReadOnlyAccess)(nil)
+
+// ReadOnlyAccess serves organizations replaced as a whole from another registry, e.g. from a snapshot bundle.
+// Writes fail with ErrReadOnly; registrations, certificates, probes and admins are never found, so admin routes
+// deny every token.
+type ReadOnlyAccess struct {
+	mu            sync.RWMutex
+	organizations []*entity.Organization
+}
+
+func NewReadOnlyAccess() *ReadOnlyAccess {
+	return &ReadOnlyAccess{organizations: make([]*entity.Organization, 0)}
+}
+
+// Replace swaps served organizations for items at once, readers see either all old or all new ones
+func (d *ReadOnlyAccess) Replace(items []*entity.Organization) {
+	sorted := make([]*entity.Organization, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.organizations = sorted
+}
+
+// find returns copy of first organization match accepts, callers may change it freely
+func (d *ReadOnlyAccess) find(match func(o *entity.Organization) bool) (item *entity.Organization) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, o := range d.organizations {
+		if match(o) {
+			c := *o
+			return &c
+		}
+	}
+	return nil
+}
+
+func (d *ReadOnlyAccess) filter(match func(o *entity.Organization) bool) (items []*entity.Organization) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	items = make([]*entity.Organization, 0)
+	for _, o := range d.organizations {
+		if match(o) {
+			c := *o
+			items = append(items, &c)
+		}
+	}
+	return
+}
+
+func (d *ReadOnlyAccess) Ping(ctx context.Context) (err error) {
+	return nil
+}
+
+func (d *ReadOnlyAccess) Close() {}
+
+// RunInTx runs f right away, writes it makes fail with ErrReadOnly anyway
+func (d *ReadOnlyAccess) RunInTx(ctx context.Context, f func(tx pgx.Tx) error) (err error) {
+	return f(nil)
+}
+
+func (d *ReadOnlyAccess) OrganizationAdd(ctx context.Context, pTx pgx.Tx, actor string, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey) (item *entity.Organization, err error) {
+	return nil, ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) OrganizationUpdate(ctx context.Context, pTx pgx.Tx, actor string, item *entity.Organization, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey) (err error) {
+	return ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) OrganizationChangeState(ctx context.Context, pTx pgx.Tx, actor string, item *entity.Organization, state entity.EntityState) (err error) {
+	return ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) OrganizationById(ctx context.Context, id int) (item *entity.Organization, err error) {
+	return d.find(func(o *entity.Organization) bool {
+		return o.Id == id && o.State != entity.EntityStateDeleted
+	}), nil
+}
+
+func (d *ReadOnlyAccess) OrganizationByName(ctx context.Context, name string) (item *entity.Organization, err error) {
+	return d.find(func(o *entity.Organization) bool {
+		return o.Name == name && o.State == entity.EntityStateEnabled
+	}), nil
+}
+
+// OrganizationList returns enabled and disabled organizations, as PgAccess does
+func (d *ReadOnlyAccess) OrganizationList(ctx context.Context) (items []*entity.Organization, err error) {
+	return d.filter(func(o *entity.Organization) bool {
+		return o.State == entity.EntityStateEnabled || o.State == entity.EntityStateDisabled
+	}), nil
+}
+
+// OrganizationByKeyFingerprint prefers enabled organization, then the one updated last, as PgAccess does
+func (d *ReadOnlyAccess) OrganizationByKeyFingerprint(ctx context.Context, fingerprint string) (item *entity.Organization, err error) {
+	for _, o := range d.filter(func(o *entity.Organization) bool { return o.Fingerprint == fingerprint }) {
+		switch {
+		case item == nil,
+			o.State == entity.EntityStateEnabled && item.State != entity.EntityStateEnabled,
+			(o.State == entity.EntityStateEnabled) == (item.State == entity.EntityStateEnabled) && o.UpdateTs.After(item.UpdateTs):
+			item = o
+		}
+	}
+	return
+}
+
+func (d *ReadOnlyAccess) OrganizationNoFingerprintList(ctx context.Context) (items []*entity.Organization, err error) {
+	return d.filter(func(o *entity.Organization) bool { return o.Fingerprint == "" }), nil
+}
+
+func (d *ReadOnlyAccess) OrganizationFingerprintSet(ctx context.Context, pTx pgx.Tx, item *entity.Organization, fingerprint string) (err error) {
+	return ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) OrganizationRegister(ctx context.Context, pTx pgx.Tx, actor string, name, label string, dmsType entity.DMSType, url string, key entity.OrganizationKey, tokenHash string) (item *entity.OrganizationRegistration, err error) {
+	return nil, ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) OrganizationRegistrationReview(ctx context.Context, pTx pgx.Tx, actor string, item *entity.OrganizationRegistration, state entity.EntityState, reason string) (err error) {
+	return ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) OrganizationRegistrationById(ctx context.Context, id int) (item *entity.OrganizationRegistration, err error) {
+	return nil, nil
+}
+
+func (d *ReadOnlyAccess) OrganizationRegistrationList(ctx context.Context, state entity.EntityState) (items []*entity.OrganizationRegistration, err error) {
+	return make([]*entity.OrganizationRegistration, 0), nil
+}
+
+func (d *ReadOnlyAccess) KeyChallengeAdd(ctx context.Context, pTx pgx.Tx, nonce, keyHash string, expireTs time.Time) (item *entity.KeyChallenge, err error) {
+	return nil, ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) KeyChallengeById(ctx context.Context, id int) (item *entity.KeyChallenge, err error) {
+	return nil, nil
+}
+
+func (d *ReadOnlyAccess) KeyChallengeUse(ctx context.Context, pTx pgx.Tx, item *entity.KeyChallenge) (err error) {
+	return ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) IssuedCertificateAdd(ctx context.Context, pTx pgx.Tx, actor string, item *entity.IssuedCertificate) (err error) {
+	return ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) IssuedCertificateBySerial(ctx context.Context, serial string) (item *entity.IssuedCertificate, err error) {
+	return nil, nil
+}
+
+func (d *ReadOnlyAccess) IssuedCertificateList(ctx context.Context, organizationId int) (items []*entity.IssuedCertificate, err error) {
+	return make([]*entity.IssuedCertificate, 0), nil
+}
+
+func (d *ReadOnlyAccess) IssuedCertificateRevokedList(ctx context.Context) (items []*entity.IssuedCertificate, err error) {
+	return make([]*entity.IssuedCertificate, 0), nil
+}
+
+func (d *ReadOnlyAccess) IssuedCertificateRevoke(ctx context.Context, pTx pgx.Tx, actor string, item *entity.IssuedCertificate, reason entity.RevocationReason) (err error) {
+	return ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) RevokedKeyByFingerprint(ctx context.Context, organizationId int, fingerprint string) (item *entity.RevokedKey, err error) {
+	return nil, nil
+}
+
+func (d *ReadOnlyAccess) OrganizationProbeSave(ctx context.Context, pTx pgx.Tx, item *entity.OrganizationProbe) (err error) {
+	return ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) OrganizationProbeList(ctx context.Context) (items []*entity.OrganizationProbe, err error) {
+	return make([]*entity.OrganizationProbe, 0), nil
+}
+
+func (d *ReadOnlyAccess) AdminAdd(ctx context.Context, pTx pgx.Tx, actor string, username, passwordHash string, role entity.AdminRole) (item *entity.Admin, err error) {
+	return nil, ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) AdminById(ctx context.Context, id int) (item *entity.Admin, err error) {
+	return nil, nil
+}
+
+func (d *ReadOnlyAccess) AdminByUsername(ctx context.Context, username string) (item *entity.Admin, err error) {
+	return nil, nil
+}
+
+func (d *ReadOnlyAccess) AdminTokenAdd(ctx context.Context, pTx pgx.Tx, actor string, admin *entity.Admin, tokenHash, label string, expireTs time.Time) (item *entity.AdminToken, err error) {
+	return nil, ErrReadOnly
+}
+
+func (d *ReadOnlyAccess) AdminTokenByHash(ctx context.Context, tokenHash string) (item *entity.AdminToken, err error) {
+	return nil, nil
+}
+
+func (d *ReadOnlyAccess) AuditLogList(ctx context.Context, limit int) (items []*entity.AuditLog, err error) {
+	return make([]*entity.AuditLog, 0), nil
+}
@@ -0,0 +1,27 @@
+-- doc-registry-go migration of databases created before key fingerprints
+--
+-- Part 1 runs before the upgraded daemon starts. Fingerprints are computed by the daemon from the stored keys when it
+-- starts, rows it has not reached yet keep '' and are left out of the unique index.
+
+BEGIN;
+
+ALTER TABLE tbl_organization ADD COLUMN key_fingerprint VARCHAR(64);
+
+UPDATE tbl_organization SET key_fingerprint = '' WHERE key_fingerprint IS NULL;
+
+ALTER TABLE tbl_organization ALTER COLUMN key_fingerprint SET DEFAULT '';
+ALTER TABLE tbl_organization ALTER COLUMN key_fingerprint SET NOT NULL;
+
+CREATE UNIQUE INDEX uq_organization_key_fingerprint ON tbl_organization (key_fingerprint)
+    WHERE state = 'ENABLED'::entity_state_t AND key_fingerprint <> '';
+
+CREATE INDEX ix_organization_key_fingerprint ON tbl_organization (key_fingerprint);
+
+COMMIT;
+
+-- Part 2 is run by hand after the upgraded daemon has started once. Rows this lists hold keys the daemon could not
+-- parse and logged, fix them first:
+--   SELECT id, name, state FROM tbl_organization WHERE key_fingerprint = '';
+-- Until then the old index keeps identical keys out.
+--
+--   DROP INDEX uq_organization_public_key;
@@ -1,21 +1,27 @@
 -- doc-registry-go database initialize script
 
-CREATE TYPE entity_state_t AS ENUM ('ENABLED', 'DISABLED', 'DELETED');
+CREATE TYPE entity_state_t AS ENUM ('ENABLED', 'DISABLED', 'DELETED', 'PENDING', 'REJECTED');
 
 CREATE TYPE dms_type_t AS ENUM ('SRD', 'Netije', 'eResminama');
 
 CREATE TABLE tbl_organization
 (
-    id         serial PRIMARY KEY,
-    name       VARCHAR(300)                NOT NULL,
-    label      VARCHAR(512)                NOT NULL,
-    type       dms_type_t                  NOT NULL,
-    url        VARCHAR(900)                NOT NULL,
-    public_key TEXT                        NOT NULL,
-    state      entity_state_t              NOT NULL,
-    create_ts  TIMESTAMP WITHOUT TIME ZONE NOT NULL,
-    update_ts  TIMESTAMP WITHOUT TIME ZONE NOT NULL,
-    version    INT                         NOT NULL
+    id              serial PRIMARY KEY,
+    name            VARCHAR(300)                NOT NULL,
+    label           VARCHAR(512)                NOT NULL,
+    type            dms_type_t                  NOT NULL,
+    url             VARCHAR(900)                NOT NULL,
+    public_key      TEXT                        NOT NULL,
+    key_algorithm   VARCHAR(30)                 NOT NULL DEFAULT 'RSA_PKCS1_SHA256',
+    -- hex SHA-256 of public_key in PKIX DER encoding, filled in by the daemon for rows stored before it
+    key_fingerprint VARCHAR(64)                 NOT NULL DEFAULT '',
+    -- PEM chain, leaf first, when public_key was taken from X.509 certificate
+    certificate     TEXT                        NOT NULL DEFAULT '',
+    cert_expire_ts  TIMESTAMP WITHOUT TIME ZONE,
+    state           entity_state_t              NOT NULL,
+    create_ts       TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    update_ts       TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    version         INT                         NOT NULL
 );
 
 CREATE UNIQUE INDEX uq_organization_name ON tbl_organization (name)
@@ -24,5 +30,113 @@ CREATE UNIQUE INDEX uq_organization_name ON tbl_organization (name)
 CREATE UNIQUE INDEX uq_organization_url ON tbl_organization (url)
     WHERE state = 'ENABLED'::entity_state_t;
 
-CREATE UNIQUE INDEX uq_organization_public_key ON tbl_organization (public_key)
-    WHERE state = 'ENABLED'::entity_state_t;
+-- rows not reached by the fingerprint backfill yet hold '' and must not clash
+CREATE UNIQUE INDEX uq_organization_key_fingerprint ON tbl_organization (key_fingerprint)
+    WHERE state = 'ENABLED'::entity_state_t AND key_fingerprint <> '';
+
+CREATE INDEX ix_organization_key_fingerprint ON tbl_organization (key_fingerprint);
+
+-- self-registered organizations stay in tbl_organization as PENDING until approved (ENABLED) or REJECTED
+CREATE TABLE tbl_organization_registration
+(
+    organization_id INT PRIMARY KEY REFERENCES tbl_organization (id),
+    token_hash      VARCHAR(64) NOT NULL,
+    reason          TEXT        NOT NULL
+);
+
+-- nonce signed by the holder of a private key before its public key is accepted, single use
+CREATE TABLE tbl_key_challenge
+(
+    id        serial PRIMARY KEY,
+    nonce     VARCHAR(100)                NOT NULL,
+    key_hash  VARCHAR(64)                 NOT NULL,
+    expire_ts TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    used_ts   TIMESTAMP WITHOUT TIME ZONE,
+    create_ts TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- DMS node certificates issued by the registry CA, revoked ones are listed in CRL until they expire
+CREATE TABLE tbl_issued_certificate
+(
+    id                serial PRIMARY KEY,
+    organization_id   INT                         NOT NULL REFERENCES tbl_organization (id),
+    serial            VARCHAR(40)                 NOT NULL,
+    cert_usage        VARCHAR(10)                 NOT NULL,
+    key_fingerprint   VARCHAR(64)                 NOT NULL,
+    certificate       TEXT                        NOT NULL,
+    not_before        TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    not_after         TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    revoked_ts        TIMESTAMP WITHOUT TIME ZONE,
+    revocation_reason VARCHAR(30)                 NOT NULL,
+    create_ts         TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX uq_issued_certificate_serial ON tbl_issued_certificate (serial);
+
+CREATE INDEX ix_issued_certificate_organization ON tbl_issued_certificate (organization_id);
+
+-- keys organizations stopped using, key status queries answer REVOKED for them
+CREATE TABLE tbl_revoked_key
+(
+    id                serial PRIMARY KEY,
+    organization_id   INT                         NOT NULL REFERENCES tbl_organization (id),
+    key_fingerprint   VARCHAR(64)                 NOT NULL,
+    revocation_reason VARCHAR(30)                 NOT NULL,
+    revoked_ts        TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE INDEX ix_revoked_key_organization_fingerprint ON tbl_revoked_key (organization_id, key_fingerprint);
+
+-- latest reachability check of each organization url, written by the prober of every daemon
+CREATE TABLE tbl_organization_probe
+(
+    organization_id INT PRIMARY KEY REFERENCES tbl_organization (id),
+    url             VARCHAR(900)                NOT NULL,
+    status_code     INT                         NOT NULL,
+    latency_ms      INT                         NOT NULL,
+    error           TEXT                        NOT NULL,
+    cert_expire_ts  TIMESTAMP WITHOUT TIME ZONE,
+    success_ts      TIMESTAMP WITHOUT TIME ZONE,
+    probe_ts        TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE TYPE admin_role_t AS ENUM ('AUDITOR', 'OPERATOR', 'SECURITY_OFFICER');
+
+CREATE TABLE tbl_admin
+(
+    id            serial PRIMARY KEY,
+    username      VARCHAR(100)                NOT NULL,
+    password_hash VARCHAR(100)                NOT NULL,
+    role          admin_role_t                NOT NULL,
+    state         entity_state_t              NOT NULL,
+    create_ts     TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    update_ts     TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    version       INT                         NOT NULL
+);
+
+CREATE UNIQUE INDEX uq_admin_username ON tbl_admin (username)
+    WHERE state != 'DELETED'::entity_state_t;
+
+CREATE TABLE tbl_admin_token
+(
+    id         serial PRIMARY KEY,
+    admin_id   INT                         NOT NULL REFERENCES tbl_admin (id),
+    token_hash VARCHAR(64)                 NOT NULL,
+    label      VARCHAR(300)                NOT NULL,
+    state      entity_state_t              NOT NULL,
+    expire_ts  TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    create_ts  TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX uq_admin_token_hash ON tbl_admin_token (token_hash);
+
+CREATE TABLE tbl_audit_log
+(
+    id          serial PRIMARY KEY,
+    actor       VARCHAR(100)                NOT NULL,
+    action      VARCHAR(100)                NOT NULL,
+    object_type VARCHAR(100)                NOT NULL,
+    object_id   INT                         NOT NULL,
+    details     TEXT                        NOT NULL,
+    create_ts   TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"time"
+)
+
+type AdminRole string
+type AdminPermission string
+
+const (
+	AdminRoleAuditor         AdminRole = "AUDITOR"          // read-only access to organizations and audit log
+	AdminRoleOperator        AdminRole = "OPERATOR"         // may add and edit organizations
+	AdminRoleSecurityOfficer AdminRole = "SECURITY_OFFICER" // may manage public keys and revocations
+
+	AdminPermissionRead             AdminPermission = "read"
+	AdminPermissionOrganizationEdit AdminPermission = "organization_edit"
+	AdminPermissionKeyManage        AdminPermission = "key_manage"
+)
+
+var adminRolePermissions = map[AdminRole][]AdminPermission{
+	AdminRoleAuditor:         {AdminPermissionRead},
+	AdminRoleOperator:        {AdminPermissionRead, AdminPermissionOrganizationEdit},
+	AdminRoleSecurityOfficer: {AdminPermissionRead, AdminPermissionKeyManage},
+}
+
+func (r AdminRole) Valid() bool {
+	_, ok := adminRolePermissions[r]
+	return ok
+}
+
+func (r AdminRole) Has(permission AdminPermission) bool {
+	for _, p := range adminRolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type Admin struct {
+	Id           int
+	Username     string
+	PasswordHash string
+	Role         AdminRole
+	State        EntityState
+	CreateTs     time.Time
+	UpdateTs     time.Time
+	Version      int
+}
+
+type AdminToken struct {
+	Id        int
+	AdminId   int
+	TokenHash string
+	Label     string
+	State     EntityState
+	ExpireTs  time.Time
+	CreateTs  time.Time
+}
+
+type AdminTokenCreateRequest struct {
+	Label    string `json:"label"`
+	TtlHours int    `json:"ttl_hours"`
+}
+
+type AdminTokenResponse struct {
+	Id       int    `json:"id"`
+	Token    string `json:"token"`
+	Label    string `json:"label"`
+	ExpireTs int64  `json:"expire_ts" convert_by:"time_to_int64"`
+}
@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionOrganizationAdd         AuditAction = "ORGANIZATION_ADD"
+	AuditActionOrganizationUpdate      AuditAction = "ORGANIZATION_UPDATE"
+	AuditActionOrganizationChangeState AuditAction = "ORGANIZATION_CHANGE_STATE"
+	AuditActionOrganizationKeyChange   AuditAction = "ORGANIZATION_KEY_CHANGE"
+	AuditActionOrganizationRegister    AuditAction = "ORGANIZATION_REGISTER"
+	AuditActionOrganizationApprove     AuditAction = "ORGANIZATION_APPROVE"
+	AuditActionOrganizationReject      AuditAction = "ORGANIZATION_REJECT"
+	AuditActionAdminAdd                AuditAction = "ADMIN_ADD"
+	AuditActionAdminTokenAdd           AuditAction = "ADMIN_TOKEN_ADD"
+	AuditActionCertificateIssue        AuditAction = "CERTIFICATE_ISSUE"
+	AuditActionCertificateRevoke       AuditAction = "CERTIFICATE_REVOKE"
+)
+
+type AuditLog struct {
+	Id         int
+	Actor      string
+	Action     AuditAction
+	ObjectType string
+	ObjectId   int
+	Details    string
+	CreateTs   time.Time
+}
+
+type AuditLogResponse struct {
+	Id         int         `json:"id"`
+	Actor      string      `json:"actor"`
+	Action     AuditAction `json:"action"`
+	ObjectType string      `json:"object_type"`
+	ObjectId   int         `json:"object_id"`
+	Details    string      `json:"details"`
+	CreateTs   int64       `json:"create_ts" convert_by:"time_to_int64"`
+}
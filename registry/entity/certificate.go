@@ -0,0 +1,93 @@
+package entity
+
+import "time"
+
+type CertificateUsage string // what issued certificate authenticates, client or server side of mTLS
+
+const (
+	CertificateUsageClient CertificateUsage = "CLIENT"
+	CertificateUsageServer CertificateUsage = "SERVER"
+)
+
+func (u CertificateUsage) IsValid() bool {
+	switch u {
+	case CertificateUsageClient, CertificateUsageServer:
+		return true
+	}
+	return false
+}
+
+// RevocationReason names follow CRLReason of RFC 5280
+type RevocationReason string
+
+const (
+	RevocationReasonUnspecified          RevocationReason = "unspecified"
+	RevocationReasonKeyCompromise        RevocationReason = "keyCompromise"
+	RevocationReasonAffiliationChanged   RevocationReason = "affiliationChanged"
+	RevocationReasonSuperseded           RevocationReason = "superseded"
+	RevocationReasonCessationOfOperation RevocationReason = "cessationOfOperation"
+)
+
+var revocationReasonCodes = map[RevocationReason]int{
+	RevocationReasonUnspecified:          0,
+	RevocationReasonKeyCompromise:        1,
+	RevocationReasonAffiliationChanged:   3,
+	RevocationReasonSuperseded:           4,
+	RevocationReasonCessationOfOperation: 5,
+}
+
+func (r RevocationReason) IsValid() bool {
+	_, ok := revocationReasonCodes[r]
+	return ok
+}
+
+// Code is CRLReason code put into CRL entries
+func (r RevocationReason) Code() int {
+	return revocationReasonCodes[r]
+}
+
+// IssuedCertificate is DMS node certificate issued by the registry CA for organization key,
+// Serial is hex of the certificate serial number
+type IssuedCertificate struct {
+	Id               int
+	OrganizationId   int
+	Serial           string
+	Usage            CertificateUsage
+	KeyFingerprint   string
+	Certificate      string
+	NotBefore        time.Time
+	NotAfter         time.Time
+	RevokedTs        *time.Time
+	RevocationReason RevocationReason
+	CreateTs         time.Time
+}
+
+type IssuedCertificateResponse struct {
+	Id               int              `json:"id"`
+	OrganizationId   int              `json:"organization_id"`
+	Serial           string           `json:"serial"`
+	Usage            CertificateUsage `json:"usage"`
+	KeyFingerprint   string           `json:"key_fingerprint"`
+	Certificate      string           `json:"certificate"`
+	NotBefore        int64            `json:"not_before" convert_by:"time_to_int64"`
+	NotAfter         int64            `json:"not_after" convert_by:"time_to_int64"`
+	RevokedTs        *int64           `json:"revoked_ts" convert_by:"time_to_int64"`
+	RevocationReason RevocationReason `json:"revocation_reason"`
+	CreateTs         int64            `json:"create_ts" convert_by:"time_to_int64"`
+}
+
+type CertificateIssueRequest struct {
+	Id    int              `json:"-"`
+	Csr   string           `json:"csr"`
+	Usage CertificateUsage `json:"usage"`
+}
+
+type CertificateRevokeRequest struct {
+	Serial string           `json:"-"`
+	Reason RevocationReason `json:"reason"`
+}
+
+type CaCertificateResponse struct {
+	Certificate string `json:"certificate"`
+	NotAfter    int64  `json:"not_after" convert_by:"time_to_int64"`
+}
@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"time"
+)
+
+// KeyChallenge is a nonce the holder of a private key signs to prove possession before its public key is accepted.
+// KeyHash binds the challenge to one public key, UsedTs is set once the proof is accepted.
+type KeyChallenge struct {
+	Id       int
+	Nonce    string
+	KeyHash  string
+	ExpireTs time.Time
+	UsedTs   *time.Time
+	CreateTs time.Time
+}
+
+type KeyChallengeRequest struct {
+	PublicKey string `json:"public_key"`
+}
+
+type KeyChallengeResponse struct {
+	Id       int    `json:"id"`
+	Nonce    string `json:"nonce"`
+	ExpireTs int64  `json:"expire_ts" convert_by:"time_to_int64"`
+}
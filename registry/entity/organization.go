@@ -5,47 +5,202 @@ import (
 )
 
 type EntityState string
-type DMSType string // Document Management System type
+type DMSType string      // Document Management System type
+type KeyAlgorithm string // signature scheme organization key signs with
 
 const (
 	EntityStateDeleted  EntityState = "DELETED"
 	EntityStateDisabled EntityState = "DISABLED"
 	EntityStateEnabled  EntityState = "ENABLED"
+	EntityStatePending  EntityState = "PENDING"  // self-registered organization waiting for operator review
+	EntityStateRejected EntityState = "REJECTED" // self-registered organization rejected by operator
 
 	SRD        DMSType = "SRD"
 	Netije     DMSType = "Netije"
 	EResminama DMSType = "eResminama"
+
+	KeyAlgorithmRsaPkcs1Sha256  KeyAlgorithm = "RSA_PKCS1_SHA256"
+	KeyAlgorithmEcdsaP256Sha256 KeyAlgorithm = "ECDSA_P256_SHA256"
+	KeyAlgorithmEcdsaP384Sha384 KeyAlgorithm = "ECDSA_P384_SHA384"
+	KeyAlgorithmEd25519         KeyAlgorithm = "ED25519"
 )
 
+func (t DMSType) Valid() bool {
+	switch t {
+	case SRD, Netije, EResminama:
+		return true
+	}
+	return false
+}
+
+// OrganizationKey is the key organization signs documents with. Certificate is set when the key was given
+// as X.509 certificate chained to a configured trust anchor, PublicKey is then taken from it.
+// Fingerprint is hex SHA-256 of the key in PKIX (SubjectPublicKeyInfo) DER encoding.
+type OrganizationKey struct {
+	PublicKey    string
+	Algorithm    KeyAlgorithm
+	Fingerprint  string
+	Certificate  string
+	CertExpireTs *time.Time
+}
+
+// SameKey tells whether k and other hold the same key, whatever PEM form or certificate it came in.
+// Keys stored before fingerprints were filled in are compared as text.
+func (k OrganizationKey) SameKey(other OrganizationKey) bool {
+	if k.Fingerprint != "" && other.Fingerprint != "" {
+		return k.Fingerprint == other.Fingerprint
+	}
+	return k.PublicKey == other.PublicKey
+}
+
 type Organization struct {
-	Id        int
-	Name      string
-	Label     string
-	Url       string
-	Type      DMSType
-	PublicKey string
-	State     EntityState
-	CreateTs  time.Time
-	UpdateTs  time.Time
-	Version   int
+	Id    int
+	Name  string
+	Label string
+	Url   string
+	Type  DMSType
+	OrganizationKey
+	State    EntityState
+	CreateTs time.Time
+	UpdateTs time.Time
+	Version  int
 }
 
 type OrganizationResponse struct {
-	Id        int     `json:"id"`
-	Name      string  `json:"name"`
-	Label     string  `json:"label"`
-	Type      DMSType `json:"type"`
-	Url       string  `json:"url"`
-	PublicKey string  `json:"public_key"`
-	CreateTs  int64   `json:"create_ts" convert_by:"time_to_int64"`
-	UpdateTs  int64   `json:"update_ts" convert_by:"time_to_int64"`
+	Id             int          `json:"id"`
+	Name           string       `json:"name"`
+	Label          string       `json:"label"`
+	Type           DMSType      `json:"type"`
+	Url            string       `json:"url"`
+	PublicKey      string       `json:"public_key"`
+	KeyAlgorithm   KeyAlgorithm `json:"key_algorithm"`
+	KeyFingerprint string       `json:"key_fingerprint"`
+	Certificate    string       `json:"certificate"`
+	CertExpireTs   *int64       `json:"cert_expire_ts" convert_by:"time_to_int64"`
+	State          EntityState  `json:"state"`
+	CreateTs       int64        `json:"create_ts" convert_by:"time_to_int64"`
+	UpdateTs       int64        `json:"update_ts" convert_by:"time_to_int64"`
 }
 
 type OrganizationListResponse struct {
-	Id        int    `json:"id"`
-	Name      string `json:"name"`
-	Label     string `json:"label"`
-	Type      DMSType `json:"type"`
-	Url       string `json:"url"`
-	PublicKey string `json:"public_key"`
+	Id             int          `json:"id"`
+	Name           string       `json:"name"`
+	Label          string       `json:"label"`
+	Type           DMSType      `json:"type"`
+	Url            string       `json:"url"`
+	PublicKey      string       `json:"public_key"`
+	KeyAlgorithm   KeyAlgorithm `json:"key_algorithm"`
+	KeyFingerprint string       `json:"key_fingerprint"`
+	// set when key is backed by certificate, verifiers should not trust the key after it
+	CertExpireTs *int64 `json:"cert_expire_ts" convert_by:"time_to_int64"`
+}
+
+type OrganizationAddRequest struct {
+	Name        string  `json:"name"`
+	Label       string  `json:"label"`
+	Type        DMSType `json:"type"`
+	Url         string  `json:"url"`
+	PublicKey   string  `json:"public_key"`
+	Certificate string  `json:"certificate"`
+	ChallengeId int     `json:"challenge_id"`
+	Signature   string  `json:"signature"`
+}
+
+type OrganizationUpdateRequest struct {
+	Id    int     `json:"id"`
+	Name  string  `json:"name"`
+	Label string  `json:"label"`
+	Type  DMSType `json:"type"`
+	Url   string  `json:"url"`
+}
+
+type OrganizationKeyChangeRequest struct {
+	Id          int    `json:"id"`
+	PublicKey   string `json:"public_key"`
+	Certificate string `json:"certificate"`
+	ChallengeId int    `json:"challenge_id"`
+	Signature   string `json:"signature"`
+}
+
+type OrganizationChangeStateRequest struct {
+	Id    int         `json:"id"`
+	State EntityState `json:"state"`
+}
+
+type OrganizationKeyResponse struct {
+	OrganizationId   int          `json:"organization_id"`
+	OrganizationName string       `json:"organization_name"`
+	PublicKey        string       `json:"public_key"`
+	KeyAlgorithm     KeyAlgorithm `json:"key_algorithm"`
+	KeyFingerprint   string       `json:"key_fingerprint"`
+}
+
+type KeyStatus string
+
+const (
+	KeyStatusActive   KeyStatus = "ACTIVE"   // organization is enabled and certificate, if any, is not expired
+	KeyStatusExpired  KeyStatus = "EXPIRED"  // organization is enabled but certificate is expired
+	KeyStatusPending  KeyStatus = "PENDING"  // organization registration is not approved yet
+	KeyStatusInactive KeyStatus = "INACTIVE" // organization is disabled, deleted or rejected
+)
+
+type KeyFingerprintResponse struct {
+	Fingerprint       string       `json:"fingerprint"`
+	KeyStatus         KeyStatus    `json:"key_status"`
+	KeyAlgorithm      KeyAlgorithm `json:"key_algorithm"`
+	PublicKey         string       `json:"public_key"`
+	CertExpireTs      *int64       `json:"cert_expire_ts" convert_by:"time_to_int64"`
+	OrganizationId    int          `json:"organization_id"`
+	OrganizationName  string       `json:"organization_name"`
+	OrganizationState EntityState  `json:"organization_state"`
+}
+
+type OrganizationEventType string
+
+const (
+	OrganizationEventAdded   OrganizationEventType = "ADDED"
+	OrganizationEventUpdated OrganizationEventType = "UPDATED"
+	OrganizationEventDeleted OrganizationEventType = "DELETED"
+)
+
+type OrganizationEvent struct {
+	Type         OrganizationEventType `json:"type"`
+	Organization *OrganizationResponse `json:"organization"`
+}
+
+// RevokedKey records key that stopped being good for organization, when it was replaced or organization left ENABLED
+type RevokedKey struct {
+	Id               int
+	OrganizationId   int
+	KeyFingerprint   string
+	RevocationReason RevocationReason
+	RevokedTs        time.Time
+}
+
+type KeyValidity string // answer of key status query, named after OCSP certificate status
+
+const (
+	KeyValidityGood    KeyValidity = "GOOD"    // current key of enabled organization, certificate, if any, is not expired
+	KeyValidityRevoked KeyValidity = "REVOKED" // key was replaced or organization was disabled or deleted
+	KeyValidityUnknown KeyValidity = "UNKNOWN" // key is not known for organization, pending or expired
+)
+
+// KeyValidityResponse answer may be cached until NextUpdate
+type KeyValidityResponse struct {
+	OrganizationId   int              `json:"organization_id"`
+	KeyFingerprint   string           `json:"key_fingerprint"`
+	Status           KeyValidity      `json:"status"`
+	KeyAlgorithm     KeyAlgorithm     `json:"key_algorithm"`
+	KeyNotAfter      *int64           `json:"key_not_after" convert_by:"time_to_int64"`
+	RevokedTs        *int64           `json:"revoked_ts" convert_by:"time_to_int64"`
+	RevocationReason RevocationReason `json:"revocation_reason"`
+	ThisUpdate       int64            `json:"this_update" convert_by:"time_to_int64"`
+	NextUpdate       int64            `json:"next_update" convert_by:"time_to_int64"`
+}
+
+// SigningKeyResponse is public key registry signs responses with
+type SigningKeyResponse struct {
+	PublicKey      string       `json:"public_key"`
+	KeyAlgorithm   KeyAlgorithm `json:"key_algorithm"`
+	KeyFingerprint string       `json:"key_fingerprint"`
 }
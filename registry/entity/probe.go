@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"time"
+)
+
+// OrganizationProbe is the latest reachability check of organization Url.
+// SuccessTs is the last probe that got a response, CertExpireTs is taken from the leaf certificate of https endpoints.
+type OrganizationProbe struct {
+	OrganizationId   int
+	OrganizationName string
+	Url              string
+	StatusCode       int
+	LatencyMs        int
+	Error            string
+	CertExpireTs     *time.Time
+	SuccessTs        *time.Time
+	ProbeTs          time.Time
+}
+
+type OrganizationProbeResponse struct {
+	OrganizationId   int    `json:"organization_id"`
+	OrganizationName string `json:"organization_name"`
+	Url              string `json:"url"`
+	Reachable        bool   `json:"reachable"`
+	StatusCode       int    `json:"status_code"`
+	LatencyMs        int    `json:"latency_ms"`
+	Error            string `json:"error"`
+	CertExpireTs     *int64 `json:"cert_expire_ts" convert_by:"time_to_int64"`
+	SuccessTs        *int64 `json:"success_ts" convert_by:"time_to_int64"`
+	ProbeTs          int64  `json:"probe_ts" convert_by:"time_to_int64"`
+}
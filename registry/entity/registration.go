@@ -0,0 +1,43 @@
+package entity
+
+// OrganizationRegistration is self-registered organization with the secret its requester polls status with
+// and operator's reason given on review
+type OrganizationRegistration struct {
+	Organization
+	TokenHash string
+	Reason    string
+}
+
+type OrganizationRegistrationRequest struct {
+	Name        string  `json:"name"`
+	Label       string  `json:"label"`
+	Type        DMSType `json:"type"`
+	Url         string  `json:"url"`
+	PublicKey   string  `json:"public_key"`
+	Certificate string  `json:"certificate"`
+	ChallengeId int     `json:"challenge_id"`
+	Signature   string  `json:"signature"`
+}
+
+type OrganizationRegistrationReviewRequest struct {
+	Id     int    `json:"-"`
+	Reason string `json:"reason"`
+}
+
+type OrganizationRegistrationResponse struct {
+	Id             int          `json:"id"`
+	Token          string       `json:"token,omitempty"`
+	Name           string       `json:"name"`
+	Label          string       `json:"label"`
+	Type           DMSType      `json:"type"`
+	Url            string       `json:"url"`
+	PublicKey      string       `json:"public_key"`
+	KeyAlgorithm   KeyAlgorithm `json:"key_algorithm"`
+	KeyFingerprint string       `json:"key_fingerprint"`
+	Certificate    string       `json:"certificate"`
+	CertExpireTs   *int64       `json:"cert_expire_ts" convert_by:"time_to_int64"`
+	State          EntityState  `json:"state"`
+	Reason         string       `json:"reason"`
+	CreateTs       int64        `json:"create_ts" convert_by:"time_to_int64"`
+	UpdateTs       int64        `json:"update_ts" convert_by:"time_to_int64"`
+}
@@ -0,0 +1,31 @@
+package entity
+
+// SnapshotFormatVersion is bumped on incompatible changes of Snapshot
+const SnapshotFormatVersion = 1
+
+// SnapshotManifest describes what a snapshot holds and which registry key signed it
+type SnapshotManifest struct {
+	FormatVersion         int          `json:"format_version"`
+	CreateTs              int64        `json:"create_ts" convert_by:"time_to_int64"`
+	RegistryUrl           string       `json:"registry_url"`
+	OrganizationCount     int          `json:"organization_count"`
+	SigningKeyAlgorithm   KeyAlgorithm `json:"signing_key_algorithm"`
+	SigningKeyFingerprint string       `json:"signing_key_fingerprint"`
+}
+
+// Snapshot is the organization directory as of CreateTs, for registries and clients on isolated networks
+type Snapshot struct {
+	Manifest      SnapshotManifest        `json:"manifest"`
+	Organizations []*OrganizationResponse `json:"organizations"`
+}
+
+// MirrorStatusResponse tells how far a mirror lags behind its upstream registry. LagSeconds is the age of the data
+// served, counted from the start of the last successful sync; it is null until the first one.
+type MirrorStatusResponse struct {
+	UpstreamUrl       string `json:"upstream_url"`
+	LastSyncTs        *int64 `json:"last_sync_ts" convert_by:"time_to_int64"`
+	LastAttemptTs     *int64 `json:"last_attempt_ts" convert_by:"time_to_int64"`
+	LastError         string `json:"last_error"`
+	LagSeconds        *int64 `json:"lag_seconds"`
+	OrganizationCount int    `json:"organization_count"`
+}
@@ -0,0 +1,166 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/x509"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/ratelimit"
+)
+
+const (
+	metadataRequestId  = "x-request-id"
+	metadataRetryAfter = "retry-after"
+)
+
+// requestContext carries request id from metadata (or a new one) in ctx and echoes it in response header.
+// key identifies the client for limits by its verified certificate or its address, without any lookup.
+func (s *Server) requestContext(ctx context.Context, fullMethod string) (_ context.Context, clog *log.Entry, key string) {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(metadataRequestId); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if !logging.ValidRequestId(id) {
+		id = uuid.New().String()
+	}
+	ctx = logging.WithRequestId(ctx, id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataRequestId, id))
+	clog = logging.FromContext(ctx).WithField("grpc-method", fullMethod)
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx, clog, ""
+	}
+	clog = clog.WithField("remote-addr", p.Addr.String())
+	addr := p.Addr.String()
+	if host, _, splitErr := net.SplitHostPort(addr); splitErr == nil {
+		addr = host
+	}
+	return ctx, clog, ratelimit.ClientKey(verifiedClientCertificate(p), addr)
+}
+
+// verifiedClientCertificate returns leaf of the verified client certificate chain of p, nil without one
+func verifiedClientCertificate(p *peer.Peer) *x509.Certificate {
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
+
+// checkClientCertificate rejects verified client certificates that do not belong to any enabled organization,
+// like JSON API does. It runs after rateLimit, since it looks the organization up.
+func (s *Server) checkClientCertificate(ctx context.Context, clog *log.Entry) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	cert := verifiedClientCertificate(p)
+	if cert == nil {
+		return nil
+	}
+	if _, err := s.c.OrganizationByClientCertificate(ctx, cert); err != nil {
+		clog.WithError(err).WithField("subject", cert.Subject.CommonName).Warn("client certificate rejected")
+		return statusByError(err)
+	}
+	return nil
+}
+
+// rateLimit takes a token of the client from the bucket JSON API uses, over the limit it tells client when to retry
+func (s *Server) rateLimit(ctx context.Context, key string, clog *log.Entry) error {
+	conf := s.conf.Get()
+	if conf.RateLimitPerSecond <= 0 || key == "" {
+		return nil
+	}
+	wait := s.limiter.Reserve(key, rate.Limit(conf.RateLimitPerSecond), conf.RateLimitBurst)
+	if wait <= 0 {
+		return nil
+	}
+	clog.WithFields(log.Fields{
+		"key":  key,
+		"wait": wait,
+	}).Warn("rate limit exceeded")
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds())))))
+	return statusByError(api.ErrTooManyRequests)
+}
+
+// acquireStream counts a stream of the client, false when it already has the configured maximum open
+func (s *Server) acquireStream(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streams[key] >= s.conf.Get().MaxWatchStreams() {
+		return false
+	}
+	s.streams[key]++
+	return true
+}
+
+func (s *Server) releaseStream(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams[key]--
+	if s.streams[key] <= 0 {
+		delete(s.streams, key)
+	}
+}
+
+func (s *Server) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	ctx, clog, key := s.requestContext(ctx, info.FullMethod)
+	err = s.rateLimit(ctx, key, clog)
+	if err == nil {
+		err = s.checkClientCertificate(ctx, clog)
+	}
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	clog.WithError(err).WithField("duration", time.Since(start)).Info("gRPC request")
+	return
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+// StreamInterceptor applies the same checks as UnaryInterceptor when a stream opens, and caps watch streams
+// per client, since every one of them re-reads the organization list on each poll
+func (s *Server) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx, clog, key := s.requestContext(ss.Context(), info.FullMethod)
+	err = s.rateLimit(ctx, key, clog)
+	if err == nil {
+		err = s.checkClientCertificate(ctx, clog)
+	}
+	if err == nil {
+		if s.acquireStream(key) {
+			defer s.releaseStream(key)
+			err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		} else {
+			clog.WithField("key", key).Warn("too many watch streams")
+			err = status.Error(codes.ResourceExhausted, "too many open watch streams")
+		}
+	}
+	clog.WithError(err).WithField("duration", time.Since(start)).Info("gRPC stream")
+	return
+}
@@ -0,0 +1,258 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: ykjam/registry/v1/registry.proto
+
+// Registry of organizations taking part in DMS interoperation, gRPC transport of the JSON API.
+
+package registryv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RegistryService_ListOrganizations_FullMethodName  = "/ykjam.registry.v1.RegistryService/ListOrganizations"
+	RegistryService_GetOrganization_FullMethodName    = "/ykjam.registry.v1.RegistryService/GetOrganization"
+	RegistryService_LookupKey_FullMethodName          = "/ykjam.registry.v1.RegistryService/LookupKey"
+	RegistryService_WatchOrganizations_FullMethodName = "/ykjam.registry.v1.RegistryService/WatchOrganizations"
+)
+
+// RegistryServiceClient is the client API for RegistryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RegistryServiceClient interface {
+	// List enabled and disabled organizations, same as GET /api/v1/organizations
+	ListOrganizations(ctx context.Context, in *ListOrganizationsRequest, opts ...grpc.CallOption) (*ListOrganizationsResponse, error)
+	// Get organization by id, same as GET /api/v1/organizations/{id}
+	GetOrganization(ctx context.Context, in *GetOrganizationRequest, opts ...grpc.CallOption) (*GetOrganizationResponse, error)
+	// Look up public key of enabled organization by its name, as sent in X-Organization header
+	LookupKey(ctx context.Context, in *LookupKeyRequest, opts ...grpc.CallOption) (*LookupKeyResponse, error)
+	// Stream current organizations as ADDED events, then every change as it is noticed
+	WatchOrganizations(ctx context.Context, in *WatchOrganizationsRequest, opts ...grpc.CallOption) (RegistryService_WatchOrganizationsClient, error)
+}
+
+type registryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRegistryServiceClient(cc grpc.ClientConnInterface) RegistryServiceClient {
+	return &registryServiceClient{cc}
+}
+
+func (c *registryServiceClient) ListOrganizations(ctx context.Context, in *ListOrganizationsRequest, opts ...grpc.CallOption) (*ListOrganizationsResponse, error) {
+	out := new(ListOrganizationsResponse)
+	err := c.cc.Invoke(ctx, RegistryService_ListOrganizations_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) GetOrganization(ctx context.Context, in *GetOrganizationRequest, opts ...grpc.CallOption) (*GetOrganizationResponse, error) {
+	out := new(GetOrganizationResponse)
+	err := c.cc.Invoke(ctx, RegistryService_GetOrganization_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) LookupKey(ctx context.Context, in *LookupKeyRequest, opts ...grpc.CallOption) (*LookupKeyResponse, error) {
+	out := new(LookupKeyResponse)
+	err := c.cc.Invoke(ctx, RegistryService_LookupKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) WatchOrganizations(ctx context.Context, in *WatchOrganizationsRequest, opts ...grpc.CallOption) (RegistryService_WatchOrganizationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RegistryService_ServiceDesc.Streams[0], RegistryService_WatchOrganizations_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &registryServiceWatchOrganizationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RegistryService_WatchOrganizationsClient interface {
+	Recv() (*WatchOrganizationsResponse, error)
+	grpc.ClientStream
+}
+
+type registryServiceWatchOrganizationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *registryServiceWatchOrganizationsClient) Recv() (*WatchOrganizationsResponse, error) {
+	m := new(WatchOrganizationsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RegistryServiceServer is the server API for RegistryService service.
+// All implementations must embed UnimplementedRegistryServiceServer
+// for forward compatibility
+type RegistryServiceServer interface {
+	// List enabled and disabled organizations, same as GET /api/v1/organizations
+	ListOrganizations(context.Context, *ListOrganizationsRequest) (*ListOrganizationsResponse, error)
+	// Get organization by id, same as GET /api/v1/organizations/{id}
+	GetOrganization(context.Context, *GetOrganizationRequest) (*GetOrganizationResponse, error)
+	// Look up public key of enabled organization by its name, as sent in X-Organization header
+	LookupKey(context.Context, *LookupKeyRequest) (*LookupKeyResponse, error)
+	// Stream current organizations as ADDED events, then every change as it is noticed
+	WatchOrganizations(*WatchOrganizationsRequest, RegistryService_WatchOrganizationsServer) error
+	mustEmbedUnimplementedRegistryServiceServer()
+}
+
+// UnimplementedRegistryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRegistryServiceServer struct {
+}
+
+func (UnimplementedRegistryServiceServer) ListOrganizations(context.Context, *ListOrganizationsRequest) (*ListOrganizationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListOrganizations not implemented")
+}
+func (UnimplementedRegistryServiceServer) GetOrganization(context.Context, *GetOrganizationRequest) (*GetOrganizationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrganization not implemented")
+}
+func (UnimplementedRegistryServiceServer) LookupKey(context.Context, *LookupKeyRequest) (*LookupKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupKey not implemented")
+}
+func (UnimplementedRegistryServiceServer) WatchOrganizations(*WatchOrganizationsRequest, RegistryService_WatchOrganizationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchOrganizations not implemented")
+}
+func (UnimplementedRegistryServiceServer) mustEmbedUnimplementedRegistryServiceServer() {}
+
+// UnsafeRegistryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RegistryServiceServer will
+// result in compilation errors.
+type UnsafeRegistryServiceServer interface {
+	mustEmbedUnimplementedRegistryServiceServer()
+}
+
+func RegisterRegistryServiceServer(s grpc.ServiceRegistrar, srv RegistryServiceServer) {
+	s.RegisterService(&RegistryService_ServiceDesc, srv)
+}
+
+func _RegistryService_ListOrganizations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOrganizationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).ListOrganizations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_ListOrganizations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).ListOrganizations(ctx, req.(*ListOrganizationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_GetOrganization_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrganizationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).GetOrganization(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_GetOrganization_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).GetOrganization(ctx, req.(*GetOrganizationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_LookupKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).LookupKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_LookupKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).LookupKey(ctx, req.(*LookupKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_WatchOrganizations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchOrganizationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RegistryServiceServer).WatchOrganizations(m, &registryServiceWatchOrganizationsServer{stream})
+}
+
+type RegistryService_WatchOrganizationsServer interface {
+	Send(*WatchOrganizationsResponse) error
+	grpc.ServerStream
+}
+
+type registryServiceWatchOrganizationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *registryServiceWatchOrganizationsServer) Send(m *WatchOrganizationsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// RegistryService_ServiceDesc is the grpc.ServiceDesc for RegistryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RegistryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ykjam.registry.v1.RegistryService",
+	HandlerType: (*RegistryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListOrganizations",
+			Handler:    _RegistryService_ListOrganizations_Handler,
+		},
+		{
+			MethodName: "GetOrganization",
+			Handler:    _RegistryService_GetOrganization_Handler,
+		},
+		{
+			MethodName: "LookupKey",
+			Handler:    _RegistryService_LookupKey_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchOrganizations",
+			Handler:       _RegistryService_WatchOrganizations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ykjam/registry/v1/registry.proto",
+}
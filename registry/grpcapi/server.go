@@ -0,0 +1,177 @@
+// Package grpcapi serves registry read API over gRPC, backed by the same api.APIController as JSON API
+package grpcapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/grpcapi/registryv1"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/ratelimit"
+)
+
+// watchPollInterval is how often watch streams check for changes made by other registry instances
+const watchPollInterval = 15 * time.Second
+
+type Server struct {
+	registryv1.UnimplementedRegistryServiceServer
+	c       *api.APIController
+	conf    *config.Store
+	limiter *ratelimit.Limiter
+
+	// watchPollInterval, shortened in tests
+	pollInterval time.Duration
+
+	mu      sync.Mutex
+	streams map[string]int
+}
+
+// NewServer creates gRPC service, limiter is shared with JSON API so that a client has one request budget
+func NewServer(apiController *api.APIController, conf *config.Store, limiter *ratelimit.Limiter) *Server {
+	return &Server{
+		c:            apiController,
+		conf:         conf,
+		limiter:      limiter,
+		pollInterval: watchPollInterval,
+		streams:      make(map[string]int),
+	}
+}
+
+func (s *Server) ListOrganizations(ctx context.Context, _ *registryv1.ListOrganizationsRequest) (resp *registryv1.ListOrganizationsResponse, err error) {
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "grpcapi.ListOrganizations",
+	})
+	items, err := s.c.OrganizationDirectory(ctx)
+	if err != nil {
+		clog.WithError(err).Error("error in api.OrganizationDirectory()")
+		return nil, statusByError(err)
+	}
+	resp = &registryv1.ListOrganizationsResponse{
+		Organizations: make([]*registryv1.Organization, 0, len(items)),
+	}
+	for _, item := range items {
+		resp.Organizations = append(resp.Organizations, organization(item))
+	}
+	return
+}
+
+func (s *Server) GetOrganization(ctx context.Context, req *registryv1.GetOrganizationRequest) (resp *registryv1.GetOrganizationResponse, err error) {
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "grpcapi.GetOrganization",
+		"id":     req.GetId(),
+	})
+	item, err := s.c.OrganizationGet(ctx, int(req.GetId()))
+	if err != nil {
+		clog.WithError(err).Error("error in api.OrganizationGet()")
+		return nil, statusByError(err)
+	}
+	resp = &registryv1.GetOrganizationResponse{Organization: organization(item)}
+	return
+}
+
+func (s *Server) LookupKey(ctx context.Context, req *registryv1.LookupKeyRequest) (resp *registryv1.LookupKeyResponse, err error) {
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "grpcapi.LookupKey",
+		"name":   req.GetOrganizationName(),
+	})
+	item, err := s.c.OrganizationKeyLookup(ctx, req.GetOrganizationName())
+	if err != nil {
+		clog.WithError(err).Error("error in api.OrganizationKeyLookup()")
+		return nil, statusByError(err)
+	}
+	resp = &registryv1.LookupKeyResponse{
+		OrganizationId:   int64(item.OrganizationId),
+		OrganizationName: item.OrganizationName,
+		PublicKey:        item.PublicKey,
+		KeyAlgorithm:     string(item.KeyAlgorithm),
+		KeyFingerprint:   item.KeyFingerprint,
+	}
+	return
+}
+
+func (s *Server) WatchOrganizations(_ *registryv1.WatchOrganizationsRequest, stream registryv1.RegistryService_WatchOrganizationsServer) (err error) {
+	ctx := stream.Context()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"method": "grpcapi.WatchOrganizations",
+	})
+	clog.Info("watch started")
+	err = s.c.OrganizationWatch(ctx, s.pollInterval, func(event *entity.OrganizationEvent) error {
+		return stream.Send(&registryv1.WatchOrganizationsResponse{
+			Type:         eventTypes[event.Type],
+			Organization: organization(event.Organization),
+		})
+	})
+	if err != nil {
+		clog.WithError(err).Warn("watch ended with error")
+		return statusByError(err)
+	}
+	clog.Info("watch ended")
+	return
+}
+
+var states = map[entity.EntityState]registryv1.OrganizationState{
+	entity.EntityStateEnabled:  registryv1.OrganizationState_ORGANIZATION_STATE_ENABLED,
+	entity.EntityStateDisabled: registryv1.OrganizationState_ORGANIZATION_STATE_DISABLED,
+	entity.EntityStateDeleted:  registryv1.OrganizationState_ORGANIZATION_STATE_DELETED,
+}
+
+var eventTypes = map[entity.OrganizationEventType]registryv1.EventType{
+	entity.OrganizationEventAdded:   registryv1.EventType_EVENT_TYPE_ADDED,
+	entity.OrganizationEventUpdated: registryv1.EventType_EVENT_TYPE_UPDATED,
+	entity.OrganizationEventDeleted: registryv1.EventType_EVENT_TYPE_DELETED,
+}
+
+func organization(o *entity.OrganizationResponse) *registryv1.Organization {
+	return &registryv1.Organization{
+		Id:             int64(o.Id),
+		Name:           o.Name,
+		Label:          o.Label,
+		Type:           string(o.Type),
+		Url:            o.Url,
+		PublicKey:      o.PublicKey,
+		KeyAlgorithm:   string(o.KeyAlgorithm),
+		KeyFingerprint: o.KeyFingerprint,
+		State:          states[o.State],
+		CreateTs:       o.CreateTs,
+		UpdateTs:       o.UpdateTs,
+		CertExpireTs:   unixOrZero(o.CertExpireTs),
+	}
+}
+
+func unixOrZero(ts *int64) int64 {
+	if ts == nil {
+		return 0
+	}
+	return *ts
+}
+
+var codesByHttpStatus = map[int]codes.Code{
+	api.ErrorCodeBadRequest:         codes.InvalidArgument,
+	api.ErrorCodeUnauthorized:       codes.Unauthenticated,
+	api.ErrorCodeForbidden:          codes.PermissionDenied,
+	api.ErrorCodeNotFound:           codes.NotFound,
+	api.ErrorCodeConflict:           codes.AlreadyExists,
+	api.ErrorCodeTooManyRequests:    codes.ResourceExhausted,
+	api.ErrorCodeServiceUnavailable: codes.Unavailable,
+}
+
+// statusByError converts api error to gRPC status, anything else is reported as internal error
+func statusByError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	apiErr := api.AsError(err)
+	code, ok := codesByHttpStatus[apiErr.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, apiErr.Message)
+}
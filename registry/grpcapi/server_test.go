@@ -0,0 +1,243 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/grpcapi/registryv1"
+	"ykjam/doc-registry-go/ratelimit"
+)
+
+func testOrganization(id int, name, url string, state entity.EntityState) *entity.Organization {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &entity.Organization{
+		Id:              id,
+		Name:            name,
+		Label:           name,
+		Type:            entity.SRD,
+		Url:             url,
+		OrganizationKey: entity.OrganizationKey{PublicKey: "key", Algorithm: entity.KeyAlgorithmEd25519, Fingerprint: name},
+		State:           state,
+		CreateTs:        now,
+		UpdateTs:        now,
+		Version:         1,
+	}
+}
+
+// newTestClient serves gs with the interceptors the daemon installs over an in-memory connection
+func newTestClient(t *testing.T, gs *Server) registryv1.RegistryServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(gs.UnaryInterceptor), grpc.StreamInterceptor(gs.StreamInterceptor))
+	registryv1.RegisterRegistryServiceServer(srv, gs)
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return registryv1.NewRegistryServiceClient(conn)
+}
+
+func newTestServer(conf *config.Config, organizations ...*entity.Organization) (*Server, *datastore.ReadOnlyAccess) {
+	access := datastore.NewReadOnlyAccess()
+	access.Replace(organizations)
+	return NewServer(api.NewAPIController(access), config.NewStaticStore(conf), ratelimit.New()), access
+}
+
+func TestRequestId(t *testing.T) {
+	gs, _ := newTestServer(&config.Config{},
+		testOrganization(1, "Edara 1", "https://edara-1.example.com", entity.EntityStateEnabled),
+		testOrganization(2, "Edara 2", "https://edara-2.example.com", entity.EntityStateDisabled))
+	c := newTestClient(t, gs)
+
+	tests := []struct {
+		name   string
+		sent   string
+		echoed bool
+	}{
+		{"given", "req-0001", true},
+		{"missing", "", false},
+		{"with space", "req 0001", false},
+		{"too long", strings.Repeat("a", 200), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.sent != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, metadataRequestId, tt.sent)
+			}
+			var header metadata.MD
+			resp, err := c.ListOrganizations(ctx, &registryv1.ListOrganizationsRequest{}, grpc.Header(&header))
+			if err != nil {
+				t.Fatalf("ListOrganizations: %v", err)
+			}
+			ids := header.Get(metadataRequestId)
+			if len(ids) != 1 || ids[0] == "" {
+				t.Fatalf("request id header = %q", ids)
+			}
+			if (ids[0] == tt.sent) != tt.echoed {
+				t.Errorf("request id = %q for %q sent, echoed %v", ids[0], tt.sent, tt.echoed)
+			}
+			if len(resp.Organizations) != 2 || resp.Organizations[1].State != registryv1.OrganizationState_ORGANIZATION_STATE_DISABLED {
+				t.Errorf("ListOrganizations = %v", resp.Organizations)
+			}
+		})
+	}
+
+	// errors carry the request id as well
+	var header metadata.MD
+	_, err := c.GetOrganization(metadata.AppendToOutgoingContext(context.Background(), metadataRequestId, "req-0002"),
+		&registryv1.GetOrganizationRequest{Id: 99}, grpc.Header(&header))
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetOrganization of missing organization = %v, want %s", err, codes.NotFound)
+	}
+	if ids := header.Get(metadataRequestId); len(ids) != 1 || ids[0] != "req-0002" {
+		t.Errorf("request id header of failed call = %q", ids)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	gs, _ := newTestServer(&config.Config{RateLimitPerSecond: 0.01, RateLimitBurst: 2})
+	c := newTestClient(t, gs)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := c.ListOrganizations(ctx, &registryv1.ListOrganizationsRequest{}); err != nil {
+			t.Fatalf("call %d within burst: %v", i, err)
+		}
+	}
+	var header metadata.MD
+	_, err := c.ListOrganizations(ctx, &registryv1.ListOrganizationsRequest{}, grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("call over limit = %v, want %s", err, codes.ResourceExhausted)
+	}
+	if retry := header.Get(metadataRetryAfter); len(retry) != 1 || retry[0] == "0" {
+		t.Errorf("retry-after header = %q", retry)
+	}
+	// streams draw from the same bucket
+	stream, err := c.WatchOrganizations(ctx, &registryv1.WatchOrganizationsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("watch over limit = %v, want %s", err, codes.ResourceExhausted)
+	}
+}
+
+func TestWatchStreamCap(t *testing.T) {
+	gs, _ := newTestServer(&config.Config{GrpcMaxWatchStreams: 1},
+		testOrganization(1, "Edara 1", "https://edara-1.example.com", entity.EntityStateEnabled))
+	c := newTestClient(t, gs)
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	defer cancelFirst()
+	stream, err := c.WatchOrganizations(first, &registryv1.WatchOrganizationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatalf("first watch: %v", err)
+	}
+
+	watch := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s, err := c.WatchOrganizations(ctx, &registryv1.WatchOrganizationsRequest{})
+		if err != nil {
+			return err
+		}
+		_, err = s.Recv()
+		return err
+	}
+	if err = watch(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second watch = %v, want %s", err, codes.ResourceExhausted)
+	}
+
+	// closing the first stream frees its slot
+	cancelFirst()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err = watch(); err == nil {
+			break
+		}
+		if status.Code(err) != codes.ResourceExhausted || time.Now().After(deadline) {
+			t.Fatalf("watch after first one closed = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchOrganizations(t *testing.T) {
+	gs, access := newTestServer(&config.Config{},
+		testOrganization(1, "Edara 1", "https://edara-1.example.com", entity.EntityStateEnabled),
+		testOrganization(2, "Edara 2", "https://edara-2.example.com", entity.EntityStateEnabled))
+	gs.pollInterval = 20 * time.Millisecond
+	c := newTestClient(t, gs)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := c.WatchOrganizations(ctx, &registryv1.WatchOrganizationsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type event struct {
+		eventType registryv1.EventType
+		id        int64
+		url       string
+		state     registryv1.OrganizationState
+	}
+	recv := func() event {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		o := resp.GetOrganization()
+		return event{resp.GetType(), o.GetId(), o.GetUrl(), o.GetState()}
+	}
+	for _, want := range []event{
+		{registryv1.EventType_EVENT_TYPE_ADDED, 1, "https://edara-1.example.com", registryv1.OrganizationState_ORGANIZATION_STATE_ENABLED},
+		{registryv1.EventType_EVENT_TYPE_ADDED, 2, "https://edara-2.example.com", registryv1.OrganizationState_ORGANIZATION_STATE_ENABLED},
+	} {
+		if got := recv(); got != want {
+			t.Fatalf("event = %+v, want %+v", got, want)
+		}
+	}
+
+	// another instance changes organization 1, deletes 2 and adds 3; polling picks the changes up
+	access.Replace([]*entity.Organization{
+		testOrganization(1, "Edara 1", "https://edara-1.example.com/v2", entity.EntityStateDisabled),
+		testOrganization(3, "Edara 3", "https://edara-3.example.com", entity.EntityStateEnabled),
+	})
+	got := map[int64]event{}
+	for i := 0; i < 3; i++ {
+		e := recv()
+		got[e.id] = e
+	}
+	for _, want := range []event{
+		{registryv1.EventType_EVENT_TYPE_UPDATED, 1, "https://edara-1.example.com/v2", registryv1.OrganizationState_ORGANIZATION_STATE_DISABLED},
+		{registryv1.EventType_EVENT_TYPE_DELETED, 2, "https://edara-2.example.com", registryv1.OrganizationState_ORGANIZATION_STATE_DELETED},
+		{registryv1.EventType_EVENT_TYPE_ADDED, 3, "https://edara-3.example.com", registryv1.OrganizationState_ORGANIZATION_STATE_ENABLED},
+	} {
+		if got[want.id] != want {
+			t.Errorf("event = %+v, want %+v", got[want.id], want)
+		}
+	}
+}
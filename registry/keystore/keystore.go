@@ -0,0 +1,80 @@
+// Package keystore opens private keys the registry signs with from the configured backend, PEM files or a PKCS #11
+// token, so that keys of production registries need not be kept on disk
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+
+	"ykjam/doc-registry-go/config"
+)
+
+var (
+	ErrNoPrivateKey   = errors.New("no private key found")
+	ErrUnknownBackend = errors.New("unknown key backend")
+)
+
+// Keystore hands out signers for keys of one backend, signers must not be used after Close
+type Keystore interface {
+	// Signer returns private key named by ref, which is a PEM file path or a PKCS #11 key label
+	Signer(ref string) (crypto.Signer, error)
+	Close() error
+}
+
+// Open opens key backend of conf, PKCS #11 token is logged in until Close
+func Open(conf *config.Config) (ks Keystore, err error) {
+	switch conf.KeyBackend {
+	case "", config.KeyBackendFile:
+		return fileKeystore{}, nil
+	case config.KeyBackendPkcs11:
+		return openPkcs11(conf.Pkcs11Module, conf.Pkcs11TokenLabel, conf.Pkcs11Pin)
+	}
+	return nil, errors.Wrap(ErrUnknownBackend, conf.KeyBackend)
+}
+
+type fileKeystore struct{}
+
+func (fileKeystore) Signer(ref string) (signer crypto.Signer, err error) {
+	raw, err := ioutil.ReadFile(ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading key file")
+	}
+	return ParsePrivateKey(raw)
+}
+
+func (fileKeystore) Close() error {
+	return nil
+}
+
+// ParsePrivateKey accepts PEM encoded PKCS #8, PKCS #1 RSA or SEC 1 EC private key
+func ParsePrivateKey(raw []byte) (signer crypto.Signer, err error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, ErrNoPrivateKey
+	}
+	if key, pErr := x509.ParsePKCS8PrivateKey(block.Bytes); pErr == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case *ecdsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		}
+		return nil, ErrNoPrivateKey
+	}
+	if key, pErr := x509.ParsePKCS1PrivateKey(block.Bytes); pErr == nil {
+		return key, nil
+	}
+	if key, pErr := x509.ParseECPrivateKey(block.Bytes); pErr == nil {
+		return key, nil
+	}
+	return nil, ErrNoPrivateKey
+}
@@ -0,0 +1,121 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+// signAndVerify signs with signer of ks named ref and checks the signature against its public key with signing.Verify
+func signAndVerify(t *testing.T, ks Keystore, ref string, wantAlg entity.KeyAlgorithm) {
+	t.Helper()
+	signer, err := ks.Signer(ref)
+	if err != nil {
+		t.Fatalf("Signer(%s): %v", ref, err)
+	}
+	alg, err := signing.Algorithm(signer.Public())
+	if err != nil || alg != wantAlg {
+		t.Fatalf("algorithm = %s, %v, want %s", alg, err, wantAlg)
+	}
+	message := []byte(`{"id":1,"name":"test"}`)
+	signature, err := signing.Sign(signer, message)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err = signing.Verify(alg, signer.Public(), message, signature); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err = signing.Verify(alg, signer.Public(), append(message, ' '), signature); !errors.Is(err, signing.ErrInvalid) {
+		t.Fatalf("Verify of changed message = %v, want %v", err, signing.ErrInvalid)
+	}
+}
+
+func writePEM(t *testing.T, blockType string, der []byte) (path string) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func pkcs8(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestFileKeystore(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Der, err := x509.MarshalECPrivateKey(p256Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := Open(&config.Config{KeyBackend: config.KeyBackendFile})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer ks.Close()
+	tests := []struct {
+		name      string
+		blockType string
+		der       []byte
+		alg       entity.KeyAlgorithm
+	}{
+		{"RSA PKCS #1", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), entity.KeyAlgorithmRsaPkcs1Sha256},
+		{"RSA PKCS #8", "PRIVATE KEY", pkcs8(t, rsaKey), entity.KeyAlgorithmRsaPkcs1Sha256},
+		{"P-256 SEC 1", "EC PRIVATE KEY", p256Der, entity.KeyAlgorithmEcdsaP256Sha256},
+		{"P-384 PKCS #8", "PRIVATE KEY", pkcs8(t, p384Key), entity.KeyAlgorithmEcdsaP384Sha384},
+		{"Ed25519 PKCS #8", "PRIVATE KEY", pkcs8(t, edKey), entity.KeyAlgorithmEd25519},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signAndVerify(t, ks, writePEM(t, tt.blockType, tt.der), tt.alg)
+		})
+	}
+
+	if _, err = ks.Signer(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("Signer of missing file succeeded")
+	}
+	if _, err = ks.Signer(writePEM(t, "CERTIFICATE", []byte{0x30, 0x00})); !errors.Is(err, ErrNoPrivateKey) {
+		t.Errorf("Signer of non-key PEM = %v, want %v", err, ErrNoPrivateKey)
+	}
+}
+
+func TestOpenUnknownBackend(t *testing.T) {
+	if _, err := Open(&config.Config{KeyBackend: "vault"}); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("Open = %v, want %v", err, ErrUnknownBackend)
+	}
+}
@@ -0,0 +1,265 @@
+//go:build cgo
+
+package keystore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PKCS #11 3.0 values the pkcs11 package does not define
+	ckkEcEdwards = 0x40
+	ckmEddsa     = 0x1057
+)
+
+var (
+	oidP256    = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidP384    = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+	// CKM_RSA_PKCS signs DigestInfo as is, so the DER prefix naming the hash is put before the digest here
+	digestInfoPrefixes = map[crypto.Hash][]byte{
+		crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+		crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+		crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+	}
+
+	ErrUnsupportedSignOpts = errors.New("unsupported signing options")
+)
+
+type pkcs11Keystore struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	// a session runs one operation at a time, every signer of the keystore shares it
+	mu sync.Mutex
+}
+
+func openPkcs11(module, tokenLabel, pin string) (ks Keystore, err error) {
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		return nil, errors.Errorf("error loading PKCS #11 module %s", module)
+	}
+	if err = ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, errors.Wrap(err, "error initializing PKCS #11 module")
+	}
+	defer func() {
+		if err != nil {
+			_ = ctx.Finalize()
+			ctx.Destroy()
+		}
+	}()
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing PKCS #11 slots")
+	}
+	found := false
+	var slot uint
+	for _, s := range slots {
+		info, iErr := ctx.GetTokenInfo(s)
+		if iErr == nil && info.Label == tokenLabel {
+			slot, found = s, true
+			break
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("PKCS #11 token %q not found", tokenLabel)
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening PKCS #11 session")
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, pin); err != nil {
+		return nil, errors.Wrap(err, "error logging in to PKCS #11 token")
+	}
+	return &pkcs11Keystore{ctx: ctx, session: session}, nil
+}
+
+func (p *pkcs11Keystore) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_ = p.ctx.Logout(p.session)
+	_ = p.ctx.CloseSession(p.session)
+	err := p.ctx.Finalize()
+	p.ctx.Destroy()
+	return err
+}
+
+// findObject returns handle of the only object of class labeled label
+func (p *pkcs11Keystore) findObject(class uint, label string) (handle pkcs11.ObjectHandle, err error) {
+	err = p.ctx.FindObjectsInit(p.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return
+	}
+	handles, _, err := p.ctx.FindObjects(p.session, 2)
+	if fErr := p.ctx.FindObjectsFinal(p.session); err == nil {
+		err = fErr
+	}
+	if err != nil {
+		return
+	}
+	switch len(handles) {
+	case 0:
+		return 0, errors.Errorf("no object labeled %q found", label)
+	case 1:
+		return handles[0], nil
+	}
+	return 0, errors.Errorf("several objects labeled %q found", label)
+}
+
+// Signer finds private key labeled ref and its public key with the same label, as pkcs11-tool --keypairgen makes them
+func (p *pkcs11Keystore) Signer(ref string) (signer crypto.Signer, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, err := p.findObject(pkcs11.CKO_PRIVATE_KEY, ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding private key")
+	}
+	pubHandle, err := p.findObject(pkcs11.CKO_PUBLIC_KEY, ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding public key")
+	}
+	attrs, err := p.ctx.GetAttributeValue(p.session, pubHandle, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)})
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading key type")
+	}
+	var pub crypto.PublicKey
+	switch keyType := attrs[0].Value; {
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA).Value):
+		pub, err = p.rsaPublicKey(pubHandle)
+	case bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC).Value),
+		bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, ckkEcEdwards).Value):
+		pub, err = p.ecPublicKey(pubHandle)
+	default:
+		err = errors.New("unsupported key type")
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading public key %q", ref)
+	}
+	return &pkcs11Signer{keystore: p, key: key, pub: pub}, nil
+}
+
+func (p *pkcs11Keystore) rsaPublicKey(handle pkcs11.ObjectHandle) (pub crypto.PublicKey, err error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil {
+		return
+	}
+	e := new(big.Int).SetBytes(attrs[1].Value)
+	if !e.IsInt64() {
+		return nil, errors.New("RSA public exponent is too large")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(attrs[0].Value), E: int(e.Int64())}, nil
+}
+
+// ecPublicKey reads P-256, P-384 and Ed25519 keys, Edwards curve may be named by OID or by printable string
+func (p *pkcs11Keystore) ecPublicKey(handle pkcs11.ObjectHandle) (pub crypto.PublicKey, err error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return
+	}
+	// CKA_EC_POINT is DER octet string, some tokens return the bare point
+	point := attrs[1].Value
+	var inner []byte
+	if rest, uErr := asn1.Unmarshal(point, &inner); uErr == nil && len(rest) == 0 {
+		point = inner
+	}
+	var oid asn1.ObjectIdentifier
+	var name string
+	if _, uErr := asn1.Unmarshal(attrs[0].Value, &oid); uErr != nil {
+		if _, uErr = asn1.Unmarshal(attrs[0].Value, &name); uErr != nil {
+			return nil, errors.New("unsupported EC parameters")
+		}
+	}
+	var curve elliptic.Curve
+	switch {
+	case oid.Equal(oidEd25519), name == "edwards25519":
+		if len(point) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 point")
+		}
+		return ed25519.PublicKey(point), nil
+	case oid.Equal(oidP256):
+		curve = elliptic.P256()
+	case oid.Equal(oidP384):
+		curve = elliptic.P384()
+	default:
+		return nil, errors.New("unsupported curve")
+	}
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, errors.New("invalid EC point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// pkcs11Signer signs the way keys of crypto packages do: PKCS #1 v1.5 for RSA, ASN.1 DER for ECDSA
+// and pure Ed25519 over the message
+type pkcs11Signer struct {
+	keystore *pkcs11Keystore
+	key      pkcs11.ObjectHandle
+	pub      crypto.PublicKey
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.pub
+}
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) (signature []byte, err error) {
+	var mechanism uint
+	data := digest
+	switch s.pub.(type) {
+	case *rsa.PublicKey:
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if _, pss := opts.(*rsa.PSSOptions); pss || !ok {
+			return nil, ErrUnsupportedSignOpts
+		}
+		mechanism = pkcs11.CKM_RSA_PKCS
+		data = append(append([]byte{}, prefix...), digest...)
+	case *ecdsa.PublicKey:
+		mechanism = pkcs11.CKM_ECDSA
+	case ed25519.PublicKey:
+		if opts.HashFunc() != crypto.Hash(0) {
+			return nil, ErrUnsupportedSignOpts
+		}
+		mechanism = ckmEddsa
+	}
+	raw, err := s.keystore.sign(s.key, mechanism, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing with PKCS #11 key")
+	}
+	if _, ok := s.pub.(*ecdsa.PublicKey); ok {
+		// CKM_ECDSA returns r and s of curve size each
+		half := len(raw) / 2
+		return asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(raw[:half]), new(big.Int).SetBytes(raw[half:])})
+	}
+	return raw, nil
+}
+
+func (p *pkcs11Keystore) sign(key pkcs11.ObjectHandle, mechanism uint, data []byte) (signature []byte, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err = p.ctx.SignInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, key); err != nil {
+		return
+	}
+	return p.ctx.Sign(p.session, data)
+}
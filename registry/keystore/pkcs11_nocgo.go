@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package keystore
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PKCS #11 modules are loaded through cgo, which cross-compiled builds lack
+func openPkcs11(module, tokenLabel, pin string) (ks Keystore, err error) {
+	return nil, errors.New("pkcs11 key backend needs a build with cgo enabled")
+}
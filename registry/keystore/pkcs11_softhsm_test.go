@@ -0,0 +1,208 @@
+//go:build softhsm && cgo
+
+// Run with a local SoftHSM: go test -tags softhsm ./keystore
+// SOFTHSM2_MODULE names libsofthsm2.so when it is not in one of the usual places.
+
+package keystore
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/entity"
+)
+
+const (
+	softhsmTokenLabel = "registry-test"
+	softhsmSoPin      = "5678"
+	softhsmUserPin    = "1234"
+	// PKCS #11 3.0 value the pkcs11 package does not define
+	ckmEcEdwardsKeyPairGen = 0x1055
+)
+
+var softhsmModules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib64/pkcs11/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+}
+
+func softhsmModule(t *testing.T) string {
+	if module := os.Getenv("SOFTHSM2_MODULE"); module != "" {
+		return module
+	}
+	for _, module := range softhsmModules {
+		if _, err := os.Stat(module); err == nil {
+			return module
+		}
+	}
+	t.Fatal("libsofthsm2.so not found, set SOFTHSM2_MODULE")
+	return ""
+}
+
+type softhsmKey struct {
+	label     string
+	alg       entity.KeyAlgorithm
+	mechanism uint
+	public    []*pkcs11.Attribute
+}
+
+// initSofthsmToken points SoftHSM at an empty token directory, initializes a token there
+// and generates keys on it the way pkcs11-tool --keypairgen does; keys the token cannot generate are left out
+func initSofthsmToken(t *testing.T, module string, keys []softhsmKey) (generated []softhsmKey) {
+	dir := t.TempDir()
+	tokenDir := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokenDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	confFile := filepath.Join(dir, "softhsm2.conf")
+	conf := fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\nlog.level = ERROR\n", tokenDir)
+	if err := os.WriteFile(confFile, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", confFile)
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Fatalf("error loading %s", module)
+	}
+	defer ctx.Destroy()
+	if err := ctx.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	// keystore initializes the module again, which fails while it is initialized here
+	defer func() { _ = ctx.Finalize() }()
+	slots, err := ctx.GetSlotList(false)
+	if err != nil || len(slots) == 0 {
+		t.Fatalf("GetSlotList: %v, %d slots", err, len(slots))
+	}
+	if err = ctx.InitToken(slots[0], softhsmSoPin, softhsmTokenLabel); err != nil {
+		t.Fatalf("InitToken: %v", err)
+	}
+	// SoftHSM moves an initialized token to a new slot
+	slots, err = ctx.GetSlotList(true)
+	if err != nil {
+		t.Fatalf("GetSlotList: %v", err)
+	}
+	var slot uint
+	found := false
+	for _, s := range slots {
+		if info, iErr := ctx.GetTokenInfo(s); iErr == nil && info.Label == softhsmTokenLabel {
+			slot, found = s, true
+		}
+	}
+	if !found {
+		t.Fatal("initialized token not found")
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatalf("OpenSession: %v", err)
+	}
+	defer func() { _ = ctx.CloseSession(session) }()
+	if err = ctx.Login(session, pkcs11.CKU_SO, softhsmSoPin); err != nil {
+		t.Fatalf("Login SO: %v", err)
+	}
+	if err = ctx.InitPIN(session, softhsmUserPin); err != nil {
+		t.Fatalf("InitPIN: %v", err)
+	}
+	if err = ctx.Logout(session); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, softhsmUserPin); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	defer func() { _ = ctx.Logout(session) }()
+
+	for _, k := range keys {
+		public := append([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, k.label),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		}, k.public...)
+		private := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, k.label),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		}
+		_, _, err = ctx.GenerateKeyPair(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(k.mechanism, nil)}, public, private)
+		if errors.Is(err, pkcs11.Error(pkcs11.CKR_MECHANISM_INVALID)) {
+			t.Logf("token cannot generate %s keys", k.alg)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("GenerateKeyPair %s: %v", k.label, err)
+		}
+		generated = append(generated, k)
+	}
+	return
+}
+
+func ecParams(t *testing.T, oid asn1.ObjectIdentifier) []byte {
+	params, err := asn1.Marshal(oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+func TestPkcs11Keystore(t *testing.T) {
+	module := softhsmModule(t)
+	keys := initSofthsmToken(t, module, []softhsmKey{
+		{"rsa", entity.KeyAlgorithmRsaPkcs1Sha256, pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+		}},
+		{"p256", entity.KeyAlgorithmEcdsaP256Sha256, pkcs11.CKM_EC_KEY_PAIR_GEN, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams(t, oidP256)),
+		}},
+		{"p384", entity.KeyAlgorithmEcdsaP384Sha384, pkcs11.CKM_EC_KEY_PAIR_GEN, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams(t, oidP384)),
+		}},
+		{"ed25519", entity.KeyAlgorithmEd25519, ckmEcEdwardsKeyPairGen, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams(t, oidEd25519)),
+		}},
+	})
+
+	ks, err := Open(&config.Config{
+		KeyBackend:       config.KeyBackendPkcs11,
+		Pkcs11Module:     module,
+		Pkcs11TokenLabel: softhsmTokenLabel,
+		Pkcs11Pin:        softhsmUserPin,
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer ks.Close()
+	for _, k := range keys {
+		t.Run(k.label, func(t *testing.T) {
+			signAndVerify(t, ks, k.label, k.alg)
+		})
+	}
+	if _, err = ks.Signer("missing"); err == nil {
+		t.Error("Signer of missing label succeeded")
+	}
+}
+
+func TestPkcs11WrongPin(t *testing.T) {
+	module := softhsmModule(t)
+	initSofthsmToken(t, module, nil)
+	_, err := Open(&config.Config{
+		KeyBackend:       config.KeyBackendPkcs11,
+		Pkcs11Module:     module,
+		Pkcs11TokenLabel: softhsmTokenLabel,
+		Pkcs11Pin:        "0000",
+	})
+	if err == nil {
+		t.Fatal("Open with wrong PIN succeeded")
+	}
+}
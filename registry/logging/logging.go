@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/config"
+)
+
+type contextKey string
+
+const (
+	contextKeyRequestId contextKey = "request-id"
+
+	maxIncomingRequestIdLen = 128
+)
+
+const (
+	FormatText = "text"
+	FormatJson = "json"
+
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+
+	timestampFormat = "01-02 15:04:05.000"
+)
+
+// WithRequestId returns context carrying request id, which FromContext adds to every log entry
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, contextKeyRequestId, requestId)
+}
+
+// ValidRequestId accepts only printable ASCII without spaces, so that ids passed in by clients are safe to log
+func ValidRequestId(id string) bool {
+	if id == "" || len(id) > maxIncomingRequestIdLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestId returns request id carried by ctx, empty if none
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(contextKeyRequestId).(string)
+	return id
+}
+
+// FromContext returns log entry bound to ctx, with request id field when ctx carries one
+func FromContext(ctx context.Context) *log.Entry {
+	entry := log.WithContext(ctx)
+	if id := RequestId(ctx); id != "" {
+		entry = entry.WithField("request-id", id)
+	}
+	return entry
+}
+
+func newFormatter(format string) log.Formatter {
+	if format == FormatJson {
+		return &log.JSONFormatter{}
+	}
+	return &log.TextFormatter{
+		TimestampFormat: timestampFormat,
+		FullTimestamp:   true,
+	}
+}
+
+func openOutput(output string) (w io.Writer, err error) {
+	switch output {
+	case "", OutputStdout:
+		return os.Stdout, nil
+	case OutputStderr:
+		return os.Stderr, nil
+	}
+	return os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+}
+
+// Setup configures standard logger format, level and output
+func Setup(conf *config.Config) (err error) {
+	var out io.Writer
+	out, err = openOutput(conf.LogOutput)
+	if err != nil {
+		return errors.Wrap(err, "error opening log output")
+	}
+	log.SetOutput(out)
+	log.SetFormatter(newFormatter(conf.LogFormat))
+	return SetLevel(conf)
+}
+
+// SetLevel applies log level, it is safe to call on config reload
+func SetLevel(conf *config.Config) (err error) {
+	level := log.InfoLevel
+	if conf.LogLevel != "" {
+		level, err = log.ParseLevel(conf.LogLevel)
+		if err != nil {
+			return errors.Wrap(err, "error parsing log level")
+		}
+	}
+	log.SetLevel(level)
+	return nil
+}
+
+// NewAccessLogger returns logger for HTTP access log, nil when access log is disabled
+func NewAccessLogger(conf *config.Config) (logger *log.Logger, err error) {
+	if conf.AccessLogOutput == "" {
+		return nil, nil
+	}
+	var out io.Writer
+	out, err = openOutput(conf.AccessLogOutput)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening access log output")
+	}
+	logger = log.New()
+	logger.SetOutput(out)
+	logger.SetFormatter(newFormatter(conf.LogFormat))
+	logger.SetLevel(log.InfoLevel)
+	return
+}
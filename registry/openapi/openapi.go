@@ -0,0 +1,25 @@
+// Package openapi holds OpenAPI specification of the registry HTTP API, served by the daemon
+// and used by the contract harness to check handlers against it
+package openapi
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.yml
+var Spec []byte
+
+// Load parses and validates Spec
+func Load(ctx context.Context) (doc *openapi3.T, err error) {
+	loader := openapi3.NewLoader()
+	loader.Context = ctx
+	doc, err = loader.LoadFromData(Spec)
+	if err != nil {
+		return
+	}
+	err = doc.Validate(ctx)
+	return
+}
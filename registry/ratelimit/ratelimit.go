@@ -0,0 +1,81 @@
+// Package ratelimit keeps a token bucket per client, shared by JSON and gRPC API so that a client has one budget
+package ratelimit
+
+import (
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	limiterIdleTimeout   = 10 * time.Minute
+	limiterSweepInterval = time.Minute
+)
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter keeps token bucket per client key, idle buckets are swept periodically
+type Limiter struct {
+	mu        sync.Mutex
+	entries   map[string]*limiterEntry
+	lastSweep time.Time
+}
+
+// ClientKey identifies client by common name of its verified certificate, the name it maps to an organization by,
+// or else by its address. It needs no lookup, so that limits apply before the client costs a database query.
+func ClientKey(cert *x509.Certificate, addr string) string {
+	if cert != nil && cert.Subject.CommonName != "" {
+		return "cn:" + cert.Subject.CommonName
+	}
+	return "addr:" + addr
+}
+
+func New() *Limiter {
+	return &Limiter{
+		entries:   make(map[string]*limiterEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Reserve takes a token for key, returns how long the client has to wait if there is none
+func (rl *Limiter) Reserve(key string, limit rate.Limit, burst int) (wait time.Duration) {
+	now := time.Now()
+	rl.mu.Lock()
+	if now.Sub(rl.lastSweep) > limiterSweepInterval {
+		for k, e := range rl.entries {
+			if now.Sub(e.lastSeen) > limiterIdleTimeout {
+				delete(rl.entries, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+	e, ok := rl.entries[key]
+	if !ok {
+		e = &limiterEntry{limiter: rate.NewLimiter(limit, burst)}
+		rl.entries[key] = e
+	}
+	e.lastSeen = now
+	rl.mu.Unlock()
+
+	// limits may have been changed by config reload
+	if e.limiter.Limit() != limit {
+		e.limiter.SetLimitAt(now, limit)
+	}
+	if e.limiter.Burst() != burst {
+		e.limiter.SetBurstAt(now, burst)
+	}
+	r := e.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return time.Second
+	}
+	wait = r.DelayFrom(now)
+	if wait > 0 {
+		r.CancelAt(now)
+	}
+	return
+}
@@ -0,0 +1,88 @@
+// Package signing maps public keys to the signature schemes organizations may use and verifies signatures
+// made with them, so that the registry and its clients agree on how each key algorithm signs
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+
+	"github.com/pkg/errors"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported public key")
+	ErrInvalid        = errors.New("signature is invalid")
+)
+
+// Algorithm returns the scheme key signs with, RSA keys keep PKCS #1 v1.5 over SHA-256 of the interop spec
+// and ECDSA curves sign over the hash of matching size
+func Algorithm(key crypto.PublicKey) (alg entity.KeyAlgorithm, err error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return entity.KeyAlgorithmRsaPkcs1Sha256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return entity.KeyAlgorithmEcdsaP256Sha256, nil
+		case elliptic.P384():
+			return entity.KeyAlgorithmEcdsaP384Sha384, nil
+		}
+	case ed25519.PublicKey:
+		return entity.KeyAlgorithmEd25519, nil
+	}
+	return "", ErrUnsupportedKey
+}
+
+// Verify checks signature over message made by private key of key with alg
+func Verify(alg entity.KeyAlgorithm, key crypto.PublicKey, message, signature []byte) error {
+	keyAlg, err := Algorithm(key)
+	if err != nil {
+		return err
+	}
+	if keyAlg != alg {
+		return errors.Wrapf(ErrUnsupportedKey, "key does not sign with %s", alg)
+	}
+	ok := false
+	switch alg {
+	case entity.KeyAlgorithmRsaPkcs1Sha256:
+		digest := sha256.Sum256(message)
+		ok = rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case entity.KeyAlgorithmEcdsaP256Sha256:
+		digest := sha256.Sum256(message)
+		ok = ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], signature)
+	case entity.KeyAlgorithmEcdsaP384Sha384:
+		digest := sha512.Sum384(message)
+		ok = ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], signature)
+	case entity.KeyAlgorithmEd25519:
+		ok = ed25519.Verify(key.(ed25519.PublicKey), message, signature)
+	}
+	if !ok {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// Sign signs message with signer using the scheme of its key algorithm, so that Verify accepts the signature
+func Sign(signer crypto.Signer, message []byte) (signature []byte, err error) {
+	alg, err := Algorithm(signer.Public())
+	if err != nil {
+		return
+	}
+	switch alg {
+	case entity.KeyAlgorithmRsaPkcs1Sha256, entity.KeyAlgorithmEcdsaP256Sha256:
+		digest := sha256.Sum256(message)
+		return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case entity.KeyAlgorithmEcdsaP384Sha384:
+		digest := sha512.Sum384(message)
+		return signer.Sign(rand.Reader, digest[:], crypto.SHA384)
+	}
+	return signer.Sign(rand.Reader, message, crypto.Hash(0))
+}
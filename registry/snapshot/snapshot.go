@@ -0,0 +1,104 @@
+// Package snapshot writes and reads signed bundles of the organization directory, which carry registry data
+// to isolated networks where neither the registry nor its mirrors are reachable
+package snapshot
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+var (
+	ErrInvalidBundle     = errors.New("invalid snapshot bundle")
+	ErrUnsupportedFormat = errors.New("unsupported snapshot format version")
+)
+
+// Verifier checks base64 registry signature over body, client.Verifier satisfies it
+type Verifier interface {
+	Verify(body []byte, signature string) error
+}
+
+// bundle keeps snapshot as signed bytes, so that verification does not depend on how JSON is re-encoded
+type bundle struct {
+	Snapshot  json.RawMessage `json:"snapshot"`
+	Signature string          `json:"signature"`
+}
+
+// Create signs s with signer and returns bundle file content
+func Create(signer crypto.Signer, s *entity.Snapshot) (raw []byte, err error) {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	sig, err := signing.Sign(signer, content)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing snapshot")
+	}
+	return json.Marshal(&bundle{Snapshot: content, Signature: base64.StdEncoding.EncodeToString(sig)})
+}
+
+// Open verifies bundle signature with verifier and returns snapshot it holds
+func Open(raw []byte, verifier Verifier) (s *entity.Snapshot, err error) {
+	b := &bundle{}
+	if err = json.Unmarshal(raw, b); err != nil || len(b.Snapshot) == 0 || b.Signature == "" {
+		return nil, ErrInvalidBundle
+	}
+	// signed content is compact JSON, a bundle reformatted on the way still verifies
+	content := &bytes.Buffer{}
+	if err = json.Compact(content, b.Snapshot); err != nil {
+		return nil, ErrInvalidBundle
+	}
+	if err = verifier.Verify(content.Bytes(), b.Signature); err != nil {
+		return nil, err
+	}
+	s = &entity.Snapshot{}
+	if err = json.Unmarshal(b.Snapshot, s); err != nil {
+		return nil, errors.Wrap(ErrInvalidBundle, err.Error())
+	}
+	if s.Manifest.FormatVersion != entity.SnapshotFormatVersion {
+		return nil, errors.Wrapf(ErrUnsupportedFormat, "%d", s.Manifest.FormatVersion)
+	}
+	if s.Manifest.OrganizationCount != len(s.Organizations) {
+		return nil, errors.Wrap(ErrInvalidBundle, "organization count does not match manifest")
+	}
+	return
+}
+
+// Organizations turns organizations of a snapshot or of registry responses back into datastore entities.
+// Responses do not carry Version, so every item gets 1; watchers compare organizations by content.
+func Organizations(responses []*entity.OrganizationResponse) (items []*entity.Organization) {
+	items = make([]*entity.Organization, 0, len(responses))
+	for _, o := range responses {
+		var certExpireTs *time.Time
+		if o.CertExpireTs != nil {
+			ts := time.Unix(*o.CertExpireTs, 0).UTC()
+			certExpireTs = &ts
+		}
+		items = append(items, &entity.Organization{
+			Id:    o.Id,
+			Name:  o.Name,
+			Label: o.Label,
+			Url:   o.Url,
+			Type:  o.Type,
+			OrganizationKey: entity.OrganizationKey{
+				PublicKey:    o.PublicKey,
+				Algorithm:    o.KeyAlgorithm,
+				Fingerprint:  o.KeyFingerprint,
+				Certificate:  o.Certificate,
+				CertExpireTs: certExpireTs,
+			},
+			State:    o.State,
+			CreateTs: time.Unix(o.CreateTs, 0).UTC(),
+			UpdateTs: time.Unix(o.UpdateTs, 0).UTC(),
+			Version:  1,
+		})
+	}
+	return
+}
@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/signing"
+)
+
+type keyVerifier struct {
+	key crypto.PublicKey
+}
+
+func (v *keyVerifier) Verify(body []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return signing.ErrInvalid
+	}
+	alg, err := signing.Algorithm(v.key)
+	if err != nil {
+		return err
+	}
+	return signing.Verify(alg, v.key, body, sig)
+}
+
+func testSnapshot(count, formatVersion int) *entity.Snapshot {
+	s := &entity.Snapshot{
+		Manifest: entity.SnapshotManifest{
+			FormatVersion:     formatVersion,
+			CreateTs:          time.Now().Unix(),
+			RegistryUrl:       "https://registry.example.com",
+			OrganizationCount: count,
+		},
+	}
+	for _, id := range []int{1, 2} {
+		s.Organizations = append(s.Organizations, &entity.OrganizationResponse{
+			Id:    id,
+			Name:  "Edara",
+			Url:   "https://edara.example.com/receive",
+			State: entity.EntityStateEnabled,
+		})
+	}
+	return s
+}
+
+func TestOpen(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(s *entity.Snapshot) []byte {
+		raw, err := Create(key, s)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return raw
+	}
+	valid := create(testSnapshot(2, entity.SnapshotFormatVersion))
+	indented := &bytes.Buffer{}
+	if err = json.Indent(indented, valid, "", "    "); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		raw      []byte
+		verifier Verifier
+		wantErr  error
+	}{
+		{"valid", valid, &keyVerifier{key.Public()}, nil},
+		{"reformatted", indented.Bytes(), &keyVerifier{key.Public()}, nil},
+		{"tampered body", bytes.Replace(valid, []byte("edara.example.com"), []byte("evil.example.com"), 1), &keyVerifier{key.Public()}, signing.ErrInvalid},
+		{"wrong key", valid, &keyVerifier{otherKey.Public()}, signing.ErrInvalid},
+		{"count mismatch", create(testSnapshot(3, entity.SnapshotFormatVersion)), &keyVerifier{key.Public()}, ErrInvalidBundle},
+		{"unsupported format version", create(testSnapshot(2, entity.SnapshotFormatVersion+1)), &keyVerifier{key.Public()}, ErrUnsupportedFormat},
+		{"no signature", bytes.Replace(valid, []byte(`"signature":"`), []byte(`"signature":"","x":"`), 1), &keyVerifier{key.Public()}, ErrInvalidBundle},
+		{"not JSON", []byte("snapshot"), &keyVerifier{key.Public()}, ErrInvalidBundle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(tt.raw, tt.verifier)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || s != nil {
+					t.Fatalf("Open = %v, %v, want %v", s, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if len(s.Organizations) != 2 || s.Organizations[1].Id != 2 || s.Manifest.OrganizationCount != 2 {
+				t.Errorf("Open = %+v", s)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/config"
+)
+
+const watchInterval = 15 * time.Second
+
+var ErrNoClientCaCertificates = errors.New("no certificates found in client CA bundle")
+
+// only AEAD cipher suites with forward secrecy, TLS 1.3 suites are not configurable and always enabled
+var cipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+var curvePreferences = []tls.CurveID{
+	tls.X25519,
+	tls.CurveP256,
+	tls.CurveP384,
+}
+
+// Reloader keeps the TLS certificate and client CA bundle loaded from PEM files,
+// and replaces them without restart when Reload is called or files change on disk
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCaFile string
+	clientAuth   tls.ClientAuthType
+
+	mu      sync.RWMutex
+	config  *tls.Config
+	modTime time.Time
+}
+
+// NewReloader loads TLS files configured in conf, returns nil reloader when TLS is not configured
+func NewReloader(conf *config.Config) (r *Reloader, err error) {
+	clog := log.WithFields(log.Fields{
+		"method": "tlsconf.NewReloader",
+	})
+	if conf.TlsCertFile == "" {
+		return nil, nil
+	}
+	r = &Reloader{
+		certFile:     conf.TlsCertFile,
+		keyFile:      conf.TlsKeyFile,
+		clientCaFile: conf.TlsClientCaFile,
+		clientAuth:   tls.NoClientCert,
+	}
+	if conf.TlsClientCaFile != "" {
+		if conf.TlsClientAuthRequired {
+			r.clientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			r.clientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	err = r.Reload()
+	if err != nil {
+		eMsg := "error in initial TLS load"
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		r = nil
+		return
+	}
+	return
+}
+
+// ServerConfig returns tls.Config for the listener, every handshake picks up the latest loaded files.
+// nextProtos are offered in ALPN, gRPC needs "h2" there since clients reject connections that negotiate none.
+func (r *Reloader) ServerConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			if len(nextProtos) == 0 {
+				return r.config, nil
+			}
+			c := r.config.Clone()
+			c.NextProtos = nextProtos
+			return c, nil
+		},
+	}
+}
+
+// MutualTLS reports whether client certificates are verified
+func (r *Reloader) MutualTLS() bool {
+	return r.clientAuth != tls.NoClientCert
+}
+
+// Reload re-reads certificate, key and client CA bundle, on error previously loaded files stay in use
+func (r *Reloader) Reload() (err error) {
+	clog := log.WithFields(log.Fields{
+		"method": "tlsconf.Reloader.Reload",
+		"cert":   r.certFile,
+	})
+	modTime := r.latestModTime()
+	var cert tls.Certificate
+	cert, err = tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		eMsg := "error loading TLS certificate and key"
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		return
+	}
+	c := &tls.Config{
+		Certificates:     []tls.Certificate{cert},
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     cipherSuites,
+		CurvePreferences: curvePreferences,
+		ClientAuth:       r.clientAuth,
+	}
+	if r.clientCaFile != "" {
+		c.ClientCAs, err = loadCertPool(r.clientCaFile)
+		if err != nil {
+			eMsg := "error loading client CA bundle"
+			clog.WithError(err).Error(eMsg)
+			err = errors.Wrap(err, eMsg)
+			return
+		}
+	}
+	r.mu.Lock()
+	r.config = c
+	r.modTime = modTime
+	r.mu.Unlock()
+	if cert.Leaf == nil && len(cert.Certificate) > 0 {
+		cert.Leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+	if cert.Leaf != nil {
+		clog = clog.WithFields(log.Fields{
+			"subject":   cert.Leaf.Subject.CommonName,
+			"not-after": cert.Leaf.NotAfter,
+		})
+	}
+	clog.Info("TLS certificate loaded")
+	return
+}
+
+// Watch polls files for modification and reloads them until quit is closed
+func (r *Reloader) Watch(quit chan interface{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			r.mu.RLock()
+			loaded := r.modTime
+			r.mu.RUnlock()
+			if r.latestModTime().After(loaded) {
+				log.WithField("cert", r.certFile).Info("TLS files changed, reloading")
+				_ = r.Reload()
+			}
+		}
+	}
+}
+
+func (r *Reloader) latestModTime() (latest time.Time) {
+	for _, f := range []string{r.certFile, r.keyFile, r.clientCaFile} {
+		if f == "" {
+			continue
+		}
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return
+}
+
+func loadCertPool(source string) (pool *x509.CertPool, err error) {
+	var raw []byte
+	raw, err = ioutil.ReadFile(source)
+	if err != nil {
+		return
+	}
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, ErrNoClientCaCertificates
+	}
+	return
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"ykjam/doc-registry-go/config"
+)
+
+const (
+	instrumentationName = "ykjam/doc-registry-go"
+	serviceName         = "doc-registry"
+
+	AttrStatement = attribute.Key("db.statement.name")
+)
+
+// Setup installs global tracer provider exporting spans over OTLP/HTTP when tracing is enabled.
+// Otherwise the global no-op provider stays in place and spans cost next to nothing.
+// Returned shutdown flushes pending spans and must be called before exit.
+func Setup(ctx context.Context, conf *config.Config) (shutdown func(context.Context) error, err error) {
+	clog := log.WithFields(log.Fields{
+		"method": "tracing.Setup",
+	})
+	shutdown = func(context.Context) error { return nil }
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !conf.TracingEnabled {
+		return
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(conf.TracingEndpoint),
+	}
+	if conf.TracingInsecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	var exporter *otlptrace.Exporter
+	exporter, err = otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		eMsg := "error creating OTLP exporter"
+		clog.WithError(err).Error(eMsg)
+		err = errors.Wrap(err, eMsg)
+		return
+	}
+	ratio := conf.TracingSampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	clog.WithFields(log.Fields{
+		"endpoint": conf.TracingEndpoint,
+		"ratio":    ratio,
+	}).Info("tracing enabled")
+	return provider.Shutdown, nil
+}
+
+// Start starts span named after the layer and method, e.g. "api.OrganizationList"
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Statement names the SQL constant executed in span
+func Statement(name string) attribute.KeyValue {
+	return AttrStatement.String(name)
+}
+
+// End records err, if any, and ends span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
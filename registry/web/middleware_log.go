@@ -0,0 +1,71 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/logging"
+)
+
+const HeaderRequestId = "X-Request-ID"
+
+// RequestIdMiddleware takes X-Request-ID from request or generates one, echoes it in response
+// and carries it in request context, so that every layer logs it via logging.FromContext
+func (s *Server) RequestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestId)
+		if !logging.ValidRequestId(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(HeaderRequestId, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestId(r.Context(), id)))
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (n int, err error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err = sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return
+}
+
+// AccessLogMiddleware writes one entry per request to access logger, must run after RequestIdMiddleware
+func (s *Server) AccessLogMiddleware(logger *log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if logger == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sr := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(sr, r)
+			logger.WithFields(log.Fields{
+				"request-id":  logging.RequestId(r.Context()),
+				"remote-addr": s.remoteAddress(r),
+				"method":      r.Method,
+				"uri":         r.RequestURI,
+				"proto":       r.Proto,
+				"status":      sr.status,
+				"bytes":       sr.bytes,
+				"duration-ms": float64(time.Since(start).Microseconds()) / 1000,
+				"user-agent":  r.UserAgent(),
+			}).Info("access")
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/logging"
+)
+
+// legacyListPath lists organizations on POST as well, which read-only registries still serve
+const legacyListPath = "/api/organization"
+
+// ReadOnlyMiddleware rejects every routed request that could change data with 405 and code READ_ONLY,
+// for registries serving data of another registry
+func (s *Server) ReadOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions,
+			r.Method == http.MethodPost && r.URL.Path == legacyListPath:
+			next.ServeHTTP(w, r)
+			return
+		}
+		clog := logging.FromContext(r.Context()).WithFields(log.Fields{
+			"remote-addr": s.remoteAddress(r),
+			"uri":         r.RequestURI,
+			"method":      r.Method,
+		}).WithContext(r.Context())
+		clog.Warn("write rejected by read-only registry")
+		w.Header().Set("Allow", "GET, HEAD")
+		s.sendResponseByError(w, api.ErrReadOnly, clog)
+	})
+}
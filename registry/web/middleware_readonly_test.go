@@ -0,0 +1,57 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/ratelimit"
+)
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	s := NewServer(api.NewAPIController(datastore.NewReadOnlyAccess()), config.NewStaticStore(&config.Config{}), ratelimit.New())
+	r := mux.NewRouter()
+	s.RegisterRoutes(r, s.RequestIdMiddleware, s.ReadOnlyMiddleware)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/api/v1/organizations", http.StatusOK},
+		{http.MethodGet, "/api/organization", http.StatusOK},
+		{http.MethodPost, "/api/organization", http.StatusOK},
+		{http.MethodPost, "/api/v1/organizations", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/api/v1/organizations/1/state", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/organization/add", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/key-challenges", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/registrations", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusMethodNotAllowed {
+				return
+			}
+			var resp struct {
+				Data api.ResponseErrorCodeAndMessage `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Data.Code != api.AppCodeReadOnly {
+				t.Errorf("body %s, want code %s", w.Body, api.AppCodeReadOnly)
+			}
+			if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+				t.Errorf("Allow = %q", allow)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/ratelimit"
+	"ykjam/doc-registry-go/tracing"
+)
+
+// closedPortUrl returns database url nothing listens on, so that every query fails right away
+func closedPortUrl(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return "postgres://registry@" + addr + "/registry?connect_timeout=1"
+}
+
+// TestTracingSpans follows one request through the HTTP, api and datastore layers. The database is unreachable,
+// which still yields a datastore span, recorded with error.
+func TestTracingSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	}()
+
+	poolConf, err := pgxpool.ParseConfig(closedPortUrl(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolConf.LazyConnect = true
+	pool, err := pgxpool.ConnectConfig(context.Background(), poolConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	s := NewServer(api.NewAPIController(datastore.NewPgAccessWithPool(pool)), config.NewStaticStore(&config.Config{}), ratelimit.New())
+	r := mux.NewRouter()
+	s.RegisterRoutes(r, s.RequestIdMiddleware, s.TracingMiddleware)
+
+	const (
+		callerTraceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+		callerSpanId  = "00f067aa0ba902b7"
+	)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/organizations/7", nil)
+	req.Header.Set("traceparent", "00-"+callerTraceId+"-"+callerSpanId+"-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d while database is unreachable", w.Code, http.StatusInternalServerError)
+	}
+	if err = provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	httpSpan, ok := spans["GET /api/v1/organizations/{id:[0-9]+}"]
+	if !ok {
+		t.Fatalf("no HTTP span named after route among %d spans", len(spans))
+	}
+	if got := httpSpan.SpanContext.TraceID().String(); got != callerTraceId {
+		t.Errorf("trace id = %s, want %s of the caller", got, callerTraceId)
+	}
+	if got := httpSpan.Parent.SpanID().String(); got != callerSpanId || !httpSpan.Parent.IsRemote() {
+		t.Errorf("HTTP span parent = %s (remote %v), want caller span %s", got, httpSpan.Parent.IsRemote(), callerSpanId)
+	}
+	// every span is the child of the one before
+	chain := []tracetest.SpanStub{httpSpan}
+	for _, name := range []string{"api.OrganizationGet", "api.organizationForChange", "PgAccess.OrganizationById", "pgxpool.Acquire"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("no %s span among %d spans", name, len(spans))
+		}
+		parent := chain[len(chain)-1]
+		if span.Parent.SpanID() != parent.SpanContext.SpanID() {
+			t.Errorf("span %s parent = %s, want %s of %s", name, span.Parent.SpanID(), parent.SpanContext.SpanID(), parent.Name)
+		}
+		if span.SpanContext.TraceID() != httpSpan.SpanContext.TraceID() {
+			t.Errorf("span %s is in trace %s, want %s", name, span.SpanContext.TraceID(), httpSpan.SpanContext.TraceID())
+		}
+		chain = append(chain, span)
+	}
+	apiSpan, dbSpan := chain[1], chain[3]
+
+	if !hasAttribute(httpSpan.Attributes, attribute.String("http.route", "/api/v1/organizations/{id:[0-9]+}")) ||
+		!hasAttribute(httpSpan.Attributes, attribute.Int("http.response.status_code", http.StatusInternalServerError)) {
+		t.Errorf("HTTP span attributes = %v", httpSpan.Attributes)
+	}
+	if !hasAttribute(dbSpan.Attributes, tracing.Statement("sqlOrganizationById")) {
+		t.Errorf("datastore span attributes = %v, want statement name", dbSpan.Attributes)
+	}
+	for _, span := range []tracetest.SpanStub{httpSpan, apiSpan, dbSpan} {
+		if span.Status.Code != codes.Error {
+			t.Errorf("span %s status = %v, want error", span.Name, span.Status.Code)
+		}
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
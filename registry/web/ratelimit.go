@@ -0,0 +1,50 @@
+package web
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/ratelimit"
+)
+
+// LimitMiddleware rejects clients exceeding configured request rate with 429 and caps request body size.
+// It runs before ClientCertificateMiddleware, so that a client over the limit costs no organization lookup.
+func (s *Server) LimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf := s.conf.Get()
+		clog := logging.FromContext(r.Context()).WithFields(log.Fields{
+			"remote-addr": s.remoteAddress(r),
+			"uri":         r.RequestURI,
+			"method":      r.Method,
+		}).WithContext(r.Context())
+		// health probes come from orchestrator and load balancers and must not be throttled
+		if conf.RateLimitPerSecond > 0 && !strings.HasPrefix(r.URL.Path, "/health/") {
+			key := ratelimit.ClientKey(verifiedClientCertificate(r), s.remoteAddress(r))
+			wait := s.limiter.Reserve(key, rate.Limit(conf.RateLimitPerSecond), conf.RateLimitBurst)
+			if wait > 0 {
+				clog.WithFields(log.Fields{
+					"key":  key,
+					"wait": wait,
+				}).Warn("rate limit exceeded")
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				s.sendResponseByError(w, api.ErrTooManyRequests, clog)
+				return
+			}
+		}
+		maxBody := conf.MaxRequestBody()
+		if r.ContentLength > maxBody {
+			clog.WithField("content-length", r.ContentLength).Warn("request body too large")
+			s.sendResponseByError(w, api.ErrRequestTooLarge, clog)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/ratelimit"
+)
+
+// lookupAccess counts organization lookups of client certificates, other datastore calls are not expected
+type lookupAccess struct {
+	datastore.Access
+	lookups int32
+}
+
+func (a *lookupAccess) OrganizationByName(ctx context.Context, name string) (item *entity.Organization, err error) {
+	atomic.AddInt32(&a.lookups, 1)
+	return &entity.Organization{Id: 1, Name: name, State: entity.EntityStateEnabled}, nil
+}
+
+func TestLimitBeforeClientCertificateLookup(t *testing.T) {
+	access := &lookupAccess{}
+	conf := &config.Config{RateLimitPerSecond: 0.001, RateLimitBurst: 2}
+	s := NewServer(api.NewAPIController(access), config.NewStaticStore(conf), ratelimit.New())
+	r := mux.NewRouter()
+	s.RegisterRoutes(r, s.RequestIdMiddleware, s.LimitMiddleware, s.ClientCertificateMiddleware)
+
+	send := func(commonName, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/nothing", nil)
+		req.RemoteAddr = remoteAddr
+		cert := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: commonName}}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	// one node behind changing addresses shares the bucket of its certificate name
+	for i, addr := range []string{"192.0.2.1:1000", "192.0.2.2:1000"} {
+		if code := send("Edara 1", addr); code != http.StatusNotFound {
+			t.Fatalf("request %d: status = %d, want %d", i, code, http.StatusNotFound)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if code := send("Edara 1", "192.0.2.3:1000"); code != http.StatusTooManyRequests {
+			t.Fatalf("request over limit: status = %d, want %d", code, http.StatusTooManyRequests)
+		}
+	}
+	if n := atomic.LoadInt32(&access.lookups); n != 2 {
+		t.Errorf("organization looked up %d times, want 2: requests over the limit must not reach the datastore", n)
+	}
+	if code := send("Edara 2", "192.0.2.3:1000"); code != http.StatusNotFound {
+		t.Errorf("other certificate from the same address: status = %d, want %d", code, http.StatusNotFound)
+	}
+}
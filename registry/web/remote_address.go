@@ -0,0 +1,85 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// GetRemoteAddress resolves client IP address. Forwarding headers are honored only when the request comes
+// from a trusted proxy; the chain is walked from the right and the first address not in trusted is the client.
+// Forwarded (RFC 7239) takes precedence over X-Forwarded-For, which takes precedence over X-Real-IP.
+func GetRemoteAddress(r *http.Request, trusted []*net.IPNet) string {
+	peer := parseAddress(r.RemoteAddr)
+	if peer == nil {
+		return r.RemoteAddr
+	}
+	if !isTrusted(peer, trusted) {
+		return peer.String()
+	}
+	chain := forwardedChain(r)
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseAddress(chain[i])
+		if ip == nil {
+			// obfuscated or malformed hop, nothing left of it can be trusted
+			break
+		}
+		client = ip
+		if !isTrusted(ip, trusted) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedChain returns hop addresses ordered from the original client to the nearest proxy
+func forwardedChain(r *http.Request) (chain []string) {
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		for _, v := range values {
+			for _, element := range strings.Split(v, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+					if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+						chain = append(chain, strings.Trim(kv[1], `"`))
+					}
+				}
+			}
+		}
+		return
+	}
+	if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		for _, v := range values {
+			for _, hop := range strings.Split(v, ",") {
+				chain = append(chain, strings.TrimSpace(hop))
+			}
+		}
+		return
+	}
+	if v := r.Header.Get("X-Real-IP"); v != "" {
+		chain = append(chain, strings.TrimSpace(v))
+	}
+	return
+}
+
+// parseAddress accepts IPv4, IPv6, optionally bracketed and optionally with port
+func parseAddress(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if i := strings.IndexByte(s, '%'); i >= 0 {
+		s = s[:i]
+	}
+	return net.ParseIP(s)
+}
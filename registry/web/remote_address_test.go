@@ -0,0 +1,69 @@
+package web
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"ykjam/doc-registry-go/config"
+)
+
+func TestGetRemoteAddress(t *testing.T) {
+	trusted := config.NewStaticStore(&config.Config{
+		TrustedProxies: []string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.1"},
+	}).Get().TrustedProxyNets()
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{"untrusted peer, headers ignored", "203.0.113.5:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.5"},
+		{"trusted peer without headers", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"X-Forwarded-For", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1"},
+		{"X-Forwarded-For spoofed left of client", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.2, 10.0.0.2"}}, "198.51.100.2"},
+		{"X-Forwarded-For of trusted proxies only", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"X-Forwarded-For in several headers", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.1.1.1", "198.51.100.3"}}, "198.51.100.3"},
+		{"X-Forwarded-For with port", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.4:8080"}}, "198.51.100.4"},
+		{"X-Forwarded-For IPv6", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"2a00:1450::5"}}, "2a00:1450::5"},
+		{"X-Forwarded-For malformed hop", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.5, garbage"}}, "10.0.0.1"},
+		{"X-Real-IP", "10.0.0.1:1234", map[string][]string{"X-Real-Ip": {"198.51.100.6"}}, "198.51.100.6"},
+		{"X-Real-IP with port", "10.0.0.1:1234", map[string][]string{"X-Real-Ip": {"198.51.100.6:5555"}}, "198.51.100.6"},
+		{"X-Forwarded-For over X-Real-IP", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.7"}, "X-Real-Ip": {"198.51.100.8"}}, "198.51.100.7"},
+		{"Forwarded", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=198.51.100.9"}}, "198.51.100.9"},
+		{"Forwarded with other parameters", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=198.51.100.10;proto=https;by=10.0.0.1, for=10.0.0.2"}}, "198.51.100.10"},
+		{"Forwarded quoted, case-insensitive", "10.0.0.1:1234", map[string][]string{"Forwarded": {`For="198.51.100.11"`}}, "198.51.100.11"},
+		{"Forwarded bracketed IPv6 with port", "10.0.0.1:1234", map[string][]string{"Forwarded": {`for="[2a00:1450::1]:4711"`}}, "2a00:1450::1"},
+		{"Forwarded over X-Forwarded-For", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=198.51.100.12"}, "X-Forwarded-For": {"198.51.100.13"}}, "198.51.100.12"},
+		{"Forwarded spoofed left of client", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=1.1.1.1, for=198.51.100.14", "for=10.0.0.2"}}, "198.51.100.14"},
+		{"Forwarded obfuscated client", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=_hidden, for=198.51.100.15"}}, "198.51.100.15"},
+		{"Forwarded obfuscated nearest hop", "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=198.51.100.16, for=unknown"}}, "10.0.0.1"},
+		{"trusted IPv6 peer", "[2001:db8::1]:443", map[string][]string{"X-Forwarded-For": {"198.51.100.17"}}, "198.51.100.17"},
+		{"untrusted IPv6 peer", "[2a00:1450::2]:443", map[string][]string{"X-Forwarded-For": {"198.51.100.18"}}, "2a00:1450::2"},
+		{"IPv6 peer with zone", "[fe80::1%eth0]:80", nil, "fe80::1"},
+		{"trusted single address", "192.0.2.1:80", map[string][]string{"X-Forwarded-For": {"198.51.100.19"}}, "198.51.100.19"},
+		{"address next to trusted single address", "192.0.2.2:80", map[string][]string{"X-Forwarded-For": {"198.51.100.20"}}, "192.0.2.2"},
+		{"peer without port", "10.0.0.1", map[string][]string{"X-Forwarded-For": {"198.51.100.21"}}, "198.51.100.21"},
+		{"peer not an address", "pipe", map[string][]string{"X-Forwarded-For": {"198.51.100.22"}}, "pipe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, values := range tt.headers {
+				for _, v := range values {
+					r.Header.Add(k, v)
+				}
+			}
+			if got := GetRemoteAddress(r, trusted); got != tt.want {
+				t.Errorf("GetRemoteAddress = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.23")
+	if got := GetRemoteAddress(r, nil); got != "10.0.0.1" {
+		t.Errorf("GetRemoteAddress without trusted proxies = %s, want the peer", got)
+	}
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	PrefixV1        = "/api/v1"
+	organizationsV1 = PrefixV1 + "/organizations"
+)
+
+// deprecatedAlias serves legacy route with h, marking responses as deprecated in favour of successor
+func (s *Server) deprecatedAlias(successor string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deprecated(w, successor)
+		h(w, r)
+	}
+}
+
+// withMiddlewares wraps h in middlewares, the first one outermost as with mux.Router.Use
+func withMiddlewares(h http.Handler, middlewares []mux.MiddlewareFunc) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// RegisterRoutes adds all API routes to r behind middlewares, requests with unsupported method get 405.
+// gorilla/mux runs r.Use middlewares for matched routes only, so not found and method not allowed
+// handlers are wrapped in them here.
+func (s *Server) RegisterRoutes(r *mux.Router, middlewares ...mux.MiddlewareFunc) {
+	r.Use(middlewares...)
+	r.NotFoundHandler = withMiddlewares(http.HandlerFunc(s.HandleNotFound), middlewares)
+	r.MethodNotAllowedHandler = withMiddlewares(http.HandlerFunc(s.HandleMethodNotAllowed), middlewares)
+
+	r.HandleFunc("/health/live", s.HandleLive).Methods(http.MethodGet)
+	r.HandleFunc("/health/ready", s.HandleReady).Methods(http.MethodGet)
+	r.HandleFunc("/metrics", s.HandleMetrics).Methods(http.MethodGet)
+
+	v1 := r.PathPrefix(PrefixV1).Subrouter()
+	v1.HandleFunc("/openapi.yaml", s.HandleOpenAPISpec).Methods(http.MethodGet)
+	v1.HandleFunc("/organizations", s.HandleOrganizationList).Methods(http.MethodGet)
+	v1.HandleFunc("/organizations", s.HandleOrganizationAdd).Methods(http.MethodPost)
+	v1.HandleFunc("/organizations/{id:[0-9]+}", s.HandleOrganizationGet).Methods(http.MethodGet)
+	v1.HandleFunc("/organizations/{id:[0-9]+}", s.HandleOrganizationUpdate).Methods(http.MethodPut)
+	v1.HandleFunc("/organizations/{id:[0-9]+}/key", s.HandleOrganizationKeyChange).Methods(http.MethodPut)
+	v1.HandleFunc("/organizations/{id:[0-9]+}/state", s.HandleOrganizationChangeState).Methods(http.MethodPut)
+	v1.HandleFunc("/key-challenges", s.HandleKeyChallengeCreate).Methods(http.MethodPost)
+	v1.HandleFunc("/keys/{fingerprint}", s.HandleOrganizationKeyByFingerprint).Methods(http.MethodGet)
+	v1.HandleFunc("/organizations/{id:[0-9]+}/keys/{fingerprint}/status", s.HandleKeyValidity).Methods(http.MethodGet)
+	v1.HandleFunc("/signing-key", s.HandleSigningKey).Methods(http.MethodGet)
+	v1.HandleFunc("/mirror/status", s.HandleMirrorStatus).Methods(http.MethodGet)
+	v1.HandleFunc("/organizations/{id:[0-9]+}/certificates", s.HandleCertificateIssue).Methods(http.MethodPost)
+	v1.HandleFunc("/organizations/{id:[0-9]+}/certificates", s.HandleCertificateList).Methods(http.MethodGet)
+	v1.HandleFunc("/certificates/{serial}/revoke", s.HandleCertificateRevoke).Methods(http.MethodPut)
+	v1.HandleFunc("/ca/certificate", s.HandleCaCertificate).Methods(http.MethodGet)
+	v1.HandleFunc("/ca/crl", s.HandleCaCrl).Methods(http.MethodGet)
+	v1.HandleFunc("/registrations", s.HandleRegistrationAdd).Methods(http.MethodPost)
+	v1.HandleFunc("/registrations", s.HandleRegistrationList).Methods(http.MethodGet)
+	v1.HandleFunc("/registrations/{id:[0-9]+}", s.HandleRegistrationGet).Methods(http.MethodGet)
+	v1.HandleFunc("/registrations/{id:[0-9]+}/approve", s.HandleRegistrationApprove).Methods(http.MethodPut)
+	v1.HandleFunc("/registrations/{id:[0-9]+}/reject", s.HandleRegistrationReject).Methods(http.MethodPut)
+	v1.HandleFunc("/admin/tokens", s.HandleAdminTokenCreate).Methods(http.MethodPost)
+	v1.HandleFunc("/audit-log", s.HandleAuditLogList).Methods(http.MethodGet)
+	v1.HandleFunc("/admin/probes", s.HandleOrganizationProbeList).Methods(http.MethodGet)
+
+	// legacy routes, kept as deprecated aliases
+	r.HandleFunc("/api/organization", s.deprecatedAlias(organizationsV1, s.HandleOrganizationList)).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/api/organization/add", s.deprecatedAlias(organizationsV1, s.HandleOrganizationAdd)).Methods(http.MethodPost)
+	r.HandleFunc("/api/organization/update", s.deprecatedAlias(organizationsV1+"/{id}", s.HandleOrganizationUpdate)).Methods(http.MethodPost)
+	r.HandleFunc("/api/organization/key", s.deprecatedAlias(organizationsV1+"/{id}/key", s.HandleOrganizationKeyChange)).Methods(http.MethodPost)
+	r.HandleFunc("/api/organization/state", s.deprecatedAlias(organizationsV1+"/{id}/state", s.HandleOrganizationChangeState)).Methods(http.MethodPost)
+	r.HandleFunc("/api/admin/token", s.deprecatedAlias(PrefixV1+"/admin/tokens", s.HandleAdminTokenCreate)).Methods(http.MethodPost)
+	r.HandleFunc("/api/audit", s.deprecatedAlias(PrefixV1+"/audit-log", s.HandleAuditLogList)).Methods(http.MethodGet)
+}
@@ -2,109 +2,103 @@ package web
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
 	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/config"
+	"ykjam/doc-registry-go/logging"
+	"ykjam/doc-registry-go/ratelimit"
 )
 
-func GetRemoteAddress(r *http.Request) string {
-	if val := r.Header.Get("X-Forwarded-For"); val != "" {
-		return strings.Split(val, ":")[0]
-	} else if val := r.Header.Get("X-Real-IP"); val != "" {
-		return strings.Split(val, ":")[0]
-	} else {
-		return strings.Split(r.RemoteAddr, ":")[0]
-	}
-}
+// HeaderSignature carries base64 registry signature over the response body, as client.Verifier expects
+const HeaderSignature = "X-Registry-Signature"
 
 type Server struct {
-	c *api.APIController
+	c        *api.APIController
+	conf     *config.Store
+	limiter  *ratelimit.Limiter
+	draining int32
 }
 
-type httpPostWithLog func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry)
+type httpWithLog func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry)
 
-func NewServer(apiController *api.APIController) *Server {
+func NewServer(apiController *api.APIController, conf *config.Store, limiter *ratelimit.Limiter) *Server {
 	return &Server{
-		c: apiController,
+		c:       apiController,
+		conf:    conf,
+		limiter: limiter,
 	}
 }
 
-func (s *Server) handleHttpPostOrGetWithLog(handleName string, w http.ResponseWriter, r *http.Request, f httpPostWithLog) {
+func (s *Server) remoteAddress(r *http.Request) string {
+	return GetRemoteAddress(r, s.conf.Get().TrustedProxyNets())
+}
+
+// handleHttpWithLog calls f with request scoped logger, allowed methods are matched by router
+func (s *Server) handleHttpWithLog(handleName string, w http.ResponseWriter, r *http.Request, f httpWithLog) {
 	ctx := r.Context()
-	clog := log.WithFields(log.Fields{
-		"remote-addr": GetRemoteAddress(r),
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"remote-addr": s.remoteAddress(r),
 		"uri":         r.RequestURI,
 		"method":      r.Method,
 		"handle":      handleName,
-	}).WithContext(ctx)
-	if r.Method == http.MethodPost || r.Method == http.MethodGet {
-		f(ctx, w, r, clog)
-	} else {
-		clog.Error("invalid request, method not allowed")
-		s.sendResponseByCode(w, api.ErrorCodeForbidden, clog)
-	}
+	})
+	f(ctx, w, r, clog)
 }
 
-func (s *Server) sendResponseByCode(w http.ResponseWriter, errCode int, clog *log.Entry) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(errCode)
-	var errMessage string
-	switch errCode {
-	case api.ErrorCodeOK:
-		errMessage = api.ErrorMessageOK
-	case api.ErrorCodeNotFound:
-		errMessage = api.ErrorMessageNotFound
-	case api.ErrorCodeInternalServerError:
-		errMessage = api.ErrorMessageInternalServerError
-	}
-	var resp api.GeneralResponse
-	if errCode == api.ErrorCodeOK {
-		resp = api.GeneralResponse{
-			Success: true,
-		}
-	} else {
-		resp = api.GeneralResponse{
-			Success: false,
-			Data: api.ResponseErrorCodeAndMessage{
-				ErrorCode:    errCode,
-				ErrorMessage: errMessage,
-			},
-		}
-	}
-	err := json.NewEncoder(w).Encode(resp)
-	if err != nil {
-		clog.WithError(err).Error(fmt.Sprint(" data: ", resp))
-		http.Error(w, api.ErrorMessageInternalServerError, api.ErrorCodeInternalServerError)
+// pathId parses positive integer path variable {id}
+func pathId(r *http.Request) (id int, err error) {
+	id, err = strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, api.ErrNotFound
 	}
+	return
 }
 
+// deprecated marks response of legacy route, pointing clients to its /api/v1/ successor
+func deprecated(w http.ResponseWriter, successor string) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+}
+
+func (s *Server) HandleNotFound(w http.ResponseWriter, r *http.Request) {
+	s.handleHttpWithLog("HandleNotFound ", w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		clog.Warn("route not found")
+		s.sendResponseByError(w, api.ErrNotFound, clog)
+	})
+}
+
+func (s *Server) HandleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	s.handleHttpWithLog("HandleMethodNotAllowed ", w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		clog.Warn("invalid request, method not allowed")
+		s.sendResponseByError(w, api.ErrMethodNotAllowed, clog)
+	})
+}
+
+// sendResponseByError sends status code, message, application code and details of api.Error found in err,
+// anything else is internal server error. Request id is taken from response header set by RequestIdMiddleware.
 func (s *Server) sendResponseByError(w http.ResponseWriter, err error, clog *log.Entry) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	var errCode int
-	var errMessage string
-
-	switch err {
-	case api.ErrInternalServerError:
-		errCode = api.ErrorCodeInternalServerError
-		errMessage = api.ErrorMessageInternalServerError
-	case api.ErrBadRequest:
-		errCode = api.ErrorCodeBadRequest
-		errMessage = api.ErrorMessageBadRequest
-	case api.ErrNotFound:
-		errCode = api.ErrorCodeNotFound
-		errMessage = api.ErrorMessageNotFound
-	}
-	w.WriteHeader(errCode)
+	apiErr := api.AsError(err)
+	w.WriteHeader(apiErr.Code)
 	resp := api.GeneralResponse{
 		Success: false,
 		Data: api.ResponseErrorCodeAndMessage{
-			ErrorCode:    errCode,
-			ErrorMessage: errMessage,
+			ErrorCode:    apiErr.Code,
+			ErrorMessage: apiErr.Message,
+			Code:         apiErr.AppCode,
+			Details:      apiErr.Details,
+			RequestId:    w.Header().Get(HeaderRequestId),
 		},
 	}
 	err = json.NewEncoder(w).Encode(resp)
@@ -127,3 +121,75 @@ func (s *Server) sendResponseOKWithData(w http.ResponseWriter, data interface{},
 		http.Error(w, api.ErrorMessageInternalServerError, api.ErrorCodeInternalServerError)
 	}
 }
+
+// sendResponseOKWithETag sends data with strong ETag of the encoded body,
+// answers 304 without body when client already has the same representation
+func (s *Server) sendResponseOKWithETag(w http.ResponseWriter, r *http.Request, data interface{}, clog *log.Entry) {
+	resp := api.GeneralResponse{
+		Success: true,
+		Data:    data,
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		clog.WithError(err).Error(fmt.Sprint(" data: ", resp))
+		s.sendResponseByError(w, api.ErrInternalServerError, clog)
+		return
+	}
+	body = append(body, '\n')
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	s.writeSigned(w, body, clog)
+}
+
+// sendResponseOKSigned sends data that caches may keep for maxAge, the signature lets verifiers trust it
+// wherever it was cached
+func (s *Server) sendResponseOKSigned(w http.ResponseWriter, data interface{}, maxAge time.Duration, clog *log.Entry) {
+	resp := api.GeneralResponse{
+		Success: true,
+		Data:    data,
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		clog.WithError(err).Error(fmt.Sprint(" data: ", resp))
+		s.sendResponseByError(w, api.ErrInternalServerError, clog)
+		return
+	}
+	body = append(body, '\n')
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	s.writeSigned(w, body, clog)
+}
+
+// writeSigned writes JSON body with registry signature over it in HeaderSignature, when signing is configured
+func (s *Server) writeSigned(w http.ResponseWriter, body []byte, clog *log.Entry) {
+	signature, err := s.c.SignResponse(body)
+	if err != nil {
+		clog.WithError(err).Error("error in api.SignResponse()")
+		s.sendResponseByError(w, api.ErrInternalServerError, clog)
+		return
+	}
+	if signature != "" {
+		w.Header().Set(HeaderSignature, signature)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(api.ErrorCodeOK)
+	_, err = w.Write(body)
+	if err != nil {
+		clog.WithError(err).Warn("error writing response")
+	}
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
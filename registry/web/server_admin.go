@@ -0,0 +1,43 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func (s *Server) HandleAdminTokenCreate(w http.ResponseWriter, r *http.Request) {
+	h := "HandleAdminTokenCreate "
+	s.handleHttpWithAuth(h, entity.AdminPermissionRead, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		req := &entity.AdminTokenCreateRequest{}
+		err := decodeJsonBody(r, req)
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request body")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.AdminTokenCreate(ctx, actor, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.AdminTokenCreate()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleAuditLogList(w http.ResponseWriter, r *http.Request) {
+	h := "HandleAuditLogList "
+	s.handleHttpWithAuth(h, entity.AdminPermissionRead, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		items, err := s.c.AuditLogList(ctx)
+		if err != nil {
+			clog.WithError(err).Error("error in api.AuditLogList()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, items, clog)
+	})
+}
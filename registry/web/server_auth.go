@@ -0,0 +1,74 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+)
+
+type httpWithActor func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin)
+
+// authenticate resolves admin either from "Authorization: Bearer <token>" or from HTTP basic auth
+func (s *Server) authenticate(ctx context.Context, r *http.Request) (admin *entity.Admin, err error) {
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		return s.c.AdminAuthenticateToken(ctx, strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer ")))
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		return s.c.AdminAuthenticatePassword(ctx, username, password)
+	}
+	return nil, api.ErrUnauthorized
+}
+
+// handleHttpWithAuth authenticates caller and checks that its role grants permission before calling f
+func (s *Server) handleHttpWithAuth(handleName string, permission entity.AdminPermission, w http.ResponseWriter, r *http.Request, f httpWithActor) {
+	ctx := r.Context()
+	clog := logging.FromContext(ctx).WithFields(log.Fields{
+		"remote-addr": s.remoteAddress(r),
+		"uri":         r.RequestURI,
+		"method":      r.Method,
+		"handle":      handleName,
+	})
+	actor, err := s.authenticate(ctx, r)
+	if err != nil {
+		clog.WithError(err).Warn("authentication failed")
+		if errors.Is(err, api.ErrUnauthorized) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="registry", Basic realm="registry"`)
+		}
+		s.sendResponseByError(w, err, clog)
+		return
+	}
+	clog = clog.WithFields(log.Fields{
+		"actor":      actor.Username,
+		"actor-role": actor.Role,
+	})
+	err = s.c.Authorize(actor, permission)
+	if err != nil {
+		clog.WithField("permission", permission).Warn("permission denied")
+		s.sendResponseByError(w, err, clog)
+		return
+	}
+	f(ctx, w, r, clog, actor)
+}
+
+func decodeJsonBody(r *http.Request, v interface{}) (err error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return api.ErrRequestTooLarge
+		}
+		return api.ErrBadRequest
+	}
+	return nil
+}
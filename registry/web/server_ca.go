@@ -0,0 +1,105 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func (s *Server) HandleCaCertificate(w http.ResponseWriter, r *http.Request) {
+	h := "HandleCaCertificate "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		item, err := s.c.CaCertificateGet(ctx)
+		if err != nil {
+			clog.WithError(err).Error("error in api.CaCertificateGet()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithETag(w, r, item, clog)
+	})
+}
+
+func (s *Server) HandleCaCrl(w http.ResponseWriter, r *http.Request) {
+	h := "HandleCaCrl "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		crl, err := s.c.CaCrl(ctx)
+		if err != nil {
+			clog.WithError(err).Error("error in api.CaCrl()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(crl)
+		if err != nil {
+			clog.WithError(err).Warn("error writing response")
+		}
+	})
+}
+
+func (s *Server) HandleCertificateIssue(w http.ResponseWriter, r *http.Request) {
+	h := "HandleCertificateIssue "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		req := &entity.CertificateIssueRequest{}
+		err := decodeJsonBody(r, req)
+		if err == nil {
+			req.Id, err = pathId(r)
+		}
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.CertificateIssue(ctx, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.CertificateIssue()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleCertificateList(w http.ResponseWriter, r *http.Request) {
+	h := "HandleCertificateList "
+	s.handleHttpWithAuth(h, entity.AdminPermissionRead, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		id, err := pathId(r)
+		if err != nil {
+			clog.WithError(err).Warn("invalid organization id")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		items, err := s.c.CertificateList(ctx, id)
+		if err != nil {
+			clog.WithError(err).Error("error in api.CertificateList()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, items, clog)
+	})
+}
+
+func (s *Server) HandleCertificateRevoke(w http.ResponseWriter, r *http.Request) {
+	h := "HandleCertificateRevoke "
+	s.handleHttpWithAuth(h, entity.AdminPermissionKeyManage, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		req := &entity.CertificateRevokeRequest{}
+		err := decodeJsonBody(r, req)
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request body")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		req.Serial = mux.Vars(r)["serial"]
+		item, err := s.c.CertificateRevoke(ctx, actor, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.CertificateRevoke()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
@@ -0,0 +1,30 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func (s *Server) HandleKeyChallengeCreate(w http.ResponseWriter, r *http.Request) {
+	h := "HandleKeyChallengeCreate "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		req := &entity.KeyChallengeRequest{}
+		err := decodeJsonBody(r, req)
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request body")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.KeyChallengeCreate(ctx, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.KeyChallengeCreate()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+	"ykjam/doc-registry-go/logging"
+)
+
+type contextKey string
+
+const contextKeyClientOrganization contextKey = "client-organization"
+
+// ClientOrganization returns organization of the DMS node authenticated by TLS client certificate, nil if none
+func ClientOrganization(ctx context.Context) *entity.Organization {
+	item, _ := ctx.Value(contextKeyClientOrganization).(*entity.Organization)
+	return item
+}
+
+// verifiedClientCertificate returns leaf of the verified client certificate chain, nil without one
+func verifiedClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// ClientCertificateMiddleware resolves verified client certificate to registry organization and stores it in request context.
+// Requests with a verified certificate that does not belong to any enabled organization are rejected.
+func (s *Server) ClientCertificateMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cert := verifiedClientCertificate(r)
+		if cert == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		clog := logging.FromContext(ctx).WithFields(log.Fields{
+			"remote-addr": s.remoteAddress(r),
+			"uri":         r.RequestURI,
+			"subject":     cert.Subject.CommonName,
+			"serial":      cert.SerialNumber.String(),
+		})
+		item, err := s.c.OrganizationByClientCertificate(ctx, cert)
+		if err != nil {
+			clog.WithError(err).Warn("client certificate rejected")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, contextKeyClientOrganization, item)))
+	})
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/logging"
+)
+
+const readinessPingTimeout = 2 * time.Second
+
+// SetDraining makes readiness probe fail, it is called once shutdown starts
+func (s *Server) SetDraining() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// HandleLive reports that process is up, it does not depend on datastore
+func (s *Server) HandleLive(w http.ResponseWriter, r *http.Request) {
+	clog := logging.FromContext(r.Context()).WithField("handle", "HandleLive")
+	s.sendResponseOKWithData(w, nil, clog)
+}
+
+// HandleReady reports whether requests should be routed here: not draining and datastore reachable
+func (s *Server) HandleReady(w http.ResponseWriter, r *http.Request) {
+	clog := logging.FromContext(r.Context()).WithField("handle", "HandleReady")
+	if s.isDraining() {
+		s.sendResponseByError(w, api.ErrServiceUnavailable, clog)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readinessPingTimeout)
+	defer cancel()
+	if err := s.c.Ping(ctx); err != nil {
+		clog.WithError(err).Warn("datastore is not reachable")
+		s.sendResponseByError(w, api.ErrServiceUnavailable, clog)
+		return
+	}
+	s.sendResponseOKWithData(w, nil, clog)
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+func (s *Server) HandleSigningKey(w http.ResponseWriter, r *http.Request) {
+	h := "HandleSigningKey "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		item, err := s.c.SigningKeyGet(ctx)
+		if err != nil {
+			clog.WithError(err).Error("error in api.SigningKeyGet()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithETag(w, r, item, clog)
+	})
+}
+
+func (s *Server) HandleKeyValidity(w http.ResponseWriter, r *http.Request) {
+	h := "HandleKeyValidity "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		id, err := pathId(r)
+		if err != nil {
+			clog.WithError(err).Warn("invalid organization id")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		maxAge := s.conf.Get().KeyStatusMaxAge()
+		item, err := s.c.KeyValidityCheck(ctx, id, mux.Vars(r)["fingerprint"], maxAge)
+		if err != nil {
+			clog.WithError(err).Error("error in api.KeyValidityCheck()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKSigned(w, item, maxAge, clog)
+	})
+}
@@ -0,0 +1,22 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HandleMirrorStatus answers how far this mirror lags behind its upstream, the answer is not to be cached
+func (s *Server) HandleMirrorStatus(w http.ResponseWriter, r *http.Request) {
+	h := "HandleMirrorStatus "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		item, err := s.c.MirrorStatus(ctx)
+		if err != nil {
+			clog.WithError(err).Error("error in api.MirrorStatus()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKSigned(w, item, 0, clog)
+	})
+}
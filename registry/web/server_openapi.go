@@ -0,0 +1,22 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/openapi"
+)
+
+func (s *Server) HandleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	h := "HandleOpenAPISpec "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write(openapi.Spec)
+		if err != nil {
+			clog.WithError(err).Warn("error writing response")
+		}
+	})
+}
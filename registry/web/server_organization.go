@@ -4,18 +4,151 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
 )
 
+// requestId takes organization id from path on /api/v1/ routes, legacy routes carry it in request body
+func requestId(r *http.Request, bodyId *int) (err error) {
+	if _, ok := mux.Vars(r)["id"]; !ok {
+		return nil
+	}
+	*bodyId, err = pathId(r)
+	return
+}
+
 func (s *Server) HandleOrganizationList(w http.ResponseWriter, r *http.Request) {
 	h := "HandleOrganizationList "
-	s.handleHttpPostOrGetWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
 		items, err := s.c.OrganizationList(ctx)
 		if err != nil {
 			clog.WithError(err).Error("error in api.OrganizationList()")
 			s.sendResponseByError(w, err, clog)
 			return
 		}
-		s.sendResponseOKWithData(w, items, clog)
+		s.sendResponseOKWithETag(w, r, items, clog)
+	})
+}
+
+func (s *Server) HandleOrganizationGet(w http.ResponseWriter, r *http.Request) {
+	h := "HandleOrganizationGet "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		id, err := pathId(r)
+		if err != nil {
+			clog.WithError(err).Warn("invalid organization id")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationGet(ctx, id)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationGet()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithETag(w, r, item, clog)
+	})
+}
+
+func (s *Server) HandleOrganizationAdd(w http.ResponseWriter, r *http.Request) {
+	h := "HandleOrganizationAdd "
+	s.handleHttpWithAuth(h, entity.AdminPermissionOrganizationEdit, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		req := &entity.OrganizationAddRequest{}
+		err := decodeJsonBody(r, req)
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request body")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationAdd(ctx, actor, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationAdd()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleOrganizationUpdate(w http.ResponseWriter, r *http.Request) {
+	h := "HandleOrganizationUpdate "
+	s.handleHttpWithAuth(h, entity.AdminPermissionOrganizationEdit, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		req := &entity.OrganizationUpdateRequest{}
+		err := decodeJsonBody(r, req)
+		if err == nil {
+			err = requestId(r, &req.Id)
+		}
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationUpdate(ctx, actor, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationUpdate()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleOrganizationKeyChange(w http.ResponseWriter, r *http.Request) {
+	h := "HandleOrganizationKeyChange "
+	s.handleHttpWithAuth(h, entity.AdminPermissionKeyManage, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		req := &entity.OrganizationKeyChangeRequest{}
+		err := decodeJsonBody(r, req)
+		if err == nil {
+			err = requestId(r, &req.Id)
+		}
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationKeyChange(ctx, actor, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationKeyChange()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleOrganizationChangeState(w http.ResponseWriter, r *http.Request) {
+	h := "HandleOrganizationChangeState "
+	s.handleHttpWithAuth(h, entity.AdminPermissionKeyManage, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		req := &entity.OrganizationChangeStateRequest{}
+		err := decodeJsonBody(r, req)
+		if err == nil {
+			err = requestId(r, &req.Id)
+		}
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationChangeState(ctx, actor, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationChangeState()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleOrganizationKeyByFingerprint(w http.ResponseWriter, r *http.Request) {
+	h := "HandleOrganizationKeyByFingerprint "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		item, err := s.c.OrganizationKeyByFingerprint(ctx, mux.Vars(r)["fingerprint"])
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationKeyByFingerprint()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
 	})
 }
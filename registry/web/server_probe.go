@@ -0,0 +1,91 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+func (s *Server) HandleOrganizationProbeList(w http.ResponseWriter, r *http.Request) {
+	h := "HandleOrganizationProbeList "
+	s.handleHttpWithAuth(h, entity.AdminPermissionRead, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		items, err := s.c.OrganizationProbeList(ctx)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationProbeList()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, items, clog)
+	})
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeProbeMetrics writes probes in Prometheus text exposition format, gauges without value are skipped
+func writeProbeMetrics(buf *bytes.Buffer, items []*entity.OrganizationProbeResponse) {
+	gauges := []struct {
+		name, help string
+		value      func(p *entity.OrganizationProbeResponse) (float64, bool)
+	}{
+		{"registry_probe_up", "Whether organization endpoint answered the last probe",
+			func(p *entity.OrganizationProbeResponse) (float64, bool) {
+				if p.Reachable {
+					return 1, true
+				}
+				return 0, true
+			}},
+		{"registry_probe_latency_seconds", "Duration of the last probe",
+			func(p *entity.OrganizationProbeResponse) (float64, bool) {
+				return float64(p.LatencyMs) / 1000, true
+			}},
+		{"registry_probe_last_success_timestamp_seconds", "Time of the last probe organization endpoint answered",
+			func(p *entity.OrganizationProbeResponse) (float64, bool) {
+				if p.SuccessTs == nil {
+					return 0, false
+				}
+				return float64(*p.SuccessTs), true
+			}},
+		{"registry_probe_cert_expiry_timestamp_seconds", "Expiry time of organization endpoint TLS certificate",
+			func(p *entity.OrganizationProbeResponse) (float64, bool) {
+				if p.CertExpireTs == nil {
+					return 0, false
+				}
+				return float64(*p.CertExpireTs), true
+			}},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, p := range items {
+			if v, ok := g.value(p); ok {
+				fmt.Fprintf(buf, "%s{organization_id=\"%d\",organization=\"%s\"} %g\n", g.name, p.OrganizationId, metricLabelEscaper.Replace(p.OrganizationName), v)
+			}
+		}
+	}
+}
+
+// HandleMetrics serves probe results for Prometheus, scrapers authenticate with an admin token
+func (s *Server) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	h := "HandleMetrics "
+	s.handleHttpWithAuth(h, entity.AdminPermissionRead, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		items, err := s.c.OrganizationProbeList(ctx)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationProbeList()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		buf := &bytes.Buffer{}
+		writeProbeMetrics(buf, items)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(buf.Bytes())
+		if err != nil {
+			clog.WithError(err).Warn("error writing response")
+		}
+	})
+}
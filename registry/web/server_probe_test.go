@@ -0,0 +1,156 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"ykjam/doc-registry-go/api"
+	"ykjam/doc-registry-go/datastore"
+	"ykjam/doc-registry-go/entity"
+)
+
+// probeAccess keeps organizations and their probes in memory, other datastore calls are not expected
+type probeAccess struct {
+	datastore.Access
+	organizations []*entity.Organization
+
+	mu     sync.Mutex
+	probes map[int]*entity.OrganizationProbe
+}
+
+func (a *probeAccess) OrganizationList(ctx context.Context) (items []*entity.Organization, err error) {
+	return a.organizations, nil
+}
+
+func (a *probeAccess) OrganizationProbeSave(ctx context.Context, pTx pgx.Tx, item *entity.OrganizationProbe) (err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c := *item
+	a.probes[item.OrganizationId] = &c
+	return nil
+}
+
+func (a *probeAccess) OrganizationProbeList(ctx context.Context) (items []*entity.OrganizationProbe, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, o := range a.organizations {
+		if p, ok := a.probes[o.Id]; ok {
+			c := *p
+			c.OrganizationName = o.Name
+			items = append(items, &c)
+		}
+	}
+	return
+}
+
+func TestOrganizationProbeMetrics(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("probe method = %s, want HEAD", r.Method)
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer up.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	access := &probeAccess{
+		organizations: []*entity.Organization{
+			{Id: 1, Name: "up", Url: up.URL, State: entity.EntityStateEnabled},
+			{Id: 2, Name: "failing", Url: failing.URL, State: entity.EntityStateEnabled},
+			{Id: 3, Name: "refused", Url: refused.URL, State: entity.EntityStateEnabled},
+			{Id: 4, Name: "slow", Url: slow.URL, State: entity.EntityStateEnabled},
+			{Id: 5, Name: "disabled", Url: up.URL, State: entity.EntityStateDisabled},
+		},
+		probes: make(map[int]*entity.OrganizationProbe),
+	}
+	c := api.NewAPIController(access)
+	start := time.Now()
+	c.OrganizationProbeAll(context.Background(), api.NewProbeClient(300*time.Millisecond))
+	if d := time.Since(start); d > 3*time.Second {
+		t.Fatalf("probing took %s, timeout was not applied", d)
+	}
+
+	items, err := c.OrganizationProbeList(context.Background())
+	if err != nil {
+		t.Fatalf("OrganizationProbeList: %v", err)
+	}
+	byId := make(map[int]*entity.OrganizationProbeResponse)
+	for _, p := range items {
+		byId[p.OrganizationId] = p
+	}
+	if _, ok := byId[5]; ok || len(items) != 4 {
+		t.Fatalf("probed %d organizations, want the 4 enabled ones", len(items))
+	}
+	tests := []struct {
+		id         int
+		reachable  bool
+		statusCode int
+		errPart    string
+	}{
+		{1, true, http.StatusMethodNotAllowed, ""},
+		{2, false, http.StatusServiceUnavailable, "503"},
+		{3, false, 0, "refused"},
+		{4, false, 0, "Timeout"},
+	}
+	for _, tt := range tests {
+		p := byId[tt.id]
+		if p.Reachable != tt.reachable || p.StatusCode != tt.statusCode {
+			t.Errorf("organization %d: reachable=%v status=%d, want %v %d", tt.id, p.Reachable, p.StatusCode, tt.reachable, tt.statusCode)
+		}
+		if tt.errPart == "" && p.Error != "" || !strings.Contains(p.Error, tt.errPart) {
+			t.Errorf("organization %d: error %q, want it to contain %q", tt.id, p.Error, tt.errPart)
+		}
+		if (p.SuccessTs != nil) != tt.reachable {
+			t.Errorf("organization %d: success_ts=%v, want set only when reachable", tt.id, p.SuccessTs)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	writeProbeMetrics(buf, items)
+	metrics := buf.String()
+	for _, want := range []string{
+		`registry_probe_up{organization_id="1",organization="up"} 1`,
+		`registry_probe_up{organization_id="2",organization="failing"} 0`,
+		`registry_probe_up{organization_id="3",organization="refused"} 0`,
+		`registry_probe_up{organization_id="4",organization="slow"} 0`,
+		fmt.Sprintf(`registry_probe_last_success_timestamp_seconds{organization_id="1",organization="up"} %g`, float64(*byId[1].SuccessTs)),
+	} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Errorf("metrics miss %q:\n%s", want, metrics)
+		}
+	}
+	for _, id := range []int{2, 3, 4} {
+		unwanted := fmt.Sprintf(`registry_probe_last_success_timestamp_seconds{organization_id="%d"`, id)
+		if strings.Contains(metrics, unwanted) {
+			t.Errorf("metrics report success of unreachable organization %d:\n%s", id, metrics)
+		}
+	}
+	var latency float64
+	latencyGauge := `registry_probe_latency_seconds{organization_id="4",organization="slow"} `
+	if i := strings.Index(metrics, latencyGauge); i < 0 {
+		t.Errorf("metrics miss latency of timed out probe:\n%s", metrics)
+	} else if _, err = fmt.Sscan(metrics[i+len(latencyGauge):], &latency); err != nil || latency < 0.3 {
+		t.Errorf("latency of timed out probe = %g, want at least the 0.3s timeout", latency)
+	}
+}
@@ -0,0 +1,111 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"ykjam/doc-registry-go/entity"
+)
+
+// HeaderRegistrationToken carries token returned on registration, requester polls status with it
+const HeaderRegistrationToken = "X-Registration-Token"
+
+func (s *Server) HandleRegistrationAdd(w http.ResponseWriter, r *http.Request) {
+	h := "HandleRegistrationAdd "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		req := &entity.OrganizationRegistrationRequest{}
+		err := decodeJsonBody(r, req)
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request body")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationRegister(ctx, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationRegister()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleRegistrationGet(w http.ResponseWriter, r *http.Request) {
+	h := "HandleRegistrationGet "
+	s.handleHttpWithLog(h, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry) {
+		id, err := pathId(r)
+		if err != nil {
+			clog.WithError(err).Warn("invalid registration id")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationRegistrationStatus(ctx, id, r.Header.Get(HeaderRegistrationToken))
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationRegistrationStatus()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleRegistrationList(w http.ResponseWriter, r *http.Request) {
+	h := "HandleRegistrationList "
+	s.handleHttpWithAuth(h, entity.AdminPermissionRead, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		items, err := s.c.OrganizationRegistrationList(ctx, entity.EntityState(r.URL.Query().Get("state")))
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationRegistrationList()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, items, clog)
+	})
+}
+
+func (s *Server) HandleRegistrationApprove(w http.ResponseWriter, r *http.Request) {
+	h := "HandleRegistrationApprove "
+	s.handleHttpWithAuth(h, entity.AdminPermissionOrganizationEdit, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		req := &entity.OrganizationRegistrationReviewRequest{}
+		err := decodeJsonBody(r, req)
+		if err == nil {
+			req.Id, err = pathId(r)
+		}
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationRegistrationApprove(ctx, actor, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationRegistrationApprove()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}
+
+func (s *Server) HandleRegistrationReject(w http.ResponseWriter, r *http.Request) {
+	h := "HandleRegistrationReject "
+	s.handleHttpWithAuth(h, entity.AdminPermissionOrganizationEdit, w, r, func(ctx context.Context, w http.ResponseWriter, r *http.Request, clog *log.Entry, actor *entity.Admin) {
+		req := &entity.OrganizationRegistrationReviewRequest{}
+		err := decodeJsonBody(r, req)
+		if err == nil {
+			req.Id, err = pathId(r)
+		}
+		if err != nil {
+			clog.WithError(err).Warn("error decoding request")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		item, err := s.c.OrganizationRegistrationReject(ctx, actor, req)
+		if err != nil {
+			clog.WithError(err).Error("error in api.OrganizationRegistrationReject()")
+			s.sendResponseByError(w, err, clog)
+			return
+		}
+		s.sendResponseOKWithData(w, item, clog)
+	})
+}